@@ -0,0 +1,35 @@
+// Command fakecc stands in for the compiler when replaying a fixture: it
+// answers each invocation with the output recorded for the same arguments, so
+// runs are deterministic and need no toolchain.
+//
+// The fixture dir is taken from $CLANG_COMPLETE_FIXTURE.
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/icexin/clang_complete/internal/testgen"
+)
+
+func main() {
+	dir := os.Getenv(testgen.FixtureEnv)
+	if dir == "" {
+		fmt.Fprintf(os.Stderr, "fakecc: %s not set\n", testgen.FixtureEnv)
+		os.Exit(2)
+	}
+	f, err := testgen.Load(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fakecc: %s\n", err)
+		os.Exit(2)
+	}
+	c, ok := f.Lookup(os.Args[1:])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "fakecc: no recorded invocation: %s\n", strings.Join(os.Args[1:], " "))
+		os.Exit(1)
+	}
+	os.Stdout.WriteString(c.Stdout)
+	os.Stderr.WriteString(c.Stderr)
+	os.Exit(c.Exit)
+}
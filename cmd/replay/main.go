@@ -0,0 +1,58 @@
+// Command replay reruns clang_complete over fixtures recorded with -fixture,
+// with fakecc as the compiler, and checks that the outputs still match the
+// expected ones byte for byte.
+//
+//	replay -cli clang_complete -fakecc fakecc testdata/*
+//
+// It exits non-zero when a fixture fails.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/icexin/clang_complete/internal/testgen"
+)
+
+var (
+	cli     = flag.String("cli", "clang_complete", "clang_complete binary to test")
+	fakecc  = flag.String("fakecc", "fakecc", "fakecc binary used as the compiler")
+	verbose = flag.Bool("v", false, "print the stderr of every run")
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: replay [-cli clang_complete] [-fakecc fakecc] fixture...")
+		os.Exit(2)
+	}
+	for _, p := range []*string{cli, fakecc} {
+		path, err := exec.LookPath(*p)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		*p, _ = filepath.Abs(path)
+	}
+	failed := 0
+	for _, dir := range flag.Args() {
+		var log io.Writer
+		if *verbose {
+			log = os.Stderr
+		}
+		if err := testgen.Replay(dir, *cli, *fakecc, log); err != nil {
+			fmt.Printf("FAIL %s: %s\n", dir, err)
+			failed++
+			continue
+		}
+		fmt.Printf("ok   %s\n", dir)
+	}
+	if failed != 0 {
+		fmt.Printf("%d of %d fixtures failed\n", failed, flag.NArg())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/icexin/clang_complete
+
+go 1.18
@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var extractTo = cmdline.String("extract-to", "", "with a tarball or zip as src_dir, emit paths as if it was extracted in this dir, the dir of the archive by default")
+
+var archiveSuffixes = []string{".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".zip"}
+
+func isArchive(name string) bool {
+	for _, s := range archiveSuffixes {
+		if strings.HasSuffix(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractSource extracts the source release archive to a temporary dir and
+// returns the dir to scan, the single top-level dir of the archive if it has
+// one. Emitted paths are mapped to -extract-to. The caller removes tmp.
+func extractSource(archive string) (dir, tmp string, err error) {
+	tmp, err = ioutil.TempDir("", "clang_complete")
+	if err != nil {
+		return "", "", err
+	}
+	if strings.HasSuffix(archive, ".zip") {
+		err = extractZip(archive, tmp)
+	} else {
+		err = extractTar(archive, tmp)
+	}
+	if err == nil {
+		err = pruneLinks(tmp)
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		return "", "", err
+	}
+
+	dest := *extractTo
+	if dest == "" {
+		dest = filepath.Dir(archive)
+	}
+	dest, err = filepath.Abs(dest)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return "", "", err
+	}
+	pathMaps = append(pathMaps, pathMap{tmp, dest})
+
+	dir = tmp
+	files, _ := ioutil.ReadDir(tmp)
+	if len(files) == 1 && files[0].IsDir() {
+		dir = filepath.Join(tmp, files[0].Name())
+	}
+	// 指向压缩包的搜索根目录改为解压后的目录
+	for i, root := range searchroots {
+		if abs, err := filepath.Abs(root.Path); err == nil && abs == archive {
+			searchroots[i].Path = dir
+		}
+	}
+	return dir, tmp, nil
+}
+
+// archivePath returns where the archive entry name goes under dir, refusing
+// the ones that would leave it, also through the links extracted before it.
+// An earlier link at the path itself is removed, not written through.
+func archivePath(dir, name string) (string, error) {
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	if !underAny(p, []string{dir}) {
+		return "", fmt.Errorf("archive entry %q is outside the archive", name)
+	}
+	if info, err := os.Lstat(p); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(p); err != nil {
+			return "", err
+		}
+	}
+	// 链接可以串起来，像 l1 -> . 和 l2 -> l1/..，只看字面路径不够
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	parent := filepath.Dir(p)
+	for {
+		if _, err := os.Lstat(parent); err == nil {
+			break
+		}
+		parent = filepath.Dir(parent)
+	}
+	resolved, err := filepath.EvalSymlinks(parent)
+	if err != nil || !underAny(resolved, []string{real}) {
+		return "", fmt.Errorf("archive entry %q is outside the archive", name)
+	}
+	return p, nil
+}
+
+// pruneLinks removes the links extracted under dir that resolve outside it
+// or nowhere.
+func pruneLinks(dir string) error {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	var links []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			links = append(links, path)
+		}
+		return nil
+	})
+	for _, l := range links {
+		resolved, err := filepath.EvalSymlinks(l)
+		if err == nil && underAny(resolved, []string{real}) {
+			continue
+		}
+		log.Debug("skip link %s, outside the archive", l)
+		if err := os.Remove(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractTar(archive, dir string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch {
+	case strings.HasSuffix(archive, ".gz") || strings.HasSuffix(archive, ".tgz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case strings.HasSuffix(archive, ".bz2"):
+		r = bzip2.NewReader(f)
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		p, err := archivePath(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(p, 0755)
+		case tar.TypeReg, tar.TypeRegA:
+			err = writeEntry(p, tr)
+		case tar.TypeSymlink:
+			// 只保留压缩包内部的链接
+			target := filepath.Join(filepath.Dir(p), hdr.Linkname)
+			if filepath.IsAbs(hdr.Linkname) || !underAny(target, []string{dir}) {
+				continue
+			}
+			if err = os.MkdirAll(filepath.Dir(p), 0755); err == nil {
+				err = os.Symlink(hdr.Linkname, p)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractZip(archive, dir string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		p, err := archivePath(dir, f.Name)
+		if err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(p, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeEntry(p, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeEntry(p string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,29 @@
+package cli
+
+// asmFlags are emitted for the assembly files run through the preprocessor,
+// which clang would otherwise not preprocess under other names.
+var asmFlags = []string{"-x", "assembler-with-cpp"}
+
+// isAsm reports whether file is assembly with preprocessor directives. Plain
+// .s files are not preprocessed and have no includes to resolve.
+func isAsm(file string) bool {
+	return langOf(file) == "assembler-with-cpp"
+}
+
+// scanLang returns the language file is scanned as first.
+func scanLang(file string) string {
+	switch {
+	case isAsm(file):
+		return "assembler-with-cpp"
+	case langOf(file) == "c":
+		return "c"
+	}
+	return "c++"
+}
+
+// addAsm adds the language of the preprocessed assembly files.
+func (fs *flagSet) addAsm() {
+	if fs.langCounts()["assembler-with-cpp"] > 0 {
+		fs.addLang("assembler-with-cpp", asmFlags)
+	}
+}
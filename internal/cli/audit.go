@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var auditLog = cmdline.String("audit-log", "", "append the flag changes of each run to this file, with the time, the command and the diff")
+
+// auditChanges appends an entry to -audit-log when the flags of output
+// changed from old to cur, one flag group per line:
+//
+//	2024-05-01T10:00:00Z .clang_complete changed by alice@build1
+//	  cd /src/proj && clang_complete -s third_party
+//	  +-I/src/proj/third_party/foo/include
+//
+// Entries are only ever appended, so the log tells when a flag appeared
+// and which command brought it in.
+func auditChanges(output string, old, cur []string) error {
+	if *auditLog == "" || output == "-" {
+		return nil
+	}
+	lines := diffLines(old, cur)
+	if len(lines) == 0 {
+		return nil
+	}
+	if abs, err := filepath.Abs(output); err == nil {
+		output = abs
+	}
+	who := "unknown"
+	if u, err := user.Current(); err == nil {
+		who = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		who += "@" + host
+	}
+	wd, _ := os.Getwd()
+	args := []string{shellQuote(filepath.Base(os.Args[0]))}
+	for _, arg := range os.Args[1:] {
+		args = append(args, shellQuote(arg))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s changed by %s\n", time.Now().UTC().Format(time.RFC3339), output, who)
+	fmt.Fprintf(&b, "  cd %s && %s\n", shellQuote(wd), strings.Join(args, " "))
+	for _, line := range lines {
+		fmt.Fprintf(&b, "  %s\n", line)
+	}
+	b.WriteString("\n")
+
+	// O_APPEND 保证并发运行的记录不会互相覆盖
+	f, err := os.OpenFile(*auditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// outputLines returns the flags written to output, one flag group per line,
+// to compare the outputs of two runs as written, redacted and path mapped.
+// The entries of compile_commands.json give the groups all of them share,
+// then the others prefixed with their file.
+func outputLines(output string, f *format) []string {
+	if output == "-" {
+		return nil
+	}
+	if f.name != "compdb" {
+		fs, err := readFlagSet(output, f)
+		if err != nil {
+			return nil
+		}
+		return joinGroups(fs.Args())
+	}
+	buf, err := ioutil.ReadFile(output)
+	if err != nil {
+		return nil
+	}
+	var cmds []compileCommand
+	if json.Unmarshal(buf, &cmds) != nil {
+		return nil
+	}
+	groups := make([][]string, len(cmds))
+	count := make(map[string]int)
+	for i, cmd := range cmds {
+		args := cmd.Arguments
+		if len(args) == 0 {
+			args = splitQuoted(cmd.Command)
+		}
+		groups[i] = dedup(joinGroups(compileArgs(args, cmd.File)))
+		for _, g := range groups[i] {
+			count[g]++
+		}
+	}
+	var lines []string
+	for g, n := range count {
+		if n == len(cmds) {
+			lines = append(lines, g)
+		}
+	}
+	for i, cmd := range cmds {
+		for _, g := range groups[i] {
+			if count[g] != len(cmds) {
+				lines = append(lines, cmd.File+": "+g)
+			}
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var autoWorkers = cmdline.Bool("auto-workers", false, "tune the number of concurrent compiler processes to the measured throughput, unless -cc-workers is given")
+
+// checkWorkers refuses a -cc-workers below 1, which would divide the files
+// of a batch by zero and run nothing.
+func checkWorkers() error {
+	if *ccWorkers < 1 {
+		return fmt.Errorf("-cc-workers %d, want at least 1", *ccWorkers)
+	}
+	return nil
+}
+
+// workerTuner climbs to the number of compiler processes scanning the most
+// files per second. Each window of at least two rounds is measured at one
+// width; a width not at least 5% faster than the best one turns the search
+// around, then halves the step, until the step is 0.
+type workerTuner struct {
+	workers int
+	max     int
+	done    bool
+
+	// the window being measured
+	files   int
+	elapsed time.Duration
+	latency time.Duration
+
+	best     int
+	bestRate float64
+	step     int
+	dir      int
+	turned   bool
+}
+
+func newWorkerTuner() *workerTuner {
+	t := &workerTuner{
+		workers: *ccWorkers,
+		max:     2 * runtime.NumCPU(),
+		step:    *ccWorkers / 4,
+		dir:     -1,
+	}
+	if t.step == 0 {
+		t.step = 1
+	}
+	// 显式指定的并发数不调整
+	if !*autoWorkers || isFlagSet("cc-workers") || isFlagSet("work") || *ccWorkers < 2 {
+		t.done = true
+	}
+	return t
+}
+
+// Workers returns the number of compiler processes for the next round.
+func (t *workerTuner) Workers() int {
+	return t.workers
+}
+
+// Observe records a round of files scanned in elapsed, latency being the sum
+// of the time each scan took.
+func (t *workerTuner) Observe(files int, elapsed, latency time.Duration) {
+	if t.done || files == 0 {
+		return
+	}
+	t.files += files
+	t.elapsed += elapsed
+	t.latency += latency
+	if t.files < 2*t.workers || t.elapsed <= 0 {
+		return
+	}
+	rate := float64(t.files) / t.elapsed.Seconds()
+	log.Debug("cc workers %d: %.1f files/s, %s per file", t.workers, rate, t.latency/time.Duration(t.files))
+	t.files, t.elapsed, t.latency = 0, 0, 0
+
+	// 内存不足时更多的进程只会换页，不再尝试更大的并发数
+	if memoryLow() {
+		t.max = t.workers - 1
+		if t.max < 1 {
+			t.max = 1
+		}
+		rate = 0
+	}
+	switch {
+	case t.best == 0 && rate > 0:
+		t.best, t.bestRate = t.workers, rate
+	case rate > t.bestRate*1.05:
+		t.best, t.bestRate = t.workers, rate
+		t.turned = false
+	case !t.turned:
+		t.dir = -t.dir
+		t.turned = true
+	default:
+		t.step /= 2
+		t.dir = -1
+		t.turned = false
+	}
+	if t.best == 0 || t.best > t.max {
+		t.best = t.max
+	}
+	for t.step > 0 {
+		next := t.best + t.dir*t.step
+		if next >= 1 && next <= t.max {
+			t.workers = next
+			return
+		}
+		if !t.turned {
+			t.dir = -t.dir
+			t.turned = true
+			continue
+		}
+		t.step /= 2
+		t.dir = -1
+		t.turned = false
+	}
+	t.workers = t.best
+	t.done = true
+	fmt.Fprintf(os.Stderr, "cc workers tuned to %d, %.1f files/s\n", t.best, t.bestRate)
+}
+
+// memoryLow reports whether less than a tenth of the memory is available.
+// It is false where /proc/meminfo is missing.
+func memoryLow() bool {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	var total, avail int64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		n, _ := strconv.ParseInt(fields[1], 10, 64)
+		switch fields[0] {
+		case "MemTotal:":
+			total = n
+		case "MemAvailable:":
+			avail = n
+		}
+	}
+	return total > 0 && avail > 0 && avail < total/10
+}
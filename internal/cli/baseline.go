@@ -0,0 +1,14 @@
+package cli
+
+var baseline = cmdline.String("baseline", "", "seed include dirs and flags from an existing output file of any format")
+
+func loadBaseline(name string) (dirs []string, flags []string, err error) {
+	fs, err := readFlagSet(name, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, dir := range fs.Systems {
+		flags = append(flags, "-isystem", dir)
+	}
+	return fs.Includes, append(flags, fs.Flags...), nil
+}
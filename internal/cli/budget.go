@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+var timeBudget = cmdline.Duration("time-budget", 0, "stop scanning new files after this long and write what was found so far, 0 means no limit")
+
+// runStart is when the run started, the time budget counts from it.
+var runStart = time.Now()
+
+func budgetExhausted() bool {
+	return *timeBudget > 0 && time.Since(runStart) > *timeBudget
+}
+
+// addCached adds the include dirs of the previous output to a run cut short
+// by the time budget or a timeout, for the sources it did not get to.
+func addCached(printer *printer, output string, f *format) {
+	if output == "-" {
+		return
+	}
+	prev, err := readFlagSet(output, f)
+	if err != nil {
+		return
+	}
+	printer.Printdirs(prev.Includes)
+	printer.Printdirs(prev.Systems)
+	printer.Printdirs(prev.After)
+}
+
+func printCoverage(w io.Writer, reason string, scanned, total int) {
+	percent := 100.0
+	if total != 0 {
+		percent = float64(scanned) * 100 / float64(total)
+	}
+	fmt.Fprintf(w, "%s, scanned %d of %d sources (%.1f%%)\n", reason, scanned, total, percent)
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// capabilities is what editor plugins and wrappers detect at runtime.
+type capabilities struct {
+	Version     string         `json:"version"`
+	Formats     []string       `json:"formats"`
+	Scanners    []string       `json:"scanners"`
+	Subcommands []string       `json:"subcommands"`
+	Requests    []string       `json:"serve_requests"`
+	Protocols   map[string]int `json:"protocols"`
+}
+
+func currentCapabilities() capabilities {
+	c := capabilities{
+		Version:  version,
+		Scanners: scanners,
+		Requests: serveRequests,
+		// 格式变化时各自的版本号会增加
+		Protocols: map[string]int{
+			"serve":        serveProtocol,
+			"index":        indexFormat,
+			"state":        stateVersion,
+			"shared-cache": sharedVersion,
+		},
+	}
+	for name := range formats {
+		c.Formats = append(c.Formats, name)
+	}
+	sort.Strings(c.Formats)
+	for name := range commands {
+		c.Subcommands = append(c.Subcommands, name)
+	}
+	sort.Strings(c.Subcommands)
+	return c
+}
+
+// runCapabilities prints the version, formats, scanners, subcommands and
+// protocol versions, as JSON with -json.
+func runCapabilities(args []string) error {
+	fs := flag.NewFlagSet("capabilities", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print a JSON object")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New("usage: clang_complete " + commands["capabilities"].usage)
+	}
+
+	c := currentCapabilities()
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(c)
+	}
+	fmt.Printf("version: %s\n", c.Version)
+	fmt.Printf("formats: %s\n", strings.Join(c.Formats, " "))
+	fmt.Printf("scanners: %s\n", strings.Join(c.Scanners, " "))
+	fmt.Printf("subcommands: %s\n", strings.Join(c.Subcommands, " "))
+	fmt.Printf("serve requests: %s\n", strings.Join(c.Requests, " "))
+	var names []string
+	for name := range c.Protocols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s protocol: %d\n", name, c.Protocols[name])
+	}
+	return nil
+}
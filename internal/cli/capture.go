@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// captureEnv names the capture log when -log is not given, so build systems
+// taking a compiler launcher need no extra arguments.
+const captureEnv = "CLANG_COMPLETE_CAPTURE"
+
+// runCapture wraps a compiler during a real build: the compile commands are
+// appended to the capture log, one json object per line, and the compiler is
+// run with its exit status passed through. Use it as
+// CC="clang_complete capture -log /tmp/build.log gcc".
+func runCapture(args []string) error {
+	fs := flag.NewFlagSet("capture", flag.ExitOnError)
+	logFile := fs.String("log", os.Getenv(captureEnv), "capture log, $"+captureEnv+" by default")
+	fs.Parse(args)
+	if fs.NArg() == 0 || *logFile == "" {
+		return errors.New("usage: clang_complete " + commands["capture"].usage)
+	}
+	args = fs.Args()
+
+	err := recordCompile(*logFile, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "capture: %s\n", err)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if exit, ok := err.(*exec.ExitError); ok {
+		os.Exit(exit.ExitCode())
+	}
+	return err
+}
+
+// recordCompile appends an entry for each source compiled by args to the
+// capture log, link steps and other commands are not recorded.
+func recordCompile(name string, args []string) error {
+	_, srcext := suffixes(new(config))
+	var sources []string
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		if a == "-o" {
+			i++
+			continue
+		}
+		if !strings.HasPrefix(a, "-") && srcext[filepath.Ext(a)] {
+			sources = append(sources, a)
+		}
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	var buf []byte
+	for _, src := range sources {
+		line, err := json.Marshal(compileCommand{Directory: dir, File: src, Arguments: args})
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	// 并行编译时多个进程同时追加，一次写入不会交错
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(buf)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// runFromCapture converts a capture log into an output. compile_commands.json
+// gets the captured commands as they were run, each source with its own
+// flags; the other formats merge the flags of all the commands.
+func runFromCapture(args []string) error {
+	fs := flag.NewFlagSet("from-capture", flag.ExitOnError)
+	out := fs.String("o", "compile_commands.json", "output file, '-' means stdout")
+	outFormat := formatFlag(fs, "format", "output format, guessed from the file name by default")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: clang_complete " + commands["from-capture"].usage)
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var cmds []compileCommand
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var cmd compileCommand
+		// 编译器被杀掉时可能留下不完整的一行
+		if json.Unmarshal(scanner.Bytes(), &cmd) != nil {
+			continue
+		}
+		cmds = append(cmds, cmd)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(cmds) == 0 {
+		return fmt.Errorf("no compile commands in %s", fs.Arg(0))
+	}
+
+	f, err := outFormat(*out)
+	if err != nil {
+		return err
+	}
+	if f.name == "compdb" {
+		return writeCaptured(*out, cmds)
+	}
+	return writeFlagSet(*out, f, commandsFlagSet(cmds, ""))
+}
+
+// writeCaptured writes the captured commands to compile_commands.json, once
+// each, in the form of -compdb-style.
+func writeCaptured(name string, cmds []compileCommand) error {
+	seen := make(map[string]bool)
+	ret := []compileCommand{}
+	for _, cmd := range cmds {
+		// 同样的命令在增量构建中会记录多次
+		key := cmd.Directory + "\x00" + cmd.File + "\x00" + strings.Join(cmd.Arguments, "\x00")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if *compdbStyle == "command" {
+			quoted := make([]string, len(cmd.Arguments))
+			for i, arg := range cmd.Arguments {
+				quoted[i] = shellQuote(arg)
+			}
+			cmd.Command = strings.Join(quoted, " ")
+			cmd.Arguments = nil
+		}
+		ret = append(ret, cmd)
+	}
+	write := func(w io.Writer) error {
+		buf, err := json.MarshalIndent(ret, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(buf, '\n'))
+		return err
+	}
+	if name == "-" {
+		return write(os.Stdout)
+	}
+	tx, err := newOutputTx(name)
+	if err != nil {
+		return err
+	}
+	if err := tx.write(name, write); err != nil {
+		tx.Abort()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,193 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	caseInsensitive = cmdline.Bool("case-insensitive", false, "resolve the includes found in no dir, like <Windows.h>, to a header differing only in case, like windows.h")
+	reportCase      = cmdline.Bool("report-case", true, "with -case-insensitive, list the includes resolved ignoring case at the end, to fix them for case-sensitive builds")
+)
+
+// foldMatch is a header found ignoring case: its dir, and its name as on
+// disk.
+type foldMatch struct {
+	dir    string
+	header string
+}
+
+// lookupFold follows seps, the components of a header, from the tree of n
+// like Search does, ignoring case.
+func (n *node) lookupFold(seps []string) []foldMatch {
+	type cand struct {
+		n    *node
+		name string
+	}
+	l := []cand{{n, ""}}
+	for i := len(seps) - 1; i >= 0; i-- {
+		var l1 []cand
+		for _, c := range l {
+			// 不区分大小写时子节点的顺序没有用，逐个比较
+			for _, child := range c.n.Children {
+				if !strings.EqualFold(child.Name, seps[i]) {
+					continue
+				}
+				name := child.Name
+				if c.name != "" {
+					name = filepath.Join(name, c.name)
+				}
+				l1 = append(l1, cand{child, name})
+			}
+		}
+		l = l1
+	}
+	var ret []foldMatch
+	for _, c := range l {
+		ret = append(ret, foldMatch{filepath.Dir(c.n.Path()), c.name})
+	}
+	return ret
+}
+
+// SearchFold is Search ignoring case, going through all the entries.
+func (idx *flatIndex) SearchFold(header string) []foldMatch {
+	header = filepath.Clean(strings.TrimPrefix(header, string(filepath.Separator)))
+	suffix := string(filepath.Separator) + header
+	var ret []foldMatch
+	for i := 0; i < idx.nentries; i++ {
+		b, min := idx.entry(i)
+		if len(b) < len(suffix) || len(b)-len(suffix)+1 < min {
+			continue
+		}
+		p := string(b)
+		if !strings.EqualFold(p[len(p)-len(suffix):], suffix) {
+			continue
+		}
+		dir := p[:len(p)-len(suffix)]
+		if dir == "" {
+			dir = string(filepath.Separator)
+		}
+		ret = append(ret, foldMatch{dir, p[len(p)-len(suffix)+1:]})
+	}
+	return ret
+}
+
+// caseStage is the dir of links named like the includes resolved ignoring
+// case to the headers on disk. It is emitted as an include dir, so the
+// compiler finds them on case-sensitive filesystems too.
+var caseStage struct {
+	sync.Mutex
+	root string
+	dir  string
+}
+
+// setCaseRoot keys the stage dir on the source dir instead of the current
+// dir.
+func setCaseRoot(root string) {
+	caseStage.Lock()
+	defer caseStage.Unlock()
+	caseStage.root = root
+}
+
+// stageFold links header to the header m found ignoring case and returns the
+// dir to emit for it. The first header found for a name is kept.
+func stageFold(header string, m foldMatch) (string, error) {
+	caseStage.Lock()
+	defer caseStage.Unlock()
+	if caseStage.dir == "" {
+		root := caseStage.root
+		if root == "" {
+			root = "."
+		}
+		dir := stageDir(root, "case")
+		// 每次运行重新建立，不留下已经改名的头文件
+		if err := os.RemoveAll(dir); err != nil {
+			return "", err
+		}
+		sandboxAllow(dir)
+		caseStage.dir = dir
+	}
+	link := filepath.Join(caseStage.dir, header)
+	if _, err := os.Lstat(link); err == nil {
+		return caseStage.dir, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Symlink(filepath.Join(m.dir, m.header), link); err != nil {
+		return "", err
+	}
+	return caseStage.dir, nil
+}
+
+// searchFold resolves header ignoring case when no dir has it as named. It
+// returns the stage dir and the names of the headers on disk.
+func (t *tree) searchFold(header string, seps []string, nodes []scannedRoot, flats []*flatIndex) ([]string, []string) {
+	var matches []foldMatch
+	for _, root := range nodes {
+		for _, m := range root.node.lookupFold(seps) {
+			if !t.hidden(filepath.Join(m.dir, m.header), root.path) {
+				matches = append(matches, m)
+			}
+		}
+	}
+	for _, idx := range flats {
+		for _, m := range idx.SearchFold(header) {
+			if !t.hidden(filepath.Join(m.dir, m.header), "") {
+				matches = append(matches, m)
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	dir, err := stageFold(header, matches[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "link %s: %s\n", header, err)
+		return nil, nil
+	}
+	var actual []string
+	for _, m := range matches {
+		actual = append(actual, m.header)
+	}
+	return []string{dir}, dedup(actual)
+}
+
+// CaseMismatch records site including header, found only as actual.
+func (r *report) CaseMismatch(header string, actual []string, site includeSite) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, s := range r.caseSites[header] {
+		if s == site {
+			return
+		}
+	}
+	r.caseSites[header] = append(r.caseSites[header], site)
+	r.caseNames[header] = dedup(append(r.caseNames[header], actual...))
+}
+
+func (r *report) printCase(w io.Writer) {
+	var headers []string
+	for h := range r.caseSites {
+		headers = append(headers, h)
+	}
+	sort.Strings(headers)
+	caseStage.Lock()
+	dir := caseStage.dir
+	caseStage.Unlock()
+	fmt.Fprintf(w, "includes found only ignoring case, linked in %s, breaking other case-sensitive builds:\n", dir)
+	for _, h := range headers {
+		fmt.Fprintf(w, "  %s, on disk %s:\n", h, strings.Join(r.caseNames[h], " "))
+		sites := r.caseSites[h]
+		sort.Slice(sites, func(i, j int) bool { return sites[i].String() < sites[j].String() })
+		for _, site := range sites {
+			fmt.Fprintf(w, "    %s\n", site)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{
+		"convert":      {"convert -from file -to file", runConvert},
+		"merge":        {"merge -o file file...", runMerge},
+		"diff":         {"diff old new", runDiff},
+		"flags":        {"flags file...", runFlags},
+		"defines":      {"defines [-lang c++]", runDefines},
+		"warm":         {"warm file", runWarm},
+		"init":         {"init [-o clang_complete.json]", runInit},
+		"wizard":       {"wizard [-o clang_complete.json]", runWizard},
+		"stdin":        {"stdin -filename file [-format compdb] < buffer", runStdin},
+		"query":        {"query [-index file] [-regexp] pattern", runQuery},
+		"serve":        {"serve [-socket .clang_complete.sock]", runServe},
+		"rescan":       {"rescan dir", runRescan},
+		"capture":      {"capture [-log file] cc args...", runCapture},
+		"from-capture": {"from-capture [-o compile_commands.json] [-format compdb] log", runFromCapture},
+		"capabilities": {"capabilities [-json]", runCapabilities},
+	}
+}
+
+// afterDashes reports whether the arguments left by cmdline.Parse follow
+// "--", which makes the first one the src_dir even if it names a subcommand.
+func afterDashes() bool {
+	i := len(cmdArgs) - cmdline.NArg() - 1
+	return i >= 0 && cmdArgs[i] == "--"
+}
+
+func formatFlag(fs *flag.FlagSet, name, usage string) func(file string) (*format, error) {
+	s := fs.String(name, "", usage)
+	return func(file string) (*format, error) {
+		if *s == "" {
+			return formatOf(file), nil
+		}
+		return lookupFormat(*s)
+	}
+}
+
+func runConvert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	from := fs.String("from", "", "input file")
+	to := fs.String("to", "", "output file, '-' means stdout")
+	fromFormat := formatFlag(fs, "from-format", "input format, guessed from the file name by default")
+	toFormat := formatFlag(fs, "to-format", "output format, guessed from the file name by default")
+	fs.Parse(args)
+	if *from == "" || *to == "" {
+		return errors.New("usage: clang_complete " + commands["convert"].usage)
+	}
+
+	f, err := fromFormat(*from)
+	if err != nil {
+		return err
+	}
+	set, err := readFlagSet(*from, f)
+	if err != nil {
+		return err
+	}
+	f, err = toFormat(*to)
+	if err != nil {
+		return err
+	}
+	return writeFlagSet(*to, f, set)
+}
+
+func runMerge(args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	out := fs.String("o", "", "output file, '-' means stdout")
+	outFormat := formatFlag(fs, "format", "output format, guessed from the file name by default")
+	fs.Parse(args)
+	if *out == "" || fs.NArg() == 0 {
+		return errors.New("usage: clang_complete " + commands["merge"].usage)
+	}
+
+	set := new(flagSet)
+	for _, name := range fs.Args() {
+		one, err := readFlagSet(name, nil)
+		if err != nil {
+			return err
+		}
+		set.merge(one)
+	}
+	f, err := outFormat(*out)
+	if err != nil {
+		return err
+	}
+	return writeFlagSet(*out, f, set)
+}
+
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return errors.New("usage: clang_complete " + commands["diff"].usage)
+	}
+
+	old, err := readFlagSet(fs.Arg(0), nil)
+	if err != nil {
+		return err
+	}
+	cur, err := readFlagSet(fs.Arg(1), nil)
+	if err != nil {
+		return err
+	}
+	oldDirs, oldDefines, oldFlags := splitDiff(old)
+	curDirs, curDefines, curFlags := splitDiff(cur)
+	dirs := diffLines(oldDirs, curDirs)
+	defines := diffLines(oldDefines, curDefines)
+	flags := diffLines(oldFlags, curFlags)
+	printDiff("include dirs", dirs)
+	printDiff("defines", defines)
+	printDiff("flags", flags)
+	printDiff("files", diffLines(old.Files, cur.Files))
+
+	// compile_commands.json 的每个文件有自己的参数
+	oldArgs, err := fileArgs(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	curArgs, err := fileArgs(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	var files []string
+	for file := range curArgs {
+		if _, ok := oldArgs[file]; ok {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	// 只列出和整体变化不同的部分
+	shown := make(map[string]bool)
+	for _, line := range append(append(dirs, defines...), flags...) {
+		shown[line] = true
+	}
+	for _, file := range files {
+		var lines []string
+		for _, line := range diffLines(oldArgs[file], curArgs[file]) {
+			if !shown[line] {
+				lines = append(lines, line)
+			}
+		}
+		printDiff(file, lines)
+	}
+	return nil
+}
+
+// splitDiff returns the include dirs, defines and other flags of fs, one
+// flag group per entry.
+func splitDiff(fs *flagSet) (dirs, defines, flags []string) {
+	dirs = joinGroups((&flagSet{Includes: fs.Includes, Systems: fs.Systems, After: fs.After}).Args())
+	for _, dir := range fs.Test {
+		dirs = append(dirs, "-I"+dir+" (test)")
+	}
+	var rest []string
+	rest = append(rest, fs.Flags...)
+	for _, lang := range fs.Langs() {
+		for _, g := range joinGroups(fs.Lang[lang]) {
+			flags = append(flags, g+" ("+lang+")")
+		}
+	}
+	for _, g := range joinGroups(rest) {
+		if strings.HasPrefix(g, "-D") || strings.HasPrefix(g, "-U") {
+			defines = append(defines, g)
+		} else {
+			flags = append(flags, g)
+		}
+	}
+	return dirs, defines, flags
+}
+
+// fileArgs returns the flag groups of each file of a compile_commands.json,
+// and nothing for the formats sharing the flags between files.
+func fileArgs(name string) (map[string][]string, error) {
+	if formatOf(name).name != "compdb" {
+		return nil, nil
+	}
+	buf, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var cmds []compileCommand
+	if err := json.Unmarshal(buf, &cmds); err != nil {
+		return nil, err
+	}
+	base, _ := filepath.Abs(filepath.Dir(name))
+	ret := make(map[string][]string)
+	for _, cmd := range cmds {
+		one := commandsFlagSet([]compileCommand{cmd}, base)
+		for _, file := range one.Files {
+			ret[file] = joinGroups(one.ArgsFor(file))
+		}
+	}
+	return ret, nil
+}
+
+func printDiff(title string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Println(title + ":")
+	for _, line := range lines {
+		fmt.Println("  " + line)
+	}
+}
+
+func joinGroups(args []string) []string {
+	var ret []string
+	for _, g := range flagGroups(args) {
+		ret = append(ret, strings.Join(g, " "))
+	}
+	return ret
+}
+
+// diffLines lists the entries removed from old with a "-" prefix and the ones
+// added in cur with a "+" prefix.
+func diffLines(old, cur []string) []string {
+	in := func(l []string) map[string]bool {
+		m := make(map[string]bool)
+		for _, s := range l {
+			m[s] = true
+		}
+		return m
+	}
+	om, cm := in(old), in(cur)
+	var lines []string
+	for _, s := range old {
+		if !cm[s] {
+			lines = append(lines, "-"+s)
+		}
+	}
+	for _, s := range cur {
+		if !om[s] {
+			lines = append(lines, "+"+s)
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i][1:] < lines[j][1:] })
+	return lines
+}
+
+func runFlags(args []string) error {
+	fs := flag.NewFlagSet("flags", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: clang_complete " + commands["flags"].usage)
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	if err := cfg.apply(); err != nil {
+		return err
+	}
+	headerext, _ := suffixes(cfg)
+	for _, name := range append(fs.Args(), searchroots.Paths()...) {
+		if abs, err := filepath.Abs(name); err == nil {
+			sandboxAllow(abs)
+		}
+	}
+	r := newResolver(searchroots, headerext)
+	for _, name := range fs.Args() {
+		flags, err := r.FlagsForFile(context.Background(), name)
+		if err != nil {
+			return err
+		}
+		for _, g := range flagGroups(flags) {
+			fmt.Println(strings.Join(g, " "))
+		}
+	}
+	return nil
+}
+
+func runDefines(args []string) error {
+	fs := flag.NewFlagSet("defines", flag.ExitOnError)
+	lang := fs.String("lang", "c++", "language passed to the compiler with -x")
+	fs.Parse(args)
+
+	m, err := builtinDefines(*lang)
+	if err != nil {
+		return err
+	}
+	for _, d := range sortedDefines(m) {
+		fmt.Println("-D" + d)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	compdbStyle = cmdline.String("compdb-style", "arguments", "form of the compile_commands.json entries: arguments as an array, or command as a shell quoted string for older consumers")
+	compdbDir   = cmdline.String("compdb-dir", "source", "directory of the compile_commands.json entries: source, the dir of each file, or root, the project root above it")
+)
+
+func checkCompdb() error {
+	switch *compdbStyle {
+	case "arguments", "command":
+	default:
+		return fmt.Errorf("unknown -compdb-style %q", *compdbStyle)
+	}
+	switch *compdbDir {
+	case "source", "root":
+	default:
+		return fmt.Errorf("unknown -compdb-dir %q", *compdbDir)
+	}
+	return nil
+}
+
+// compdbEntry returns the compile command of file with args in the form of
+// -compdb-style and the directory of -compdb-dir.
+func compdbEntry(file string, args []string) compileCommand {
+	cmd := compileCommand{
+		Directory: filepath.Dir(file),
+		File:      file,
+	}
+	if *compdbDir == "root" {
+		cmd.Directory = projectRoot(cmd.Directory)
+	}
+	if *compdbStyle == "arguments" {
+		cmd.Arguments = args
+		return cmd
+	}
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	cmd.Command = strings.Join(quoted, " ")
+	return cmd
+}
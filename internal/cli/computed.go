@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var computedIncl = cmdline.Bool("computed-includes", false, "expand computed #include directives with -E -dD and retry them in later rounds")
+
+var defineRe = regexp.MustCompile(`^#define\s+([A-Za-z_]\w*)(\([^)]*\))?\s*(.*)$`)
+
+// computedSet tracks sources with "#include MACRO" directives, which -M -MG
+// can not see until the macro is defined.
+type computedSet struct {
+	lock   sync.Mutex
+	macros map[string][]string
+	tried  map[string]int
+}
+
+var computed = &computedSet{
+	macros: make(map[string][]string),
+	tried:  make(map[string]int),
+}
+
+func (c *computedSet) lookup(p string) []string {
+	c.lock.Lock()
+	macros, ok := c.macros[p]
+	c.lock.Unlock()
+	if ok {
+		return macros
+	}
+
+	macros = scanComputed(p)
+	c.lock.Lock()
+	c.macros[p] = macros
+	c.lock.Unlock()
+	return macros
+}
+
+// Check expands the computed includes of p with the include dirs known so far
+// and returns the headers they name.
+func (c *computedSet) Check(ctx context.Context, p string, printer *printer) []string {
+	macros := c.lookup(p)
+	if len(macros) == 0 {
+		return nil
+	}
+	c.lock.Lock()
+	c.tried[p] = printer.Len()
+	c.lock.Unlock()
+	return expandComputed(ctx, p, macros, printer.Includes())
+}
+
+// Retry returns the sources whose computed includes were last expanded with
+// fewer include dirs than are known now.
+func (c *computedSet) Retry(ndirs int) []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var ret []string
+	for p, n := range c.tried {
+		if n < ndirs {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+func scanComputed(p string) []string {
+	incs, err := parseIncludesFile(p)
+	if err != nil {
+		return nil
+	}
+	var ret []string
+	for _, inc := range incs {
+		if inc.Macro {
+			ret = append(ret, inc.Name)
+		}
+	}
+	return ret
+}
+
+func expandComputed(ctx context.Context, p string, macros []string, includes []string) []string {
+	args := []string{"-xc++", "-E", "-dD"}
+	args = append(args, scanFlags()...)
+	args = append(args, includes...)
+	args = append(args, p)
+	// 头文件缺失时预处理会中途失败，但之前输出的宏定义仍然可用
+	out, _, _ := runCompilerContext(ctx, args...)
+
+	defines := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		m := defineRe.FindStringSubmatch(scanner.Text())
+		if m != nil && m[2] == "" {
+			defines[m[1]] = strings.TrimSpace(m[3])
+		}
+	}
+
+	var ret []string
+	for _, name := range macros {
+		value := name
+		for i := 0; i < 16; i++ {
+			v, ok := defines[value]
+			if !ok {
+				break
+			}
+			value = v
+		}
+		if len(value) >= 2 && (value[0] == '"' && value[len(value)-1] == '"' ||
+			value[0] == '<' && value[len(value)-1] == '>') {
+			ret = append(ret, value[1:len(value)-1])
+		}
+	}
+	return ret
+}
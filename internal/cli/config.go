@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var configFile = cmdline.String("config", "", "json config file")
+
+type config struct {
+	HeaderSuffix []string `json:"header_suffix"`
+	SrcSuffix    []string `json:"src_suffix"`
+	Sniff        bool     `json:"sniff"`
+	SearchRoots  []string `json:"search_roots"`
+	Exclude      []string `json:"exclude"`
+	Format       string   `json:"format"`
+	// used for scanning when CC is not set
+	Compiler string `json:"compiler,omitempty"`
+	// named variants like debug and release, see -configuration
+	Configurations map[string]*configuration `json:"configurations,omitempty"`
+	// library name to the version of its dirs to emit, see -version-policy
+	Versions map[string]string `json:"versions,omitempty"`
+}
+
+// excludes are the names of dirs and files left out of the scans, globs
+// allowed.
+var excludes []string
+
+func loadConfig(name string) (*config, error) {
+	cfg := new(config)
+	if name == "" {
+		return cfg, nil
+	}
+	buf, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(buf, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// apply makes the settings of cfg the defaults of the command line: its
+// search roots are used when -s is not given and its format without -format.
+func (cfg *config) apply() error {
+	if len(searchroots) == 0 {
+		for _, root := range cfg.SearchRoots {
+			if err := searchroots.Set(root); err != nil {
+				return err
+			}
+		}
+	}
+	if cfg.Format != "" && !isFlagSet("format") {
+		*outFormat = cfg.Format
+	}
+	excludes = cfg.Exclude
+	versionPins = make(map[string]string)
+	for name, version := range cfg.Versions {
+		versionPins[strings.ToLower(name)] = strings.Replace(version, "_", ".", -1)
+	}
+	if cfg.Compiler != "" && os.Getenv("CC") == "" {
+		os.Setenv("CC", cfg.Compiler)
+	}
+	if *configName != "" {
+		c, ok := cfg.Configurations[*configName]
+		if !ok {
+			return fmt.Errorf("unknown configuration %q", *configName)
+		}
+		ccflags = append(ccflags, c.args()...)
+	}
+	return nil
+}
+
+func isExcluded(name string) bool {
+	for _, pattern := range excludes {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var configName = cmdline.String("configuration", "", "configuration of the config file to generate, all of them to suffixed outputs by default")
+
+// configuration is a named variant of the build, with its own defines,
+// sysroot and flags.
+type configuration struct {
+	Defines []string `json:"defines"`
+	Sysroot string   `json:"sysroot"`
+	Flags   []string `json:"flags"`
+}
+
+func (c *configuration) args() []string {
+	var ret []string
+	for _, d := range c.Defines {
+		ret = append(ret, "-D"+d)
+	}
+	if c.Sysroot != "" {
+		ret = append(ret, "--sysroot="+c.Sysroot)
+	}
+	return append(ret, c.Flags...)
+}
+
+// configOutput returns the output of the configuration name, with the name
+// before the extension: compile_commands.debug.json, .clang_complete.debug.
+func configOutput(output, name string) string {
+	if output == "-" {
+		return output
+	}
+	dir, base := filepath.Split(output)
+	ext := filepath.Ext(base)
+	if ext == base {
+		return output + "." + name
+	}
+	return dir + strings.TrimSuffix(base, ext) + "." + name + ext
+}
+
+// runConfigurations generates every configuration of cfg in turn, each with
+// its own discovery since conditional includes may need other dirs.
+func runConfigurations(cfg *config, output string) error {
+	var names []string
+	for name := range cfg.Configurations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// 选项需要放在源码目录参数之前
+	n := len(os.Args) - cmdline.NArg()
+	for _, name := range names {
+		out := configOutput(output, name)
+		fmt.Fprintf(os.Stderr, "configuration %s: %s\n", name, out)
+		args := append([]string{}, os.Args[1:n]...)
+		args = append(args, "-configuration", name, "-o", out)
+		args = append(args, os.Args[n:]...)
+		cmd := exec.Command(executable(), args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("configuration %s: %s", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var cudaFallback = cmdline.Bool("cuda-fallback", true, "scan .cu files host-side when no CUDA toolchain is installed")
+
+// cudaHostDefines hide the device-only qualifiers from a host compiler.
+var cudaHostDefines = []string{
+	"-D__global__=", "-D__device__=", "-D__host__=", "-D__shared__=",
+	"-D__constant__=", "-D__managed__=", "-D__forceinline__=inline",
+	"-D__launch_bounds__(...)=",
+}
+
+// cudaHostFlags are emitted for .cu files so clang parses them as CUDA
+// without an installation.
+var cudaHostFlags = append([]string{"-x", "cuda", "--no-cuda-version-check", "-nocudainc", "-nocudalib"}, cudaHostDefines...)
+
+var cudaOnce struct {
+	sync.Once
+	missing bool
+}
+
+// cudaHostOnly reports whether .cu files are handled in the degraded host-side mode.
+func cudaHostOnly() bool {
+	if !*cudaFallback {
+		return false
+	}
+	cudaOnce.Do(func() {
+		if _, err := exec.LookPath("nvcc"); err == nil {
+			return
+		}
+		for _, dir := range []string{os.Getenv("CUDA_PATH"), os.Getenv("CUDA_HOME"), "/usr/local/cuda"} {
+			if dir == "" {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(dir, "include", "cuda_runtime.h")); err == nil {
+				return
+			}
+		}
+		cudaOnce.missing = true
+	})
+	return cudaOnce.missing
+}
+
+func isCuda(file string) bool {
+	return strings.ToLower(filepath.Ext(file)) == ".cu"
+}
+
+// splitCuda separates cudaHostFlags from the flags of a .cu file.
+func splitCuda(flags []string) (rest, cuda []string) {
+	return splitKnown(flags, cudaHostFlags)
+}
+
+// splitKnown separates the flag groups of known from flags.
+func splitKnown(flags, known []string) (rest, found []string) {
+	groups := make(map[string]bool)
+	for _, g := range flagGroups(known) {
+		groups[strings.Join(g, " ")] = true
+	}
+	for _, g := range flagGroups(flags) {
+		if groups[strings.Join(g, " ")] {
+			found = append(found, g...)
+			continue
+		}
+		rest = append(rest, g...)
+	}
+	return rest, found
+}
+
+// addCuda adds the host-side CUDA flags of the .cu files.
+func (fs *flagSet) addCuda() {
+	if cudaHostOnly() && fs.langCounts()["cuda"] > 0 {
+		fs.addLang("cuda", cudaHostFlags)
+	}
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type macroCache struct {
+	lock sync.Mutex
+	m    map[string]map[string]string
+}
+
+var builtins = &macroCache{m: make(map[string]map[string]string)}
+
+// builtinDefines returns the macros predefined by the compiler for lang
+// (c, c++, objective-c...) under the current extra flags. Results are cached
+// for the lifetime of the process.
+func builtinDefines(lang string) (map[string]string, error) {
+	key := lang + "\x00" + strings.Join(scanFlags(), "\x00")
+	builtins.lock.Lock()
+	defer builtins.lock.Unlock()
+	if m, ok := builtins.m[key]; ok {
+		return m, nil
+	}
+
+	args := append([]string{"-x" + lang, "-dM", "-E"}, scanFlags()...)
+	out, _, err := runCompiler(append(args, "-")...)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		match := defineRe.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		m[match[1]+match[2]] = strings.TrimSpace(match[3])
+	}
+	builtins.m[key] = m
+	return m, nil
+}
+
+func sortedDefines(m map[string]string) []string {
+	var ret []string
+	for name, value := range m {
+		if value == "" {
+			ret = append(ret, name)
+			continue
+		}
+		ret = append(ret, name+"="+value)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// dirRulesFile holds the extra flags and excludes of the sources under its dir.
+const dirRulesFile = ".clang_complete_dir"
+
+// dirRule is a parsed dirRulesFile. Each line is a compiler flag, or
+// "exclude pattern" matched against the names and the paths relative to the
+// dir of the file.
+type dirRule struct {
+	flags    []string
+	excludes []string
+}
+
+// dirRuleCache loads the dirRulesFile of each dir under root once.
+type dirRuleCache struct {
+	lock  sync.Mutex
+	root  string
+	rules map[string]*dirRule
+}
+
+var dirRules = &dirRuleCache{rules: make(map[string]*dirRule)}
+
+// SetRoot sets the dir above which no rules are read.
+func (c *dirRuleCache) SetRoot(root string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.root = root
+}
+
+// load returns the rules of dir, nil if it has none.
+func (c *dirRuleCache) load(dir string) *dirRule {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if r, ok := c.rules[dir]; ok {
+		return r
+	}
+	r, err := parseDirRule(dir)
+	if err != nil && !os.IsNotExist(err) {
+		rep.ScanError(err)
+	}
+	c.rules[dir] = r
+	return r
+}
+
+func parseDirRule(dir string) (*dirRule, error) {
+	f, err := os.Open(filepath.Join(dir, dirRulesFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := new(dirRule)
+	var args []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if strings.HasPrefix(line, "exclude ") {
+			r.excludes = append(r.excludes, strings.Fields(line)[1:]...)
+			continue
+		}
+		args = append(args, splitQuoted(line)...)
+	}
+	// 相对路径相对于规则文件所在目录
+	for _, g := range flagGroups(args) {
+		opt := g[0]
+		switch {
+		case len(g) == 2 && (opt == "-I" || opt == "-isystem" || opt == "-idirafter" || opt == "-iquote" || opt == "-include"):
+			g = []string{opt, absUnder(dir, g[1])}
+		case strings.HasPrefix(opt, "-I") && len(opt) > 2:
+			g = []string{"-I" + absUnder(dir, opt[2:])}
+		}
+		r.flags = append(r.flags, g...)
+	}
+	return r, scanner.Err()
+}
+
+func absUnder(base, p string) string {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(base, p)
+	}
+	return filepath.Clean(p)
+}
+
+func (c *dirRuleCache) getRoot() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.root
+}
+
+// chain returns the rules of the dirs from the root down to dir.
+func (c *dirRuleCache) chain(dir string) []*dirRule {
+	root := c.getRoot()
+	if root == "" || !underAny(dir, []string{root}) {
+		return nil
+	}
+	var ret []*dirRule
+	for p := dir; ; p = filepath.Dir(p) {
+		if r := c.load(p); r != nil {
+			ret = append([]*dirRule{r}, ret...)
+		}
+		if p == root || filepath.Dir(p) == p {
+			return ret
+		}
+	}
+}
+
+// Flags returns the extra flags of file, the ones of outer dirs first so the
+// inner ones win.
+func (c *dirRuleCache) Flags(file string) []string {
+	var ret []string
+	for _, r := range c.chain(filepath.Dir(file)) {
+		ret = append(ret, r.flags...)
+	}
+	return ret
+}
+
+// Excluded reports whether a rules file of the dirs above path excludes it.
+func (c *dirRuleCache) Excluded(path string) bool {
+	root := c.getRoot()
+	if root == "" || path == root || !underAny(path, []string{root}) {
+		return false
+	}
+	for p := filepath.Dir(path); ; p = filepath.Dir(p) {
+		if r := c.load(p); r != nil {
+			rel, _ := filepath.Rel(p, path)
+			for _, pattern := range r.excludes {
+				if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+					return true
+				}
+				if ok, _ := filepath.Match(pattern, filepath.ToSlash(rel)); ok {
+					return true
+				}
+			}
+		}
+		if p == root || filepath.Dir(p) == p {
+			return false
+		}
+	}
+}
+
+// addDirFlags gives the sources under rules files their extra flags.
+func (fs *flagSet) addDirFlags() {
+	for _, file := range fs.Files {
+		flags := dirRules.Flags(file)
+		if len(flags) == 0 {
+			continue
+		}
+		if fs.FileFlags == nil {
+			fs.FileFlags = make(map[string][]string)
+		}
+		fs.FileFlags[file] = flags
+	}
+}
+
+// fileScanFlags returns the extra flags file is scanned with.
+func fileScanFlags(file string) []string {
+	return append(scanFlags(), dirRules.Flags(file)...)
+}
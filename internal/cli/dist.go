@@ -0,0 +1,292 @@
+package cli
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	coordinatorAddr = cmdline.String("coordinator", "", "listen on addr and hand dependency scans to connected workers")
+	workerAddr      = cmdline.String("worker", "", "run as a worker of the coordinator at host:port")
+	waitWorkers     = cmdline.Int("wait-workers", 0, "number of workers the coordinator waits for before scanning")
+	distToken       = cmdline.String("token", os.Getenv("CLANG_COMPLETE_TOKEN"), "secret shared by the coordinator and its workers, CLANG_COMPLETE_TOKEN by default; the coordinator makes one up if empty")
+)
+
+type ScanArgs struct {
+	File     string
+	Includes []string
+	Flags    []string
+	Suffixes map[string]bool
+	Sniff    bool
+}
+
+// ScanReply is the result of a remote scan. Lang is the language the scan
+// succeeded with if the worker had to retry it.
+type ScanReply struct {
+	Headers []string
+	Lang    string
+}
+
+// HelloArgs and HelloReply exchange nonces the two sides prove they know the
+// -token with, without sending it.
+type HelloArgs struct {
+	Version string
+	Nonce   string
+}
+
+type HelloReply struct {
+	Host  string
+	Slots int
+	Proof string
+	Nonce string
+}
+
+type AuthArgs struct {
+	Proof string
+}
+
+// Worker is served by worker processes over the connection they open to the
+// coordinator. Workers must see the sources at the same paths. They scan
+// nothing until the coordinator proved it knows the token.
+type Worker struct {
+	nonce  string
+	authed bool
+}
+
+func (w *Worker) Hello(args *HelloArgs, reply *HelloReply) error {
+	if args.Version != version {
+		return fmt.Errorf("coordinator version %s, worker version %s", args.Version, version)
+	}
+	reply.Host, _ = os.Hostname()
+	reply.Slots = *ccWorkers
+	reply.Proof = tokenProof("worker", args.Nonce)
+	w.nonce = newNonce()
+	reply.Nonce = w.nonce
+	return nil
+}
+
+func (w *Worker) Auth(args *AuthArgs, reply *bool) error {
+	if w.nonce == "" || !hmac.Equal([]byte(args.Proof), []byte(tokenProof("coordinator", w.nonce))) {
+		return errors.New("bad token")
+	}
+	w.authed = true
+	*reply = true
+	return nil
+}
+
+func (w *Worker) ListHeaders(args *ScanArgs, reply *ScanReply) error {
+	if !w.authed {
+		return errors.New("coordinator not authenticated")
+	}
+	headers, err := scanHeaders(context.Background(), args.File, args.Suffixes, args.Includes, args.Flags, args.Sniff)
+	if err != nil {
+		return err
+	}
+	reply.Headers = headers
+	reply.Lang = scanLangs.Get(args.File)
+	return nil
+}
+
+// tokenProof proves the knowledge of -token to the side that sent nonce.
+func tokenProof(role, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(*distToken))
+	fmt.Fprintf(mac, "%s\x00%s", role, nonce)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func runWorker(addr string) error {
+	if *distToken == "" {
+		return errors.New("the worker needs the -token the coordinator printed, or CLANG_COMPLETE_TOKEN")
+	}
+	server := rpc.NewServer()
+	err := server.Register(new(Worker))
+	if err != nil {
+		return err
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "connected to coordinator %s\n", addr)
+	server.ServeConn(conn)
+	return nil
+}
+
+type workerSet struct {
+	lock  sync.Mutex
+	cond  *sync.Cond
+	slots chan *rpc.Client
+	n     int
+	hosts int
+}
+
+var workers *workerSet
+
+// startCoordinator listens for workers on addr, on localhost if it names no
+// host: 0.0.0.0:7411 accepts other machines.
+func startCoordinator(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		addr = net.JoinHostPort("127.0.0.1", port)
+	}
+	if *distToken == "" {
+		*distToken = newNonce()
+		fmt.Fprintf(os.Stderr, "workers join with -token %s\n", *distToken)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	workers = &workerSet{slots: make(chan *rpc.Client, 1024)}
+	workers.cond = sync.NewCond(&workers.lock)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go workers.add(conn)
+		}
+	}()
+	return nil
+}
+
+func (ws *workerSet) add(conn net.Conn) {
+	client := rpc.NewClient(conn)
+	var hello HelloReply
+	nonce := newNonce()
+	err := client.Call("Worker.Hello", &HelloArgs{version, nonce}, &hello)
+	if err == nil && !hmac.Equal([]byte(hello.Proof), []byte(tokenProof("worker", nonce))) {
+		err = errors.New("bad token")
+	}
+	if err == nil {
+		var ok bool
+		err = client.Call("Worker.Auth", &AuthArgs{tokenProof("coordinator", hello.Nonce)}, &ok)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "worker %s rejected: %s\n", conn.RemoteAddr(), err)
+		client.Close()
+		return
+	}
+	fmt.Fprintf(os.Stderr, "worker %s joined with %d slots\n", hello.Host, hello.Slots)
+
+	ws.lock.Lock()
+	for i := 0; i < hello.Slots && ws.n < cap(ws.slots); i++ {
+		ws.slots <- client
+		ws.n++
+	}
+	ws.hosts++
+	ws.cond.Broadcast()
+	ws.lock.Unlock()
+}
+
+// Wait blocks until n workers joined or timeout passed.
+func (ws *workerSet) Wait(n int, timeout time.Duration) {
+	timer := time.AfterFunc(timeout, func() {
+		ws.lock.Lock()
+		ws.cond.Broadcast()
+		ws.lock.Unlock()
+	})
+	defer timer.Stop()
+
+	b := time.Now()
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	for ws.hosts < n && time.Now().Sub(b) < timeout {
+		ws.cond.Wait()
+	}
+}
+
+// Slots is the number of remote scans that may run concurrently.
+func (ws *workerSet) Slots() int {
+	if ws == nil {
+		return 0
+	}
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	return ws.n
+}
+
+// scan runs a dependency scan on a free worker slot. ok is false if there is
+// none, or the worker went away, and the scan should run locally. Once ctx is
+// done the scan is abandoned with ctx's error; the slot is freed when the
+// worker answers.
+func (ws *workerSet) scan(ctx context.Context, args *ScanArgs) (headers []string, ok bool, err error) {
+	if ws == nil {
+		return nil, false, nil
+	}
+	var client *rpc.Client
+	select {
+	case client = <-ws.slots:
+	default:
+		return nil, false, nil
+	}
+	var reply ScanReply
+	call := client.Go("Worker.ListHeaders", args, &reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+	case <-ctx.Done():
+		go func() {
+			<-call.Done
+			ws.release(client, call.Error)
+		}()
+		return nil, true, ctx.Err()
+	}
+	if !ws.release(client, call.Error) {
+		return nil, false, nil
+	}
+	if reply.Lang != "" {
+		scanLangs.Set(args.File, reply.Lang)
+	}
+	return reply.Headers, true, call.Error
+}
+
+// release puts the slot of client back after a call that returned err, or
+// drops it if the worker went away, and reports whether it was kept.
+func (ws *workerSet) release(client *rpc.Client, err error) bool {
+	if _, remote := err.(rpc.ServerError); err != nil && !remote {
+		log.Debug("drop worker slot: %s", err)
+		ws.lock.Lock()
+		ws.n--
+		ws.lock.Unlock()
+		return false
+	}
+	ws.slots <- client
+	return true
+}
+
+func dependencies(ctx context.Context, file string, acceptsuffix map[string]bool, includes []string) ([]string, error) {
+	if headers, ok := batch.take(file); ok {
+		return headers, nil
+	}
+	headers, ok, err := workers.scan(ctx, &ScanArgs{
+		File:     file,
+		Includes: includes,
+		Flags:    fileScanFlags(file),
+		Suffixes: acceptsuffix,
+		Sniff:    *sniff,
+	})
+	if ok {
+		return headers, err
+	}
+	return listheaders(ctx, file, acceptsuffix, includes)
+}
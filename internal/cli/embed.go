@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var embedDirs = cmdline.Bool("embed-dirs", false, "resolve the resources of #embed directives in the search roots and emit --embed-dir for their dirs")
+
+// embedRoot is the source dir; the headers under it are read for #embed too.
+var embedRoot string
+
+// resourceIndex lists the files of the source dir and the search roots by
+// name. The index of headers only keeps header suffixes, so it is built
+// apart, the first time an #embed needs it.
+type resourceIndex struct {
+	once  sync.Once
+	roots []string
+	files map[string][]string
+}
+
+var resources = new(resourceIndex)
+
+func (r *resourceIndex) build() {
+	r.files = make(map[string][]string)
+	for _, root := range r.roots {
+		root, err := filepath.Abs(msys.Windows(root))
+		if err != nil {
+			continue
+		}
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			name := info.Name()
+			if path != root && (name[0] == '.' || isExcluded(name)) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.IsDir() {
+				r.files[name] = append(r.files[name], path)
+			}
+			return nil
+		})
+	}
+}
+
+// Search returns the dirs that resource, as named by an #embed, is relative
+// to.
+func (r *resourceIndex) Search(resource string) []string {
+	r.once.Do(r.build)
+	suffix := string(filepath.Separator) + filepath.FromSlash(resource)
+	var ret []string
+	for _, p := range r.files[filepath.Base(resource)] {
+		if strings.HasSuffix(p, suffix) {
+			ret = append(ret, strings.TrimSuffix(p, suffix))
+		}
+	}
+	return dedup(ret)
+}
+
+// embedFlags resolves the #embed directives of src and of its headers under
+// the source dir. A quoted resource next to the file embedding it needs no
+// flag.
+func embedFlags(ctx context.Context, src string, headers []string) []string {
+	if !*embedDirs {
+		return nil
+	}
+	files := []string{src}
+	for _, h := range headers {
+		if filepath.IsAbs(h) && underAny(h, []string{embedRoot}) {
+			files = append(files, h)
+		}
+	}
+	var ret []string
+	for _, file := range files {
+		embeds, err := parseEmbedsFile(file)
+		if err != nil {
+			continue
+		}
+		for _, e := range embeds {
+			if e.Macro {
+				continue
+			}
+			if !e.Angled {
+				if _, err := os.Stat(filepath.Join(filepath.Dir(file), e.Name)); err == nil {
+					continue
+				}
+			}
+			dirs := resources.Search(e.Name)
+			if len(dirs) == 0 {
+				sessionOf(ctx).rep.Unresolved(e.Name, includeSite{file, e.Line, src})
+				continue
+			}
+			for _, dir := range dirs {
+				ret = append(ret, "--embed-dir="+dir)
+			}
+		}
+	}
+	return ret
+}
+
+func parseEmbedsFile(p string) ([]include, error) {
+	f, err := openRegular(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	// 大多数文件没有 #embed，不必逐行解析
+	if !bytes.Contains(data, []byte("embed")) {
+		return nil, nil
+	}
+	return parseDirectives(data, []string{"embed"}), nil
+}
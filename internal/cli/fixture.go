@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/icexin/clang_complete/internal/testgen"
+)
+
+var fixtureDir = cmdline.String("fixture", "", "snapshot sources, compiler outputs and the expected output into a test fixture dir")
+
+const fixtureMaxFile = 1 << 20
+
+var (
+	recorder *testgen.Recorder
+	manifest *testgen.Manifest
+)
+
+func startFixture(dir, srcroot string, roots rootSlice, accept func(path string) bool) error {
+	paths := map[string]string{srcroot: "src"}
+	manifest = &testgen.Manifest{Src: "src"}
+	var copies [][2]string
+	for i, spec := range roots {
+		root, err := filepath.Abs(spec.Path)
+		if err != nil {
+			return err
+		}
+		if underAny(root, []string{srcroot}) {
+			rel, _ := filepath.Rel(srcroot, root)
+			spec.Path = filepath.ToSlash(filepath.Join("src", rel))
+			manifest.Roots = append(manifest.Roots, spec.String())
+			continue
+		}
+		spec.Path = "roots/" + strconv.Itoa(i)
+		paths[root] = spec.Path
+		manifest.Roots = append(manifest.Roots, spec.String())
+		copies = append(copies, [2]string{root, spec.Path})
+	}
+
+	var err error
+	recorder, err = testgen.NewRecorder(dir, paths)
+	if err != nil {
+		return err
+	}
+	copies = append([][2]string{{srcroot, "src"}}, copies...)
+	for _, c := range copies {
+		err = recorder.Copy(c[0], c[1], func(path string, info os.FileInfo) bool {
+			return info.Size() <= fixtureMaxFile && accept(path)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func finishFixture(f *format, output string, fs *flagSet) error {
+	buf := new(bytes.Buffer)
+	err := f.write(buf, fs)
+	if err != nil {
+		return err
+	}
+	manifest.Format = f.name
+	manifest.Output = filepath.Base(output)
+	if output == "-" {
+		manifest.Output = f.output
+	}
+	manifest.Compiler = recorder.Rel(compiler())
+	manifest.Flags = []string{fmt.Sprintf("-sys=%v", printSystem)}
+	for _, flag := range ccflags {
+		manifest.Flags = append(manifest.Flags, "-x", recorder.Rel(flag))
+	}
+	return recorder.Close(manifest, buf.Bytes())
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/icexin/clang_complete/internal/testgen"
+)
+
+// TestFixtures builds clang_complete and fakecc and replays the fixture
+// under testdata/fixture once per output format.
+func TestFixtures(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the binaries")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("fakecc is linked under the recorded compiler name")
+	}
+	bin := t.TempDir()
+	cli, fakecc := filepath.Join(bin, "clang_complete"), filepath.Join(bin, "fakecc")
+	const pkg = "github.com/icexin/clang_complete"
+	for _, b := range [][2]string{{cli, pkg}, {fakecc, pkg + "/cmd/fakecc"}} {
+		out, err := exec.Command("go", "build", "-o", b[0], b[1]).CombinedOutput()
+		if err != nil {
+			t.Fatalf("go build %s: %s\n%s", b[1], err, out)
+		}
+	}
+
+	f, err := testgen.Load("testdata/fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, format := range formats {
+		fx := f.WithFormat(name, format.output)
+		t.Run(name, func(t *testing.T) {
+			if err := fx.Replay(cli, fakecc, nil); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var (
+	forceInclude stringSlice
+	detectConfig = cmdline.Bool("detect-config", false, "force include config.h when sources use its macros without including it")
+)
+
+func init() {
+	cmdline.Var(&forceInclude, "force-include", "header included before every source with -include, also while scanning")
+}
+
+// configDirs are the dirs under the source dir where configure leaves config.h.
+var configDirs = []string{".", "build", "_build"}
+
+var (
+	condRe  = regexp.MustCompile(`^\s*#\s*(if|ifdef|ifndef|elif|elifdef|elifndef)\b(.*)$`)
+	identRe = regexp.MustCompile(`[A-Za-z_]\w*`)
+)
+
+// checkForceInclude adds the headers of -force-include to the extra cc flags,
+// so they are seen by the dependency scans as by the outputs.
+func checkForceInclude() error {
+	for _, name := range forceInclude {
+		p, err := filepath.Abs(name)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(p); err != nil {
+			return fmt.Errorf("force include: %s", err)
+		}
+		ccflags = append(ccflags, "-include", p)
+	}
+	return nil
+}
+
+// configIncludes returns the -include flags of the config header of srcroot
+// if some of sources test its macros without including it, directly or
+// through the headers t resolves.
+func configIncludes(srcroot string, sources []string, t *tree) []string {
+	if !*detectConfig {
+		return nil
+	}
+	for _, dir := range configDirs {
+		p := filepath.Join(srcroot, dir, "config.h")
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		for _, g := range flagGroups(ccflags) {
+			if len(g) == 2 && (g[0] == "-include" || g[0] == "-imacros") && filepath.Base(g[1]) == "config.h" {
+				return nil
+			}
+		}
+		macros := configMacros(p)
+		if len(macros) == 0 {
+			return nil
+		}
+		n := 0
+		for _, src := range sources {
+			if usesConfig(src, macros, t) {
+				n++
+			}
+		}
+		if n == 0 {
+			return nil
+		}
+		fmt.Fprintf(os.Stderr, "force include %s: %d sources use its macros without including it\n", p, n)
+		return []string{"-include", p}
+	}
+	return nil
+}
+
+// configMacros returns the macros defined by the config header p.
+func configMacros(p string) map[string]bool {
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil
+	}
+	macros := make(map[string]bool)
+	for _, line := range bytes.Split(stripSource(decodeSource(data)), []byte("\n")) {
+		if m := defineRe.FindSubmatch(bytes.TrimSpace(line)); m != nil {
+			macros[string(m[1])] = true
+		}
+	}
+	return macros
+}
+
+// usesConfig reports whether the conditionals of src or of the headers it
+// includes test one of macros while none of them includes config.h.
+func usesConfig(src string, macros map[string]bool, t *tree) bool {
+	uses := false
+	for _, p := range includedFiles(src, t) {
+		if filepath.Base(p) == "config.h" {
+			return false
+		}
+		if !uses {
+			uses = testsMacros(p, macros)
+		}
+	}
+	return uses
+}
+
+// includedFiles returns src and the files it includes, recursively, quoted
+// includes found next to their includer first and the others in t.
+func includedFiles(src string, t *tree) []string {
+	seen := map[string]bool{src: true}
+	ret := []string{src}
+	for i := 0; i < len(ret); i++ {
+		incs, err := parseIncludesFile(ret[i])
+		if err != nil {
+			continue
+		}
+		for _, inc := range incs {
+			if inc.Macro {
+				continue
+			}
+			p := ""
+			if local := filepath.Join(filepath.Dir(ret[i]), inc.Name); !inc.Angled && isFile(local) {
+				p = local
+			} else if dirs, err := t.Search(inc.Name); err == nil && len(dirs) != 0 {
+				p = filepath.Join(dirs[0], inc.Name)
+			}
+			if p != "" && !seen[p] {
+				seen[p] = true
+				ret = append(ret, p)
+			}
+		}
+	}
+	return ret
+}
+
+// testsMacros reports whether the conditionals of p test one of macros.
+func testsMacros(p string, macros map[string]bool) bool {
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return false
+	}
+	for _, line := range bytes.Split(stripSource(decodeSource(data)), []byte("\n")) {
+		m := condRe.FindSubmatch(line)
+		if m == nil {
+			continue
+		}
+		for _, id := range identRe.FindAll(m[2], -1) {
+			if macros[string(id)] {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,544 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// flagSet is the format independent content of an output file.
+type flagSet struct {
+	Includes []string
+	Systems  []string
+	After    []string
+	// include dirs of test roots, for test sources only
+	Test  []string
+	Flags []string
+	Files []string
+	// extra flags of the files of each language in a mixed tree
+	Lang map[string][]string
+	// include dirs too few files need to be shared, see -min-uses
+	FileIncludes map[string][]string
+	// extra flags of the sources under .clang_complete_dir files
+	FileFlags map[string][]string
+	// sources that resolved headers in each include dir, kept in a side
+	// file for rescan
+	Uses map[string][]string
+	Meta *metadata
+}
+
+func (fs *flagSet) Args() []string {
+	var ret []string
+	for _, dir := range fs.Includes {
+		ret = append(ret, "-I"+dir)
+	}
+	for _, dir := range fs.Systems {
+		ret = append(ret, systemFlag(), dir)
+	}
+	for _, dir := range fs.After {
+		ret = append(ret, "-idirafter", dir)
+	}
+	return append(ret, fs.Flags...)
+}
+
+// addArgs parses compiler arguments into fs, resolving relative dirs against base.
+func (fs *flagSet) addArgs(args []string, base string) {
+	abs := func(p string) string {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(base, p)
+		}
+		return filepath.Clean(p)
+	}
+	for _, g := range flagGroups(args) {
+		switch {
+		case g[0] == "-I" && len(g) == 2:
+			fs.Includes = append(fs.Includes, abs(g[1]))
+		case strings.HasPrefix(g[0], "-I") && len(g[0]) > 2:
+			fs.Includes = append(fs.Includes, abs(g[0][2:]))
+		case (g[0] == "-isystem" || g[0] == "-imsvc") && len(g) == 2:
+			fs.Systems = append(fs.Systems, abs(g[1]))
+		case g[0] == "-idirafter" && len(g) == 2:
+			fs.After = append(fs.After, abs(g[1]))
+		default:
+			fs.Flags = append(fs.Flags, g...)
+		}
+	}
+}
+
+func (fs *flagSet) merge(o *flagSet) {
+	// 没有来源记录的输出不能补上部分记录，否则下次 rescan 会误删目录
+	if fs.Uses != nil {
+		for dir, srcs := range o.Uses {
+			fs.Uses[dir] = dedup(append(fs.Uses[dir], srcs...))
+		}
+	}
+	fs.Includes = dedup(append(fs.Includes, o.Includes...))
+	fs.Systems = dedup(append(fs.Systems, o.Systems...))
+	fs.After = dedup(append(fs.After, o.After...))
+	fs.Test = dedup(append(fs.Test, o.Test...))
+	fs.Flags = dedupFlags(append(fs.Flags, o.Flags...))
+	fs.Files = dedup(append(fs.Files, o.Files...))
+	for lang, flags := range o.Lang {
+		fs.addLang(lang, flags)
+	}
+}
+
+func dedup(l []string) []string {
+	var ret []string
+	seen := make(map[string]bool)
+	for _, s := range l {
+		if !seen[s] {
+			seen[s] = true
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+var argFlags = map[string]bool{
+	"-D": true, "-U": true, "-I": true, "-F": true, "-x": true,
+	"-include": true, "-imacros": true, "-isystem": true, "-imsvc": true, "-idirafter": true,
+	"-iquote": true, "-isysroot": true, "-iprefix": true, "-target": true,
+	"-Xclang": true,
+}
+
+// flagGroups splits flags into options together with their separate argument.
+func flagGroups(flags []string) [][]string {
+	var ret [][]string
+	for i := 0; i < len(flags); i++ {
+		if argFlags[flags[i]] && i+1 < len(flags) {
+			ret = append(ret, flags[i:i+2])
+			i++
+			continue
+		}
+		ret = append(ret, flags[i:i+1])
+	}
+	return ret
+}
+
+func dedupFlags(flags []string) []string {
+	var ret []string
+	seen := make(map[string]bool)
+	for _, g := range flagGroups(flags) {
+		key := strings.Join(g, " ")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ret = append(ret, g...)
+	}
+	return ret
+}
+
+type format struct {
+	name   string
+	output string
+	write  func(w io.Writer, fs *flagSet) error
+	read   func(r io.Reader, base string) (*flagSet, error)
+	// formats without comments keep metadata in a side file
+	comments bool
+}
+
+var formats = map[string]*format{
+	"clang_complete": {"clang_complete", ".clang_complete", writeClangComplete, readClangComplete, true},
+	"compile_flags":  {"compile_flags", "compile_flags.txt", writeCompileFlags, readCompileFlags, false},
+	"compdb":         {"compdb", "compile_commands.json", writeCompdb, readCompdb, false},
+	"clangd":         {"clangd", ".clangd", writeClangd, readClangd, true},
+	"make":           {"make", "flags.mk", writeMake, readMake, true},
+}
+
+func lookupFormat(name string) (*format, error) {
+	f, ok := formats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q", name)
+	}
+	return f, nil
+}
+
+// formatOf guesses the format of an output file from its name.
+func formatOf(name string) *format {
+	base := filepath.Base(name)
+	for _, f := range formats {
+		if base == f.output {
+			return f
+		}
+	}
+	switch filepath.Ext(base) {
+	case ".json":
+		return formats["compdb"]
+	case ".yaml", ".yml":
+		return formats["clangd"]
+	case ".txt":
+		return formats["compile_flags"]
+	case ".mk":
+		return formats["make"]
+	}
+	return formats["clang_complete"]
+}
+
+func readFlagSet(name string, f *format) (*flagSet, error) {
+	if f == nil {
+		f = formatOf(name)
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	base, err := filepath.Abs(filepath.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	fs, err := f.read(file, base)
+	if err != nil {
+		return nil, err
+	}
+	fs.translate()
+	if !f.comments {
+		if m, err := loadMeta(metaFile(name)); err == nil {
+			fs.Meta = m
+		}
+	}
+	if buf, err := ioutil.ReadFile(usesFile(name)); err == nil {
+		json.Unmarshal(buf, &fs.Uses)
+	}
+	return fs, nil
+}
+
+// writeFlagSet writes fs to name together with its side files, see outputTx.
+func writeFlagSet(name string, f *format, fs *flagSet) error {
+	dir, err := filepath.Abs(filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+	if err := checkRedact(f); err != nil {
+		return err
+	}
+	fs = fs.redacted(f, dir)
+	if len(fs.FileFlags) != 0 && f.name != "compdb" {
+		fmt.Fprintf(os.Stderr, "%s has no per-file flags: the flags %d sources get from %s files or a language retry are left out, use -format compdb to keep them\n", f.output, len(fs.FileFlags), dirRulesFile)
+	}
+	if name == "-" {
+		return f.write(os.Stdout, fs)
+	}
+	tx, err := newOutputTx(name)
+	if err != nil {
+		return err
+	}
+	err = tx.writeFlagSet(name, f, fs)
+	if err == nil {
+		err = writeLangFiles(tx, name, f, fs)
+	}
+	if err != nil {
+		tx.Abort()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (tx *outputTx) writeFlagSet(name string, f *format, fs *flagSet) error {
+	err := tx.write(name, func(w io.Writer) error {
+		return f.write(w, fs)
+	})
+	if err == nil && fs.Meta != nil && !f.comments {
+		err = tx.write(metaFile(name), func(w io.Writer) error {
+			fs.Meta.writeComments(w)
+			return nil
+		})
+	}
+	if err == nil && fs.Uses != nil {
+		err = tx.write(usesFile(name), func(w io.Writer) error {
+			return json.NewEncoder(w).Encode(fs.Uses)
+		})
+	} else {
+		tx.Remove(usesFile(name))
+	}
+	return err
+}
+
+func writeClangComplete(w io.Writer, fs *flagSet) error {
+	fs = fs.flatten()
+	bw := bufio.NewWriter(w)
+	if fs.Meta != nil {
+		fs.Meta.writeComments(bw)
+	}
+	for _, dir := range fs.Includes {
+		fmt.Fprintln(bw, "-I"+dir)
+	}
+	for _, dir := range fs.Systems {
+		fmt.Fprintln(bw, systemFlag()+" "+dir)
+	}
+	for _, dir := range fs.After {
+		fmt.Fprintln(bw, "-idirafter "+dir)
+	}
+	for _, g := range flagGroups(fs.Flags) {
+		fmt.Fprintln(bw, strings.Join(g, " "))
+	}
+	return bw.Flush()
+}
+
+func readClangComplete(r io.Reader, base string) (*flagSet, error) {
+	var args []string
+	meta := new(metadata)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || meta.parseComment(line) {
+			continue
+		}
+		args = append(args, splitQuoted(line)...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	fs := new(flagSet)
+	fs.addArgs(args, base)
+	fs.setMeta(meta)
+	return fs, nil
+}
+
+func (fs *flagSet) setMeta(m *metadata) {
+	if *m != (metadata{}) {
+		fs.Meta = m
+	}
+}
+
+func writeCompileFlags(w io.Writer, fs *flagSet) error {
+	fs = fs.flatten()
+	bw := bufio.NewWriter(w)
+	for _, arg := range fs.Args() {
+		fmt.Fprintln(bw, arg)
+	}
+	return bw.Flush()
+}
+
+func readCompileFlags(r io.Reader, base string) (*flagSet, error) {
+	var args []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			args = append(args, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	fs := new(flagSet)
+	fs.addArgs(args, base)
+	return fs, nil
+}
+
+type compileCommand struct {
+	Directory string   `json:"directory"`
+	File      string   `json:"file"`
+	Arguments []string `json:"arguments,omitempty"`
+	Command   string   `json:"command,omitempty"`
+}
+
+func writeCompdb(w io.Writer, fs *flagSet) error {
+	cmds := []compileCommand{}
+	for _, file := range fs.Files {
+		args := append([]string{compiler()}, fs.ArgsFor(file)...)
+		cmds = append(cmds, compdbEntry(file, append(args, "-c", file)))
+	}
+	buf, err := json.MarshalIndent(cmds, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(buf, '\n'))
+	return err
+}
+
+func readCompdb(r io.Reader, base string) (*flagSet, error) {
+	var cmds []compileCommand
+	err := json.NewDecoder(r).Decode(&cmds)
+	if err != nil {
+		return nil, err
+	}
+	return commandsFlagSet(cmds, base), nil
+}
+
+// commandsFlagSet merges the flags of compile commands, relative directories
+// are resolved against base.
+func commandsFlagSet(cmds []compileCommand, base string) *flagSet {
+	fs := new(flagSet)
+	for _, cmd := range cmds {
+		args := cmd.Arguments
+		if len(args) == 0 {
+			args = splitQuoted(cmd.Command)
+		}
+		dir := cmd.Directory
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(base, dir)
+		}
+		file := cmd.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		args = compileArgs(args, cmd.File)
+		one := new(flagSet)
+		if isCuda(file) {
+			var cuda []string
+			args, cuda = splitCuda(args)
+			one.addLang("cuda", cuda)
+		}
+		if isAsm(file) {
+			var asm []string
+			args, asm = splitKnown(args, asmFlags)
+			one.addLang("assembler-with-cpp", asm)
+		}
+		one.addArgs(args, dir)
+		one.Files = []string{filepath.Clean(file)}
+		one.splitLangs()
+		fs.merge(one)
+	}
+	fs.foldLangs()
+	return fs
+}
+
+// compileArgs strips the compiler, the source file and the options unrelated to
+// preprocessing from a compile command.
+func compileArgs(args []string, file string) []string {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		args = args[1:]
+	}
+	var ret []string
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == "-c" || a == file:
+		case a == "-o" || a == "-MF" || a == "-MT" || a == "-MQ":
+			i++
+		case strings.HasPrefix(a, "-o") || a == "-MD" || a == "-MMD":
+		default:
+			ret = append(ret, a)
+		}
+	}
+	return ret
+}
+
+func writeClangd(w io.Writer, fs *flagSet) error {
+	bw := bufio.NewWriter(w)
+	if fs.Meta != nil {
+		fs.Meta.writeComments(bw)
+	}
+	fmt.Fprintln(bw, "CompileFlags:")
+	fmt.Fprintln(bw, "  Add:")
+	for _, arg := range fs.Args() {
+		fmt.Fprintf(bw, "    - %s\n", yamlQuote(arg))
+	}
+	fragment := func(match string, args []string) {
+		fmt.Fprintln(bw, "---")
+		fmt.Fprintln(bw, "If:")
+		fmt.Fprintf(bw, "  PathMatch: %s\n", yamlQuote(match))
+		fmt.Fprintln(bw, "CompileFlags:")
+		fmt.Fprintln(bw, "  Add:")
+		for _, arg := range args {
+			fmt.Fprintf(bw, "    - %s\n", yamlQuote(arg))
+		}
+	}
+	for _, lang := range fs.Langs() {
+		fragment(langPathMatch[lang], fs.Lang[lang])
+	}
+	if len(fs.Test) != 0 {
+		var args []string
+		for _, dir := range fs.Test {
+			args = append(args, "-I"+dir)
+		}
+		fragment(testPathMatch(), args)
+	}
+	return bw.Flush()
+}
+
+// testPathMatch is -test-pattern as a clangd PathMatch, which must match the
+// whole path.
+func testPathMatch() string {
+	return ".*(" + *testPattern + ").*"
+}
+
+func yamlQuote(s string) string {
+	if s == "" || strings.ContainsAny(s, ":#{}[],&*!|>'\"%@`") || s[0] == '-' && len(s) == 1 {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
+
+// readClangd understands the subset of yaml written by writeClangd, plus the
+// inline "Add: [a, b]" form.
+func readClangd(r io.Reader, base string) (*flagSet, error) {
+	var args []string
+	var inAdd bool
+	langs := make(map[string]string)
+	for lang, match := range langPathMatch {
+		langs[match] = lang
+	}
+	langs[testPathMatch()] = "test"
+	// 只认识 writeClangd 写出的语言片段，其余片段忽略
+	section := &args
+	sections := make(map[string]*[]string)
+	meta := new(metadata)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || meta.parseComment(line):
+		case line == "---":
+			inAdd = false
+			section = nil
+		case strings.HasPrefix(line, "PathMatch:"):
+			lang, ok := langs[yamlUnquote(strings.TrimSpace(line[len("PathMatch:"):]))]
+			if section == nil && ok {
+				section = new([]string)
+				sections[lang] = section
+			}
+		case section == nil:
+		case strings.HasPrefix(line, "Add:"):
+			rest := strings.TrimSpace(line[len("Add:"):])
+			inAdd = rest == ""
+			if strings.HasPrefix(rest, "[") {
+				for _, s := range strings.Split(strings.Trim(rest, "[]"), ",") {
+					*section = append(*section, yamlUnquote(strings.TrimSpace(s)))
+				}
+			}
+		case inAdd && strings.HasPrefix(line, "- "):
+			*section = append(*section, yamlUnquote(strings.TrimSpace(line[2:])))
+		default:
+			inAdd = false
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	fs := new(flagSet)
+	fs.addArgs(args, base)
+	for lang, flags := range sections {
+		if lang == "test" {
+			test := new(flagSet)
+			test.addArgs(*flags, base)
+			fs.Test = test.Includes
+			continue
+		}
+		fs.addLang(lang, *flags)
+	}
+	fs.setMeta(meta)
+	return fs, nil
+}
+
+func yamlUnquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		if s[0] == '"' {
+			var ret string
+			if err := json.Unmarshal([]byte(s), &ret); err == nil {
+				return ret
+			}
+		}
+		return s[1 : len(s)-1]
+	}
+	return s
+}
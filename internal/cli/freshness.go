@@ -0,0 +1,351 @@
+package cli
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// overlay is a subtree scanned again after the index was built. It hides
+// the entries of the index under its dir.
+type overlay struct {
+	// root is the search root the dir belongs to
+	root string
+	node *node
+}
+
+// hidden reports whether file, found in the root or overlay at from, is
+// under an overlay scanned later. from is empty for the loaded indexes.
+func (t *tree) hidden(file, from string) bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.hiddenLocked(file, from)
+}
+
+func (t *tree) hiddenLocked(file, from string) bool {
+	for dir := range t.overlays {
+		if dir == from || (from != "" && !underAny(dir, []string{from})) {
+			continue
+		}
+		if underAny(file, []string{dir}) {
+			return true
+		}
+	}
+	return false
+}
+
+// ownerOf returns the innermost root of the index containing dir.
+func (t *tree) ownerOf(dir string) (string, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	var paths []string
+	for p := range t.roots {
+		paths = append(paths, p)
+	}
+	for _, idx := range t.flats {
+		paths = append(paths, idx.roots.Paths()...)
+	}
+	best := ""
+	for _, p := range paths {
+		if underAny(dir, []string{p}) && len(p) > len(best) {
+			best = p
+		}
+	}
+	return best, best != ""
+}
+
+// Rescan indexes dir again, replacing what the index had under it. A dir
+// removed since leaves no entries.
+func (t *tree) Rescan(dir string, acceptext map[string]bool) error {
+	owner, ok := t.ownerOf(dir)
+	if !ok {
+		return fmt.Errorf("%s is not under a search root", dir)
+	}
+	t.lock.RLock()
+	_, scanned := t.roots[dir]
+	t.lock.RUnlock()
+	if scanned {
+		if err := t.Scan(dir, acceptext); err != nil {
+			return err
+		}
+		t.dropOverlays(dir)
+		return nil
+	}
+
+	depth := 0
+	if rel, err := filepath.Rel(owner, dir); err == nil && rel != "." {
+		depth = strings.Count(rel, string(filepath.Separator)) + 1
+	}
+	top := newNode("", "")
+	info, err := os.Lstat(dir)
+	switch {
+	case err == nil:
+		n, err := t.buildtree(dir, info.Mode(), depth, top, acceptext)
+		if err != nil && err != errSkip {
+			return err
+		}
+		// 补上到根目录为止的上级目录，带目录前缀的头文件才能找到
+		for p := filepath.Dir(dir); n != nil && underAny(p, []string{owner}); p = filepath.Dir(p) {
+			parent := newNode(filepath.Base(p), filepath.Dir(p))
+			n.AddChild(parent)
+			n = parent
+			if p == owner {
+				break
+			}
+		}
+	case !os.IsNotExist(err):
+		return t.scanError(err)
+	}
+	top.Sort()
+	t.dropOverlays(dir)
+	t.lock.Lock()
+	t.overlays[dir] = overlay{owner, top}
+	t.lock.Unlock()
+	return nil
+}
+
+// dropOverlays forgets the overlays under dir, which was scanned again.
+func (t *tree) dropOverlays(dir string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for p := range t.overlays {
+		if underAny(p, []string{dir}) {
+			delete(t.overlays, p)
+		}
+	}
+}
+
+// dirState is the listing of a dir as the index sees it.
+type dirState struct {
+	mtime time.Time
+	hash  string
+	dirs  []string
+}
+
+// readDirState lists dir, skipping the entries the index skips.
+func readDirState(dir string, mtime time.Time) (dirState, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return dirState{}, err
+	}
+	defer f.Close()
+	var names, dirs []string
+	for {
+		files, err := f.ReadDir(readDirBatch)
+		for _, file := range files {
+			name := file.Name()
+			if name[0] == '.' || isExcluded(name) {
+				continue
+			}
+			if file.IsDir() {
+				dirs = append(dirs, name)
+				name += "/"
+			}
+			names = append(names, name)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return dirState{}, err
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(dirs)
+	h := sha1.New()
+	for _, name := range names {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+	}
+	// 刚修改过的目录可能在同一时间戳内再变，下次重新读取
+	if time.Since(mtime) < 2*time.Second {
+		mtime = time.Time{}
+	}
+	return dirState{mtime, hex.EncodeToString(h.Sum(nil)), dirs}, nil
+}
+
+// fingerprint lists the dirs under root, reading again only the dirs whose
+// mtime differs from prev.
+func fingerprint(root string, prev map[string]dirState) map[string]dirState {
+	cur := make(map[string]dirState)
+	var walk func(dir string)
+	walk = func(dir string) {
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			return
+		}
+		st, ok := prev[dir]
+		if !ok || st.mtime.IsZero() || !st.mtime.Equal(info.ModTime()) {
+			st, err = readDirState(dir, info.ModTime())
+			if err != nil {
+				return
+			}
+		}
+		cur[dir] = st
+		for _, name := range st.dirs {
+			walk(filepath.Join(dir, name))
+		}
+	}
+	walk(root)
+	return cur
+}
+
+// listingHash hashes the listings of dirs, the content hash of a root.
+func listingHash(dirs map[string]dirState) string {
+	var paths []string
+	for p := range dirs {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	h := sha1.New()
+	for _, p := range paths {
+		fmt.Fprintf(h, "%s\x00%s\x00", p, dirs[p].hash)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// changedDirs returns the topmost dirs under root whose listing changed
+// between prev and cur.
+func changedDirs(root string, prev, cur map[string]dirState) []string {
+	changed := make(map[string]bool)
+	for dir, st := range cur {
+		if p, ok := prev[dir]; !ok || p.hash != st.hash {
+			changed[dir] = true
+		}
+	}
+	// 删除的目录由仍然存在的上级目录重新扫描
+	for dir := range prev {
+		if _, ok := cur[dir]; ok {
+			continue
+		}
+		for dir != root {
+			dir = filepath.Dir(dir)
+			if _, ok := cur[dir]; ok {
+				break
+			}
+		}
+		changed[dir] = true
+	}
+	var dirs []string
+	for dir := range changed {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	var ret []string
+	for _, dir := range dirs {
+		if !underAny(dir, ret) {
+			ret = append(ret, dir)
+		}
+	}
+	return ret
+}
+
+// rootInfo is the freshness of a search root in the daemon.
+type rootInfo struct {
+	Path string
+	// Built is when the index of the root was built or last updated
+	Built time.Time
+	// Hash is the content hash of the root, empty until it is validated
+	Hash      string
+	Network   bool
+	Validated bool
+	dirs      map[string]dirState
+}
+
+type freshness struct {
+	lock  sync.Mutex
+	roots []*rootInfo
+}
+
+// Roots returns a copy of the freshness of the roots.
+func (f *freshness) Roots() []rootInfo {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var ret []rootInfo
+	for _, info := range f.roots {
+		ret = append(ret, *info)
+	}
+	return ret
+}
+
+// revalidate lists the roots nothing tells the daemon about every interval,
+// the roots on network filesystems unless all is set, and indexes again the
+// dirs that changed.
+func (s *server) revalidate(ctx context.Context, interval time.Duration, all bool) {
+	if err := s.r.index(); err != nil {
+		return
+	}
+	built := time.Now()
+	var roots []*rootInfo
+	for _, root := range s.r.roots.Paths() {
+		p, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		info := &rootInfo{Path: p, Built: built, Network: networkFS(p)}
+		info.Validated = interval > 0 && (all || info.Network)
+		if info.Validated {
+			info.dirs = fingerprint(p, nil)
+			info.Hash = listingHash(info.dirs)
+		}
+		roots = append(roots, info)
+	}
+	s.fresh.lock.Lock()
+	s.fresh.roots = roots
+	s.fresh.lock.Unlock()
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, info := range roots {
+			if ctx.Err() != nil {
+				return
+			}
+			if info.Validated {
+				s.revalidateRoot(info)
+			}
+		}
+	}
+}
+
+func (s *server) revalidateRoot(info *rootInfo) {
+	s.fresh.lock.Lock()
+	prev := info.dirs
+	s.fresh.lock.Unlock()
+	cur := fingerprint(info.Path, prev)
+	changed := changedDirs(info.Path, prev, cur)
+	for _, dir := range changed {
+		log.Debug("reindex %s", dir)
+		if err := s.r.t.Rescan(dir, s.r.headerext); err != nil {
+			fmt.Fprintf(os.Stderr, "reindex %s: %s\n", dir, err)
+		}
+	}
+	s.fresh.lock.Lock()
+	info.dirs = cur
+	info.Hash = listingHash(cur)
+	s.r.SetListing(info.Path, info.Hash)
+	if len(changed) != 0 {
+		info.Built = time.Now()
+	}
+	s.fresh.lock.Unlock()
+	if len(changed) != 0 {
+		fmt.Fprintf(os.Stderr, "%s: reindexed %d changed dirs\n", info.Path, len(changed))
+		s.r.Invalidate()
+	}
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var gnOut = cmdline.String("gn", "", "merge include dirs, defines and cflags of the targets of a gn out dir")
+
+type gnTarget struct {
+	IncludeDirs []string `json:"include_dirs"`
+	Defines     []string `json:"defines"`
+	Cflags      []string `json:"cflags"`
+	CflagsC     []string `json:"cflags_c"`
+	CflagsCC    []string `json:"cflags_cc"`
+	Sources     []string `json:"sources"`
+}
+
+// gnRoot finds the source root of outdir, the dir of the .gn file above it.
+func gnRoot(outdir string) (string, error) {
+	for dir := outdir; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".gn")); err == nil {
+			return dir, nil
+		}
+		if filepath.Dir(dir) == dir {
+			return "", errors.New("no .gn file above " + outdir)
+		}
+	}
+}
+
+// gnFlags returns the include dirs and preprocessor flags used by the targets
+// of outdir, and the sources they cover.
+func gnFlags(outdir string) (dirs []string, flags []string, sources []string, err error) {
+	outdir, err = filepath.Abs(outdir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	root, err := gnRoot(outdir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	cmd := exec.Command("gn", "desc", outdir, "//*", "--format=json")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var targets map[string]gnTarget
+	if err := json.Unmarshal(out, &targets); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// "//" 开头的路径相对于源码根目录
+	abs := func(p string) string {
+		if strings.HasPrefix(p, "//") {
+			return filepath.Join(root, filepath.FromSlash(p[2:]))
+		}
+		return filepath.Clean(p)
+	}
+	var args []string
+	for _, t := range targets {
+		for _, dir := range t.IncludeDirs {
+			args = append(args, "-I"+abs(dir))
+		}
+		for _, d := range t.Defines {
+			args = append(args, "-D"+d)
+		}
+		args = append(args, t.Cflags...)
+		// C 和 C++ 各自的 -std 会冲突，只取其他参数
+		for _, f := range append(t.CflagsC, t.CflagsCC...) {
+			if !strings.HasPrefix(f, "-std=") {
+				args = append(args, f)
+			}
+		}
+		for _, src := range t.Sources {
+			sources = append(sources, abs(src))
+		}
+	}
+
+	// cflags 里的相对路径相对于 out 目录
+	dirs, flags = splitIncludes(args, outdir)
+	return dedup(dirs), dedupFlags(flags), sources, nil
+}
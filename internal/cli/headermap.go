@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// headerMap makes headers included as from/x.h resolve to to/x.h.
+type headerMap struct {
+	from, to string
+}
+
+type headerMapSlice []headerMap
+
+var headerMaps headerMapSlice
+
+func (s *headerMapSlice) String() string {
+	var l []string
+	for _, m := range *s {
+		l = append(l, m.from+"/="+m.to+"/")
+	}
+	return fmt.Sprintf("%q", l)
+}
+
+func (s *headerMapSlice) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i <= 0 || i == len(value)-1 {
+		return fmt.Errorf("bad header map %q, want prefix/=dir/", value)
+	}
+	to, err := filepath.Abs(value[i+1:])
+	if err != nil {
+		return err
+	}
+	from := filepath.Clean(strings.Trim(value[:i], "/"))
+	*s = append(*s, headerMap{from, to})
+	return nil
+}
+
+// mapStage is a dir of symlinks named after the mapped prefixes, so both the
+// compiler and the editor find the mapped headers through a plain -I.
+var mapStage struct {
+	sync.Mutex
+	dir string
+}
+
+// stageDir returns the dir name, kept for srcroot in the user cache dir so
+// nothing is written to the source tree.
+func stageDir(srcroot, name string) string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	if abs, err := filepath.Abs(srcroot); err == nil {
+		srcroot = abs
+	}
+	sum := sha1.Sum([]byte(srcroot))
+	return filepath.Join(base, "clang_complete", hex.EncodeToString(sum[:8]), name)
+}
+
+// realDir makes p, under the stage dir, a dir of its own. A link to a mapped
+// dir there is replaced by a dir of links to its entries, so a nested prefix
+// is added without writing to the mapped dir.
+func realDir(stage, p string) error {
+	info, err := os.Lstat(p)
+	switch {
+	case err == nil && info.Mode()&os.ModeSymlink == 0:
+		return nil
+	case err == nil:
+		target, err := os.Readlink(p)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		if err := os.Mkdir(p, 0755); err != nil {
+			return err
+		}
+		files, _ := ioutil.ReadDir(target)
+		for _, f := range files {
+			if err := os.Symlink(filepath.Join(target, f.Name()), filepath.Join(p, f.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	case !os.IsNotExist(err):
+		return err
+	}
+	if p == stage {
+		return os.MkdirAll(p, 0755)
+	}
+	if err := realDir(stage, filepath.Dir(p)); err != nil {
+		return err
+	}
+	return os.Mkdir(p, 0755)
+}
+
+// stageHeaderMaps creates the symlinks of the header maps in the stage dir
+// of srcroot.
+func stageHeaderMaps(srcroot string) error {
+	if len(headerMaps) == 0 {
+		return nil
+	}
+	dir := stageDir(srcroot, "map")
+	err := os.RemoveAll(dir)
+	if err != nil {
+		return err
+	}
+	// 短的前缀先建立，嵌套的前缀再把它的链接换成目录
+	maps := append(headerMapSlice{}, headerMaps...)
+	sort.SliceStable(maps, func(i, j int) bool { return len(maps[i].from) < len(maps[j].from) })
+	for _, m := range maps {
+		link := filepath.Join(dir, m.from)
+		err = realDir(dir, filepath.Dir(link))
+		if err != nil {
+			return err
+		}
+		err = os.RemoveAll(link)
+		if err != nil {
+			return err
+		}
+		err = os.Symlink(m.to, link)
+		if err != nil {
+			return err
+		}
+		sandboxAllow(m.to)
+	}
+	sandboxAllow(dir)
+	mapStage.Lock()
+	mapStage.dir = dir
+	mapStage.Unlock()
+	return nil
+}
+
+// mapHeader returns the stage dir if header resolves through a header map.
+func mapHeader(header string) (string, bool) {
+	mapStage.Lock()
+	dir := mapStage.dir
+	mapStage.Unlock()
+	if dir == "" {
+		return "", false
+	}
+
+	var best *headerMap
+	for i, m := range headerMaps {
+		if !strings.HasPrefix(header, m.from+"/") {
+			continue
+		}
+		if best == nil || len(m.from) > len(best.from) {
+			best = &headerMaps[i]
+		}
+	}
+	if best == nil {
+		return "", false
+	}
+	if _, err := os.Stat(filepath.Join(best.to, header[len(best.from)+1:])); err != nil {
+		return "", false
+	}
+	return dir, true
+}
@@ -0,0 +1,307 @@
+package cli
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"sort"
+	"time"
+)
+
+var htmlFile = cmdline.String("html", "", "write a self-contained HTML report of the header usage by dir, the unresolved includes and the headers of each source to this file")
+
+type htmlDir struct {
+	Path    string `json:"path"`
+	Uses    int    `json:"uses"`
+	Headers int    `json:"headers"`
+	Sources int    `json:"sources"`
+}
+
+type htmlInclude struct {
+	Header string   `json:"header"`
+	Dirs   []string `json:"dirs"`
+}
+
+type htmlFileEntry struct {
+	Path     string        `json:"path"`
+	Count    int           `json:"count"`
+	Depth    int           `json:"depth"`
+	Includes []htmlInclude `json:"includes"`
+}
+
+type htmlUnresolved struct {
+	Header string   `json:"header"`
+	Sites  []string `json:"sites"`
+}
+
+type htmlData struct {
+	Generated  string           `json:"generated"`
+	Dirs       []htmlDir        `json:"dirs"`
+	Files      []htmlFileEntry  `json:"files"`
+	Unresolved []htmlUnresolved `json:"unresolved"`
+}
+
+// htmlReport collects the statistics and the unresolved headers of the run.
+func htmlReport() htmlData {
+	data := htmlData{Generated: time.Now().Format(time.RFC1123)}
+
+	stats.lock.Lock()
+	for dir, uses := range stats.dirs {
+		data.Dirs = append(data.Dirs, htmlDir{
+			Path:    mapPath(dir),
+			Uses:    uses,
+			Headers: len(stats.dirHdrs[dir]),
+			Sources: len(stats.dirSrcs[dir]),
+		})
+	}
+	for src, headers := range stats.srcHdrs {
+		f := htmlFileEntry{Path: mapPath(src), Count: stats.counts[src], Depth: stats.depths[src]}
+		for h, dirs := range headers {
+			inc := htmlInclude{Header: h}
+			for _, dir := range dirs {
+				inc.Dirs = append(inc.Dirs, mapPath(dir))
+			}
+			f.Includes = append(f.Includes, inc)
+		}
+		sort.Slice(f.Includes, func(i, j int) bool { return f.Includes[i].Header < f.Includes[j].Header })
+		data.Files = append(data.Files, f)
+	}
+	stats.lock.Unlock()
+	sort.Slice(data.Dirs, func(i, j int) bool { return data.Dirs[i].Path < data.Dirs[j].Path })
+	sort.Slice(data.Files, func(i, j int) bool { return data.Files[i].Path < data.Files[j].Path })
+
+	rep.lock.Lock()
+	for h, sites := range rep.unresolved {
+		u := htmlUnresolved{Header: h}
+		for _, site := range sites {
+			u.Sites = append(u.Sites, site.String())
+		}
+		sort.Strings(u.Sites)
+		data.Unresolved = append(data.Unresolved, u)
+	}
+	rep.lock.Unlock()
+	// 影响源码最多的排在前面，和报告一致
+	sort.Slice(data.Unresolved, func(i, j int) bool {
+		a, b := len(data.Unresolved[i].Sites), len(data.Unresolved[j].Sites)
+		return a > b || a == b && data.Unresolved[i].Header < data.Unresolved[j].Header
+	})
+	return data
+}
+
+// writeHTML writes the report of the run to name as one page with no
+// outside resources.
+func writeHTML(name string) error {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, htmlReport()); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, buf.Bytes(), 0644)
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>clang_complete header usage</title>
+<style>
+body { font: 14px sans-serif; margin: 1em 2em; color: #222; }
+h2 { margin-top: 1.5em; }
+#crumbs a { cursor: pointer; color: #06c; }
+#map { position: relative; height: 480px; border: 1px solid #999; }
+#map div { position: absolute; box-sizing: border-box; border: 1px solid #fff; overflow: hidden; font-size: 12px; padding: 2px; cursor: pointer; }
+#filter { width: 30em; }
+summary { cursor: pointer; }
+.n { color: #777; }
+code { font-size: 13px; }
+</style>
+</head>
+<body>
+<h1>Header usage</h1>
+<p class="n">Generated {{.Generated}}</p>
+<h2>Include dirs by use</h2>
+<p id="crumbs"></p>
+<div id="map"></div>
+<h2>Unresolved includes (<span id="nunresolved"></span>)</h2>
+<div id="unresolved"></div>
+<h2>Sources (<span id="nfiles"></span>)</h2>
+<p><input id="filter" placeholder="filter by path or header"></p>
+<div id="files"></div>
+<script>
+var data = {{.}};
+
+function el(tag, text, cls) {
+	var e = document.createElement(tag);
+	if (text !== undefined) e.textContent = text;
+	if (cls) e.className = cls;
+	return e;
+}
+
+// 目录按路径组成树，节点的权重是其下所有 include 目录被使用的次数
+function buildTree(dirs) {
+	var root = {name: "", path: "", uses: 0, children: {}, dir: null};
+	dirs.forEach(function(d) {
+		var n = root;
+		n.uses += d.uses;
+		d.path.split("/").forEach(function(part, i, parts) {
+			if (part === "" && i === 0) return;
+			if (!n.children[part]) {
+				n.children[part] = {name: part, path: parts.slice(0, i + 1).join("/"), uses: 0, children: {}, dir: null};
+			}
+			n = n.children[part];
+			n.uses += d.uses;
+		});
+		n.dir = d;
+	});
+	// 只有一个子节点的目录合并到子节点
+	function collapse(n) {
+		var keys = Object.keys(n.children);
+		while (keys.length === 1 && !n.dir && n !== root) {
+			var c = n.children[keys[0]];
+			n.name += "/" + c.name;
+			n.path = c.path;
+			n.children = c.children;
+			n.dir = c.dir;
+			keys = Object.keys(n.children);
+		}
+		keys.forEach(function(k) { collapse(n.children[k]); });
+	}
+	collapse(root);
+	// 所有目录的公共前缀不单独占一层
+	while (Object.keys(root.children).length === 1 && !root.dir) {
+		root = root.children[Object.keys(root.children)[0]];
+	}
+	return root;
+}
+
+var tree = buildTree(data.dirs || []);
+var maxUses = 1;
+(data.dirs || []).forEach(function(d) { if (d.uses > maxUses) maxUses = d.uses; });
+
+function heat(uses) {
+	var t = Math.min(1, uses / maxUses);
+	return "hsl(" + Math.round(210 - 210 * t) + ",70%," + Math.round(80 - 30 * t) + "%)";
+}
+
+// 按权重交替横竖切分
+function layout(nodes, x, y, w, h, vertical, out) {
+	var total = 0;
+	nodes.forEach(function(n) { total += n.uses; });
+	var off = 0;
+	nodes.forEach(function(n) {
+		var share = total ? n.uses / total : 0;
+		if (vertical) {
+			out.push({n: n, x: x, y: y + off, w: w, h: h * share});
+			off += h * share;
+		} else {
+			out.push({n: n, x: x + off, y: y, w: w * share, h: h});
+			off += w * share;
+		}
+	});
+}
+
+function children(n) {
+	var l = Object.keys(n.children).map(function(k) { return n.children[k]; });
+	if (n.dir && l.length) {
+		l.push({name: ".", path: n.path, uses: n.dir.uses, children: {}, dir: n.dir, self: true});
+	}
+	return l.sort(function(a, b) { return b.uses - a.uses; });
+}
+
+var stack = [tree];
+
+function draw() {
+	var cur = stack[stack.length - 1];
+	var crumbs = document.getElementById("crumbs");
+	crumbs.textContent = "";
+	stack.forEach(function(n, i) {
+		var a = el("a", i === 0 ? n.path || "/" : n.name);
+		a.onclick = function() { stack = stack.slice(0, i + 1); draw(); };
+		crumbs.appendChild(a);
+		if (i < stack.length - 1) crumbs.appendChild(document.createTextNode(" / "));
+	});
+	var map = document.getElementById("map");
+	map.textContent = "";
+	var out = [];
+	var l = children(cur);
+	if (!l.length && cur.dir) l = [cur];
+	layout(l, 0, 0, map.clientWidth, map.clientHeight, map.clientWidth < map.clientHeight, out);
+	out.forEach(function(r) {
+		var d = el("div", r.n.name + " (" + r.n.uses + ")");
+		d.style.left = r.x + "px";
+		d.style.top = r.y + "px";
+		d.style.width = r.w + "px";
+		d.style.height = r.h + "px";
+		d.style.background = heat(r.n.uses);
+		var title = r.n.path + "\n" + r.n.uses + " includes resolved";
+		if (r.n.dir) title += "\n" + r.n.dir.headers + " distinct headers, " + r.n.dir.sources + " sources";
+		d.title = title;
+		d.onclick = function() {
+			if (Object.keys(r.n.children).length && !r.n.self) {
+				stack.push(r.n);
+				draw();
+			} else {
+				document.getElementById("filter").value = r.n.path;
+				drawFiles();
+			}
+		};
+		map.appendChild(d);
+	});
+}
+
+function drawUnresolved() {
+	var l = data.unresolved || [];
+	document.getElementById("nunresolved").textContent = l.length;
+	var div = document.getElementById("unresolved");
+	l.forEach(function(u) {
+		var det = el("details");
+		det.appendChild(el("summary", u.header + ", included " + u.sites.length + " times"));
+		var ul = el("ul");
+		u.sites.forEach(function(s) { ul.appendChild(el("li", s)); });
+		det.appendChild(ul);
+		div.appendChild(det);
+	});
+}
+
+function drawFiles() {
+	var q = document.getElementById("filter").value;
+	var div = document.getElementById("files");
+	div.textContent = "";
+	var shown = 0;
+	(data.files || []).forEach(function(f) {
+		var match = !q || f.path.indexOf(q) >= 0 || f.includes.some(function(inc) {
+			return inc.header.indexOf(q) >= 0 || inc.dirs.some(function(d) { return d.indexOf(q) >= 0; });
+		});
+		if (!match) return;
+		shown++;
+		var det = el("details");
+		var sum = el("summary", f.path + " ");
+		var info = f.includes.length + " includes resolved";
+		if (f.count) info += ", " + f.count + " headers";
+		if (f.depth) info += ", depth " + f.depth;
+		sum.appendChild(el("span", info, "n"));
+		det.appendChild(sum);
+		det.addEventListener("toggle", function() {
+			if (!det.open || det.childNodes.length > 1) return;
+			var ul = el("ul");
+			f.includes.forEach(function(inc) {
+				var li = el("li");
+				li.appendChild(el("code", inc.header));
+				li.appendChild(el("span", " from " + inc.dirs.join(" "), "n"));
+				ul.appendChild(li);
+			});
+			det.appendChild(ul);
+		});
+		div.appendChild(det);
+	});
+	document.getElementById("nfiles").textContent = shown;
+}
+
+document.getElementById("filter").oninput = drawFiles;
+window.onresize = draw;
+draw();
+drawUnresolved();
+drawFiles();
+</script>
+</body>
+</html>
+`))
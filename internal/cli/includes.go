@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"strings"
+	"unicode/utf16"
+)
+
+// include is an #include directive found without the compiler.
+type include struct {
+	Name   string
+	Angled bool
+	// the directive names a macro, as in #include HEADER
+	Macro bool
+	Line  int
+}
+
+// decodeSource returns the source as UTF-8, dropping a byte order mark and
+// converting UTF-16, which some Windows tools write.
+func decodeSource(data []byte) []byte {
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(data, []byte{0xef, 0xbb, 0xbf}):
+		return data[3:]
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		order, data = binary.LittleEndian, data[2:]
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		order, data = binary.BigEndian, data[2:]
+	case len(data) >= 4 && data[0] != 0 && data[1] == 0 && data[2] != 0 && data[3] == 0:
+		order = binary.LittleEndian
+	case len(data) >= 4 && data[0] == 0 && data[1] != 0 && data[2] == 0 && data[3] != 0:
+		order = binary.BigEndian
+	default:
+		return data
+	}
+	u := make([]uint16, len(data)/2)
+	for i := range u {
+		u[i] = order.Uint16(data[2*i:])
+	}
+	return []byte(string(utf16.Decode(u)))
+}
+
+// stripSource splices continued lines and blanks out comments, keeping the
+// line structure so directives keep their line numbers.
+func stripSource(src []byte) []byte {
+	out := make([]byte, 0, len(src))
+	// 续行删除后补回换行，保持行号不变
+	pending := 0
+	var quote byte
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if c == '\\' {
+			j := i + 1
+			if j < len(src) && src[j] == '\r' {
+				j++
+			}
+			if j < len(src) && src[j] == '\n' {
+				pending++
+				i = j
+				continue
+			}
+		}
+		switch {
+		case c == '\n':
+			quote = 0
+			out = append(out, c)
+			for ; pending > 0; pending-- {
+				out = append(out, '\n')
+			}
+		case c == '\r':
+		case quote != 0:
+			out = append(out, c)
+			if c == '\\' && i+1 < len(src) && src[i+1] != '\n' {
+				i++
+				out = append(out, src[i])
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			out = append(out, c)
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i+1 < len(src) && src[i+1] != '\n' {
+				i++
+				// 行注释也可以续行
+				if src[i] == '\\' && i+1 < len(src) && src[i+1] == '\n' {
+					pending++
+					i++
+				}
+			}
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			out = append(out, ' ')
+			i += 2
+			for i < len(src) && !(src[i] == '*' && i+1 < len(src) && src[i+1] == '/') {
+				if src[i] == '\n' {
+					pending++
+				}
+				i++
+			}
+			i++
+		default:
+			out = append(out, c)
+		}
+	}
+	for ; pending > 0; pending-- {
+		out = append(out, '\n')
+	}
+	return out
+}
+
+// parseIncludes returns the #include, #include_next and #import directives of
+// a source, regardless of conditionals.
+func parseIncludes(data []byte) []include {
+	return parseDirectives(data, []string{"include_next", "include", "import"})
+}
+
+// parseDirectives returns the directives of a source naming a file, like
+// #include, the longer of two names sharing a prefix listed first.
+func parseDirectives(data []byte, directives []string) []include {
+	var ret []include
+	src := stripSource(decodeSource(data))
+	for n, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(line[1:])
+		var rest string
+		for _, d := range directives {
+			if strings.HasPrefix(line, d) {
+				rest = strings.TrimSpace(line[len(d):])
+				break
+			}
+		}
+		if rest == "" {
+			continue
+		}
+		inc := include{Line: n + 1}
+		switch rest[0] {
+		case '"', '<':
+			end := byte('"')
+			if rest[0] == '<' {
+				end = '>'
+				inc.Angled = true
+			}
+			i := strings.IndexByte(rest[1:], end)
+			if i <= 0 {
+				continue
+			}
+			inc.Name = rest[1 : i+1]
+		default:
+			i := 0
+			for i < len(rest) && (rest[i] == '_' || 'a' <= rest[i] && rest[i] <= 'z' ||
+				'A' <= rest[i] && rest[i] <= 'Z' || i > 0 && '0' <= rest[i] && rest[i] <= '9') {
+				i++
+			}
+			if i == 0 {
+				continue
+			}
+			inc.Name = rest[:i]
+			inc.Macro = true
+		}
+		ret = append(ret, inc)
+	}
+	return ret
+}
+
+func parseIncludesFile(p string) ([]include, error) {
+	f, err := openRegular(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return parseIncludes(data), nil
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// utf16Source encodes s as UTF-16 with a byte order mark when bom is set.
+func utf16Source(s string, order binary.ByteOrder, bom bool) []byte {
+	var buf []byte
+	u := utf16.Encode([]rune(s))
+	if bom {
+		u = append([]uint16{0xfeff}, u...)
+	}
+	for _, c := range u {
+		var b [2]byte
+		order.PutUint16(b[:], c)
+		buf = append(buf, b[:]...)
+	}
+	return buf
+}
+
+func TestParseIncludes(t *testing.T) {
+	tests := []struct {
+		src  string
+		incs []include
+	}{
+		{"#include <a.h>\n#include \"b.h\"\n", []include{
+			{Name: "a.h", Angled: true, Line: 1},
+			{Name: "b.h", Line: 2},
+		}},
+		{"  #  include_next <a.h>\n#import \"b.h\"\n#define X\n", []include{
+			{Name: "a.h", Angled: true, Line: 1},
+			{Name: "b.h", Line: 2},
+		}},
+		{"#include HEADER_1\n#include 1\n", []include{
+			{Name: "HEADER_1", Macro: true, Line: 1},
+		}},
+		// 续行和注释不影响后面的行号
+		{"#define X \\\n  1\n#inc\\\nlude <a.h>\n", []include{
+			{Name: "a.h", Angled: true, Line: 3},
+		}},
+		// 跨行注释换成空格，后面的指令属于注释开始的那一行
+		{"/* #include <no.h>\n */ #include <a.h>\n// #include <no.h>\n#include /* c */ \"b.h\" // c\n", []include{
+			{Name: "a.h", Angled: true, Line: 1},
+			{Name: "b.h", Line: 4},
+		}},
+		{"// line comment \\\n#include <no.h>\n#include <a.h>\n", []include{
+			{Name: "a.h", Angled: true, Line: 3},
+		}},
+		{"\r\n#include <a.h>\r\n", []include{
+			{Name: "a.h", Angled: true, Line: 2},
+		}},
+		{"#include <a.h\n#include\n#error\n", nil},
+		{"\xef\xbb\xbf#include <a.h>\n", []include{
+			{Name: "a.h", Angled: true, Line: 1},
+		}},
+	}
+	for _, tt := range tests {
+		if incs := parseIncludes([]byte(tt.src)); !reflect.DeepEqual(incs, tt.incs) {
+			t.Errorf("parseIncludes(%q) = %+v, want %+v", tt.src, incs, tt.incs)
+		}
+	}
+}
+
+func TestParseIncludesUTF16(t *testing.T) {
+	src := "#include <a.h>\n// é\n#include \"bé.h\"\n"
+	want := parseIncludes([]byte(src))
+	if len(want) != 2 {
+		t.Fatalf("parseIncludes(%q) = %+v", src, want)
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for _, bom := range []bool{true, false} {
+			data := utf16Source(src, order, bom)
+			if incs := parseIncludes(data); !reflect.DeepEqual(incs, want) {
+				t.Errorf("%v bom=%v: parseIncludes = %+v, want %+v", order, bom, incs, want)
+			}
+		}
+	}
+}
+
+func FuzzParseIncludes(f *testing.F) {
+	for _, s := range []string{
+		"#include <a.h>\n",
+		"#include \"a.h\" /* x\n */\n#import <b.h>\n",
+		"#define A \\\n#include <no.h>\n",
+		"#include_next HEADER\n",
+		"\"#include <no.h>\"\n#include '<a.h>'\n",
+		"\xef\xbb\xbf#include <a.h>",
+		"\xff\xfe#\x00i\x00",
+	} {
+		f.Add([]byte(s))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		incs := parseIncludes(data)
+		lines := strings.Count(string(decodeSource(data)), "\n") + 1
+		for _, inc := range incs {
+			if inc.Name == "" || inc.Line < 1 || inc.Line > lines {
+				t.Fatalf("bad include %+v in %d lines", inc, lines)
+			}
+			if inc.Macro && (inc.Angled || strings.ContainsAny(inc.Name, " \t\"<>")) {
+				t.Fatalf("bad macro include %+v", inc)
+			}
+			if strings.ContainsRune(inc.Name, '\n') {
+				t.Fatalf("include %+v spans lines", inc)
+			}
+		}
+		// 去掉注释和续行后行数不变
+		src := decodeSource(data)
+		if n, m := strings.Count(string(stripSource(src)), "\n"), strings.Count(string(src), "\n"); n != m {
+			t.Fatalf("stripSource made %d lines out of %d", n, m)
+		}
+	})
+}
@@ -0,0 +1,359 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+var (
+	saveIndex = cmdline.String("save-index", "", "save the header index to file after scanning")
+	loadIndex = cmdline.String("load-index", "", "load a header index saved with -save-index instead of scanning its roots")
+)
+
+// The saved index is a flat file searched in place after mmap:
+//
+//	magic [8]byte
+//	nroots, nentries uint32
+//	toolchain {off, len uint32}
+//	roots   [nroots]{off, len uint32}
+//	entries [nentries]{off, len, min uint32}
+//	strings
+//
+// The toolchain is the toolchainKey of the saving run; indexes of another
+// compiler are not loaded. Roots are rootSpec strings. Entries are header paths sorted by base name,
+// and a header may only match the part of its path after min, which keeps
+// lookups limited to the root like the tree does.
+const indexMagic = "CCINDEX2"
+
+// indexFormat is the number in indexMagic.
+const indexFormat = 2
+
+var (
+	errBadIndex   = errors.New("bad index file")
+	errStaleIndex = errors.New("index saved with another compiler")
+)
+
+type flatIndex struct {
+	data     []byte
+	roots    rootSlice
+	nentries int
+	entries  []byte
+	close    func() error
+}
+
+type indexEntry struct {
+	path string
+	min  int
+}
+
+func openIndex(name string) (*flatIndex, error) {
+	data, close, err := mmapFile(name)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := parseIndex(data)
+	if err != nil {
+		close()
+		return nil, err
+	}
+	idx.close = close
+	return idx, nil
+}
+
+func parseIndex(data []byte) (*flatIndex, error) {
+	if len(data) < 24 || string(data[:8]) != indexMagic {
+		return nil, errBadIndex
+	}
+	nroots := int(binary.LittleEndian.Uint32(data[8:]))
+	nentries := int(binary.LittleEndian.Uint32(data[12:]))
+	end := 24 + nroots*8 + nentries*12
+	if end > len(data) {
+		return nil, errBadIndex
+	}
+	idx := &flatIndex{
+		data:     data,
+		nentries: nentries,
+		entries:  data[24+nroots*8 : end],
+	}
+	key, err := idx.str(data[16:])
+	if err != nil {
+		return nil, err
+	}
+	if string(key) != toolchainKey() {
+		return nil, errStaleIndex
+	}
+	for i := 0; i < nroots; i++ {
+		s, err := idx.str(data[24+i*8:])
+		if err != nil {
+			return nil, err
+		}
+		spec, err := parseRoot(string(s))
+		if err != nil {
+			return nil, err
+		}
+		idx.roots = append(idx.roots, spec)
+	}
+	return idx, nil
+}
+
+func (idx *flatIndex) str(b []byte) ([]byte, error) {
+	off := binary.LittleEndian.Uint32(b)
+	n := binary.LittleEndian.Uint32(b[4:])
+	if uint64(off)+uint64(n) > uint64(len(idx.data)) {
+		return nil, errBadIndex
+	}
+	return idx.data[off : off+n], nil
+}
+
+func (idx *flatIndex) entry(i int) ([]byte, int) {
+	b := idx.entries[i*12:]
+	s, err := idx.str(b)
+	if err != nil {
+		return nil, 0
+	}
+	return s, int(binary.LittleEndian.Uint32(b[8:]))
+}
+
+func baseName(p []byte) []byte {
+	return p[bytes.LastIndexByte(p, filepath.Separator)+1:]
+}
+
+// Search returns the include dirs under which header is found.
+func (idx *flatIndex) Search(header string) []string {
+	header = filepath.Clean(strings.TrimPrefix(header, string(filepath.Separator)))
+	suffix := []byte(string(filepath.Separator) + header)
+	base := []byte(filepath.Base(header))
+
+	i := sort.Search(idx.nentries, func(i int) bool {
+		p, _ := idx.entry(i)
+		return bytes.Compare(baseName(p), base) >= 0
+	})
+	var ret []string
+	for ; i < idx.nentries; i++ {
+		p, min := idx.entry(i)
+		if !bytes.Equal(baseName(p), base) {
+			break
+		}
+		if !bytes.HasSuffix(p, suffix) || len(p)-len(suffix)+1 < min {
+			continue
+		}
+		dir := string(p[:len(p)-len(suffix)])
+		if dir == "" {
+			dir = string(filepath.Separator)
+		}
+		ret = append(ret, dir)
+	}
+	return ret
+}
+
+func (idx *flatIndex) Close() error {
+	if idx.close == nil {
+		return nil
+	}
+	return idx.close()
+}
+
+// Load makes the entries of idx searchable and returns the roots it covers.
+func (t *tree) Load(idx *flatIndex) []string {
+	var ret []string
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.flats = append(t.flats, idx)
+	for _, spec := range idx.roots {
+		t.specs[spec.Path] = spec
+		ret = append(ret, spec.Path)
+	}
+	return ret
+}
+
+func (t *tree) indexEntries() (rootSlice, []indexEntry) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.indexEntriesLocked()
+}
+
+func (t *tree) indexEntriesLocked() (rootSlice, []indexEntry) {
+	var roots rootSlice
+	var entries []indexEntry
+	for p, root := range t.roots {
+		spec, ok := t.specs[p]
+		if !ok {
+			spec = rootSpec{Path: p}
+		}
+		spec.Path = p
+		roots = append(roots, spec)
+		min := len(filepath.Dir(p)) + 1
+		for _, n := range root.Children {
+			if !t.hiddenLocked(n.Path(), p) {
+				entries = append(entries, indexEntry{n.Path(), min})
+			}
+		}
+	}
+	for dir, o := range t.overlays {
+		min := len(filepath.Dir(o.root)) + 1
+		for _, n := range o.node.Children {
+			if !t.hiddenLocked(n.Path(), dir) {
+				entries = append(entries, indexEntry{n.Path(), min})
+			}
+		}
+	}
+	for _, idx := range t.flats {
+		roots = append(roots, idx.roots...)
+		for i := 0; i < idx.nentries; i++ {
+			p, min := idx.entry(i)
+			if !t.hiddenLocked(string(p), "") {
+				entries = append(entries, indexEntry{string(p), min})
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		bi, bj := filepath.Base(entries[i].path), filepath.Base(entries[j].path)
+		if bi != bj {
+			return bi < bj
+		}
+		return entries[i].path < entries[j].path
+	})
+	return roots, entries
+}
+
+// Save writes the index of t to name in the format read by openIndex.
+func (t *tree) Save(name string) error {
+	roots, entries := t.indexEntries()
+	return writeIndex(name, roots, entries)
+}
+
+func writeIndex(name string, roots rootSlice, entries []indexEntry) error {
+	head := new(bytes.Buffer)
+	blob := new(bytes.Buffer)
+	base := 24 + len(roots)*8 + len(entries)*12
+	put := func(v int) {
+		binary.Write(head, binary.LittleEndian, uint32(v))
+	}
+	putString := func(s string) {
+		put(base + blob.Len())
+		put(len(s))
+		blob.WriteString(s)
+	}
+
+	head.WriteString(indexMagic)
+	put(len(roots))
+	put(len(entries))
+	putString(toolchainKey())
+	for _, spec := range roots {
+		putString(spec.String())
+	}
+	for _, e := range entries {
+		putString(e.path)
+		put(e.min)
+	}
+	if uint64(base+blob.Len()) > 1<<32-1 {
+		return errors.New("index too large")
+	}
+	return ioutil.WriteFile(name, append(head.Bytes(), blob.Bytes()...), 0644)
+}
+
+// Spill moves the scanned roots of t to a flat index in a temporary file,
+// searched in place like the ones of -load-index, to free their memory.
+func (t *tree) Spill() error {
+	f, err := ioutil.TempFile("", "clang_complete.index")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	defer os.Remove(name)
+
+	// 保存和替换之间不能有新的扫描结果
+	t.lock.Lock()
+	roots, entries := t.indexEntriesLocked()
+	err = writeIndex(name, roots, entries)
+	if err != nil {
+		t.lock.Unlock()
+		return err
+	}
+	idx, err := openIndex(name)
+	if err != nil {
+		t.lock.Unlock()
+		return err
+	}
+	n := len(t.roots)
+	old := t.flats
+	t.roots = make(map[string]*node)
+	t.overlays = make(map[string]overlay)
+	t.flats = []*flatIndex{idx}
+	t.lock.Unlock()
+	// 旧的索引已经写入新文件，等正在读的搜索结束后解除映射
+	t.flatUse.Lock()
+	for _, idx := range old {
+		idx.Close()
+	}
+	t.flatUse.Unlock()
+	runtime.GC()
+	debug.FreeOSMemory()
+	fmt.Fprintf(os.Stderr, "index over -max-mem, %d roots moved to disk\n", n)
+	return nil
+}
+
+// Close unmaps the flat indexes of t once the searches using them finish.
+func (t *tree) Close() {
+	t.lock.Lock()
+	old := t.flats
+	t.flats = nil
+	t.lock.Unlock()
+	t.flatUse.Lock()
+	for _, idx := range old {
+		idx.Close()
+	}
+	t.flatUse.Unlock()
+}
+
+// overMemLimit reports whether the heap is past -max-mem.
+func overMemLimit() bool {
+	if *maxMem <= 0 {
+		return false
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return int64(m.HeapAlloc) > *maxMem<<20
+}
+
+// flatRoot reports whether root is covered by a flat index. t.lock is held.
+func (t *tree) flatRoot(root string) bool {
+	for _, idx := range t.flats {
+		if containsString(idx.roots.Paths(), root) {
+			return true
+		}
+	}
+	return false
+}
+
+// uncovered returns the roots that are not in the loaded ones.
+func uncovered(roots rootSlice, loaded []string) rootSlice {
+	var ret rootSlice
+	for _, root := range roots {
+		p, err := filepath.Abs(msys.Windows(root.Path))
+		if err == nil && containsString(loaded, p) {
+			continue
+		}
+		ret = append(ret, root)
+	}
+	return ret
+}
+
+func containsString(l []string, s string) bool {
+	for _, x := range l {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
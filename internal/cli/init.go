@@ -0,0 +1,184 @@
+package cli
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// buildOutputs are the names of dirs holding build output, excluded by init.
+var buildOutputs = []string{"build", "_build", "out", "cmake-build-*", "bazel-*", "node_modules"}
+
+// buildSystems maps the files marking a build system to its name, checked in
+// order.
+var buildSystems = []struct {
+	pattern, name string
+}{
+	{"CMakeLists.txt", "cmake"},
+	{"meson.build", "meson"},
+	{"*.pro", "qmake"},
+	{"*.xcodeproj", "xcode"},
+	{"configure.ac", "autoconf"},
+	{"Makefile", "make"},
+}
+
+// runInit inspects the current dir and writes a starter config for it.
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	out := fs.String("o", "clang_complete.json", "config file to write")
+	yes := fs.Bool("y", false, "write the config without asking")
+	force := fs.Bool("f", false, "overwrite an existing config")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New("usage: clang_complete " + commands["init"].usage)
+	}
+	if _, err := os.Stat(*out); err == nil && !*force {
+		return fmt.Errorf("%s exists, use -f to overwrite it", *out)
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	cfg, system := initConfig(dir)
+	buf, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	buf = append(buf, '\n')
+	fmt.Printf("build system: %s\n", system)
+	fmt.Printf("%s:\n%s", *out, buf)
+	if !*yes && isTerminal(os.Stdin) && !confirm("write "+*out+"? [Y/n] ") {
+		return nil
+	}
+	err = ioutil.WriteFile(*out, buf, 0644)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("run: clang_complete -config %s .\n", *out)
+	return nil
+}
+
+// initConfig proposes a config for the project in dir, and returns the name
+// of the build system it found.
+func initConfig(dir string) (*config, string) {
+	cfg := &config{
+		HeaderSuffix: []string{},
+		SrcSuffix:    []string{},
+		Exclude:      []string{},
+		Format:       "clang_complete",
+	}
+	system := "none"
+	for _, b := range buildSystems {
+		if l, _ := filepath.Glob(filepath.Join(dir, b.pattern)); len(l) != 0 {
+			system = b.name
+			break
+		}
+	}
+	// 这些构建系统的工具都能读取 compile_commands.json
+	switch system {
+	case "cmake", "meson", "qmake":
+		cfg.Format = "compdb"
+	}
+
+	for _, pattern := range buildOutputs {
+		if l, _ := filepath.Glob(filepath.Join(dir, pattern)); len(l) != 0 {
+			cfg.Exclude = append(cfg.Exclude, pattern)
+		}
+	}
+
+	excludes = cfg.Exclude
+	for _, root := range defaultRoots(dir) {
+		if rel, err := filepath.Rel(dir, root); err == nil {
+			root = rel
+		}
+		cfg.SearchRoots = append(cfg.SearchRoots, root)
+	}
+	return cfg, system
+}
+
+// defaultRoots proposes the search roots of the project in dir: its include
+// dirs, or dir itself when headers are elsewhere too, and the vendored
+// libraries searched after them.
+func defaultRoots(dir string) []string {
+	vendored := findVendored(dir)
+	var roots []string
+	for _, root := range vendored {
+		if info, err := os.Stat(filepath.Join(root, "include")); err == nil && info.IsDir() {
+			root = filepath.Join(root, "include")
+		}
+		roots = append(roots, root+":after")
+	}
+	// 不在 include 目录下的头文件需要搜索整个项目
+	incs := includeDirs(dir, vendored)
+	if len(incs) == 0 || hasLooseHeaders(dir, append(incs, vendored...)) {
+		incs = []string{dir}
+	}
+	roots = append(roots, incs...)
+	sort.Strings(roots)
+	return roots
+}
+
+// includeDirs returns the include dirs of the project in dir, not counting the
+// vendored ones.
+func includeDirs(dir string, vendored []string) []string {
+	var ret []string
+	var walk func(p string, depth int)
+	walk = func(p string, depth int) {
+		files, err := ioutil.ReadDir(p)
+		if err != nil {
+			return
+		}
+		for _, f := range files {
+			if !f.IsDir() || f.Name()[0] == '.' || isExcluded(f.Name()) {
+				continue
+			}
+			sub := filepath.Join(p, f.Name())
+			if underAny(sub, vendored) {
+				continue
+			}
+			if f.Name() == "include" {
+				ret = append(ret, sub)
+				continue
+			}
+			if depth < vendorMaxDepth {
+				walk(sub, depth+1)
+			}
+		}
+	}
+	walk(dir, 0)
+	return ret
+}
+
+// hasLooseHeaders reports whether dir has headers outside dirs.
+func hasLooseHeaders(dir string, dirs []string) bool {
+	headerext, _ := suffixes(new(config))
+	l := list.New()
+	collect(dir, l, headerext)
+	for e := l.Front(); e != nil; e = e.Next() {
+		if !underAny(e.Value.(string), dirs) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func confirm(prompt string) bool {
+	fmt.Print(prompt)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	line = strings.ToLower(strings.TrimSpace(line))
+	return line == "" || line == "y" || line == "yes"
+}
@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var inventoryFile = cmdline.String("inventory", "", "write the third-party include dirs with their detected library and version to file as json")
+
+// inventoryItem is a third-party include dir the scanned sources use.
+type inventoryItem struct {
+	Dir      string `json:"dir"`
+	Library  string `json:"library"`
+	Version  string `json:"version,omitempty"`
+	Evidence string `json:"evidence,omitempty"`
+	Headers  int    `json:"headers"`
+}
+
+var (
+	versionDefineRe = regexp.MustCompile(`^\s*#\s*define\s+(\w*VERSION\w*)\s+(\S+)`)
+	versionRe       = regexp.MustCompile(`^v?([0-9]+(\.[0-9]+)+[0-9A-Za-z.+-]*)$`)
+	cmakeVersionRe  = regexp.MustCompile(`(?is)project\s*\([^)]*\bVERSION\s+([0-9][0-9.]*)`)
+	mesonVersionRe  = regexp.MustCompile(`(?s)project\s*\([^)]*\bversion\s*:\s*'([^']+)'`)
+)
+
+// libraryParents is how far above an include dir package metadata is looked for.
+const libraryParents = 3
+
+// writeInventory describes the include dirs outside srcroot, and the ones of
+// vendored libraries inside it.
+func writeInventory(name, srcroot string, vendored []string) error {
+	stats.lock.Lock()
+	dirs := make(map[string][]string)
+	for dir, m := range stats.dirHdrs {
+		if underAny(dir, []string{srcroot}) && !underAny(dir, vendored) {
+			continue
+		}
+		for h := range m {
+			dirs[dir] = append(dirs[dir], h)
+		}
+	}
+	stats.lock.Unlock()
+
+	// 元数据不会在搜索根目录之外查找
+	tops := []string{srcroot}
+	for _, root := range searchroots.Paths() {
+		if abs, err := filepath.Abs(msys.Windows(root)); err == nil {
+			tops = append(tops, abs)
+		}
+	}
+	items := []inventoryItem{}
+	for dir, headers := range dirs {
+		sort.Strings(headers)
+		item := inventoryItem{Dir: mapPath(dir), Headers: len(headers)}
+		item.Library, item.Version, item.Evidence = detectLibrary(dir, headers, tops)
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Dir < items[j].Dir
+	})
+
+	buf, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	buf = append(buf, '\n')
+	if name == "-" {
+		_, err = os.Stdout.Write(buf)
+		return err
+	}
+	return ioutil.WriteFile(name, buf, 0644)
+}
+
+// detectLibrary guesses the library of an include dir from package metadata
+// next to it, or from version macros in its headers. Metadata is not looked
+// for above the dirs in tops.
+func detectLibrary(dir string, headers []string, tops []string) (library, version, evidence string) {
+	library = libraryName(dir)
+	p := dir
+	for i := 0; i <= libraryParents; i++ {
+		if v, file := metadataVersion(p); v != "" {
+			return libraryName(p), v, file
+		}
+		parent := filepath.Dir(p)
+		if parent == p || containsString(tops, p) {
+			break
+		}
+		p = parent
+	}
+
+	var files []string
+	for _, h := range headers {
+		files = append(files, filepath.Join(dir, h))
+	}
+	if l, err := ioutil.ReadDir(dir); err == nil {
+		for _, f := range l {
+			if !f.IsDir() && strings.Contains(strings.ToLower(f.Name()), "version") {
+				files = append(files, filepath.Join(dir, f.Name()))
+			}
+		}
+	}
+	for _, file := range files {
+		if v, macro := macroVersion(file); v != "" {
+			rel, _ := filepath.Rel(dir, file)
+			return library, v, macro + " in " + rel
+		}
+	}
+	return library, "", ""
+}
+
+func libraryName(dir string) string {
+	name := filepath.Base(dir)
+	if name == "include" {
+		name = filepath.Base(filepath.Dir(dir))
+	}
+	return versionSuffix.ReplaceAllString(name, "")
+}
+
+func metadataVersion(dir string) (version, file string) {
+	read := func(name string) string {
+		buf, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return ""
+		}
+		return string(buf)
+	}
+	for _, name := range []string{"VERSION", "version.txt"} {
+		if v := strings.TrimSpace(read(name)); versionRe.MatchString(v) {
+			return strings.TrimPrefix(v, "v"), name
+		}
+	}
+	for _, name := range []string{"vcpkg.json", "library.json", "package.json"} {
+		var pkg struct {
+			Version       string `json:"version"`
+			VersionString string `json:"version-string"`
+		}
+		if json.Unmarshal([]byte(read(name)), &pkg) != nil {
+			continue
+		}
+		if v := pkg.Version + pkg.VersionString; v != "" {
+			return v, name
+		}
+	}
+	if m := cmakeVersionRe.FindStringSubmatch(read("CMakeLists.txt")); m != nil {
+		return m[1], "CMakeLists.txt"
+	}
+	if m := mesonVersionRe.FindStringSubmatch(read("meson.build")); m != nil {
+		return m[1], "meson.build"
+	}
+	return "", ""
+}
+
+// macroVersion returns the version defined in file, preferring a dotted string
+// over MAJOR/MINOR/PATCH parts over a plain number.
+func macroVersion(file string) (version, macro string) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+
+	parts := make(map[string]string)
+	var number, numberMacro, prefix string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m := versionDefineRe.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		name, value := m[1], strings.Trim(m[2], `"`)
+		if versionRe.MatchString(value) {
+			return strings.TrimPrefix(value, "v"), name
+		}
+		if strings.Trim(value, "0123456789") != "" {
+			continue
+		}
+		for _, part := range []string{"MAJOR", "MINOR", "PATCH"} {
+			if strings.HasSuffix(name, "_"+part) || strings.Contains(name, "_"+part+"_") {
+				parts[part] = value
+				if part == "MAJOR" {
+					prefix = name
+				}
+			}
+		}
+		if strings.HasSuffix(name, "VERSION") && number == "" {
+			number, numberMacro = value, name
+		}
+	}
+	if parts["MAJOR"] != "" && parts["MINOR"] != "" {
+		v := parts["MAJOR"] + "." + parts["MINOR"]
+		if parts["PATCH"] != "" {
+			v += "." + parts["PATCH"]
+		}
+		return v, prefix
+	}
+	return number, numberMacro
+}
@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// languages by source suffix, named as for clang -x
+var langSuffix = map[string]string{
+	".c":   "c",
+	".m":   "objective-c",
+	".mm":  "objective-c++",
+	".cu":  "cuda",
+	".cc":  "c++",
+	".cpp": "c++",
+	".cxx": "c++",
+	".c++": "c++",
+	".C":   "c++",
+	".S":   "assembler-with-cpp",
+	".sx":  "assembler-with-cpp",
+}
+
+// clangd PathMatch of the files of each language
+var langPathMatch = map[string]string{
+	"c":                  `.*\.c`,
+	"c++":                `.*\.(cc|cpp|cxx|c\+\+|C)`,
+	"objective-c":        `.*\.m`,
+	"objective-c++":      `.*\.mm`,
+	"cuda":               `.*\.cu`,
+	"assembler-with-cpp": `.*\.(S|sx)`,
+}
+
+func langOf(file string) string {
+	ext := filepath.Ext(file)
+	if lang, ok := langSuffix[ext]; ok {
+		return lang
+	}
+	if lang, ok := langSuffix[strings.ToLower(ext)]; ok {
+		return lang
+	}
+	return "c++"
+}
+
+// flagLangs returns the languages a flag group is valid for, nil if all.
+func flagLangs(g []string) []string {
+	switch arg := g[0]; {
+	case strings.HasPrefix(arg, "-std="):
+		std := arg[len("-std="):]
+		if strings.Contains(std, "++") {
+			return []string{"c++", "objective-c++", "cuda"}
+		}
+		if strings.HasPrefix(std, "c") || strings.HasPrefix(std, "gnu") || strings.HasPrefix(std, "iso9899") {
+			return []string{"c", "objective-c"}
+		}
+	case strings.HasPrefix(arg, "-fobjc") || strings.HasPrefix(arg, "-fno-objc"):
+		return []string{"objective-c", "objective-c++"}
+	}
+	return nil
+}
+
+// langCounts counts the files of each language.
+func (fs *flagSet) langCounts() map[string]int {
+	ret := make(map[string]int)
+	for _, file := range fs.Files {
+		ret[fileLang(file)]++
+	}
+	return ret
+}
+
+// splitLangs moves the flags valid for some languages only out of the common
+// flags. Flags valid for none of the languages of the tree are dropped.
+func (fs *flagSet) splitLangs() {
+	counts := fs.langCounts()
+	if len(counts) == 0 {
+		return
+	}
+	var common []string
+	for _, g := range flagGroups(fs.Flags) {
+		langs := flagLangs(g)
+		if langs == nil {
+			common = append(common, g...)
+			continue
+		}
+		for _, lang := range langs {
+			if counts[lang] > 0 {
+				fs.addLang(lang, g)
+			}
+		}
+	}
+	fs.Flags = common
+}
+
+func (fs *flagSet) addLang(lang string, flags []string) {
+	if len(flags) == 0 {
+		return
+	}
+	if fs.Lang == nil {
+		fs.Lang = make(map[string][]string)
+	}
+	fs.Lang[lang] = dedupFlags(append(append([]string{}, fs.Lang[lang]...), flags...))
+}
+
+// foldLangs makes the flags of the only language of a tree common.
+func (fs *flagSet) foldLangs() {
+	counts := fs.langCounts()
+	if len(counts) != 1 {
+		return
+	}
+	for lang := range counts {
+		fs.Flags = dedupFlags(append(append([]string{}, fs.Flags...), fs.Lang[lang]...))
+	}
+	fs.Lang = nil
+}
+
+// Langs returns the languages with their own flags, sorted.
+func (fs *flagSet) Langs() []string {
+	var ret []string
+	for lang := range fs.Lang {
+		ret = append(ret, lang)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// ArgsFor returns the arguments of file. Test sources see the dirs of test
+// roots first.
+func (fs *flagSet) ArgsFor(file string) []string {
+	var ret []string
+	if isTestSource(nil, file) {
+		for _, dir := range fs.Test {
+			ret = append(ret, "-I"+dir)
+		}
+	}
+	ret = append(ret, fs.Args()...)
+	for _, dir := range fs.FileIncludes[file] {
+		ret = append(ret, "-I"+dir)
+	}
+	ret = append(ret, fs.Lang[fileLang(file)]...)
+	return append(ret, fs.FileFlags[file]...)
+}
+
+// forLang returns a copy of fs without sections, with the flags of lang.
+func (fs *flagSet) forLang(lang string) *flagSet {
+	ret := *fs
+	ret.Flags = append(append([]string{}, fs.Flags...), fs.Lang[lang]...)
+	ret.Lang = nil
+	return &ret
+}
+
+// flatten is forLang of the language with most files, for the formats that
+// have no sections.
+func (fs *flagSet) flatten() *flagSet {
+	// 测试目录放在最后，同名头文件仍然优先使用正式目录
+	if len(fs.Test) != 0 {
+		ret := *fs
+		ret.Includes = dedup(append(append([]string{}, fs.Includes...), fs.Test...))
+		ret.Test = nil
+		fs = &ret
+	}
+	if len(fs.Lang) == 0 {
+		return fs
+	}
+	var best string
+	counts := fs.langCounts()
+	for _, lang := range fs.Langs() {
+		if best == "" || counts[lang] > counts[best] {
+			best = lang
+		}
+	}
+	return fs.forLang(best)
+}
+
+// langFile names the output of lang next to name. The suffix is not a source
+// extension, so it never lands on a source named like the output.
+func langFile(name, lang string) string {
+	return name + ".lang-" + lang
+}
+
+// writeLangFiles writes name.lang-<lang> with the flags of each language for
+// the clang_complete format, which has no sections, and removes the files of
+// the languages the tree no longer has. The files start with generatedMarker
+// and only files starting with it are removed.
+func writeLangFiles(tx *outputTx, name string, f *format, fs *flagSet) error {
+	if f.name != "clang_complete" {
+		return nil
+	}
+	for lang := range langPathMatch {
+		if p := langFile(name, lang); isGenerated(p) {
+			tx.Remove(p)
+		}
+	}
+	if len(fs.Lang) == 0 {
+		return nil
+	}
+	var langs []string
+	for lang := range fs.langCounts() {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		err := tx.write(langFile(name, lang), func(w io.Writer) error {
+			// 有元数据时注释里已经带了标记
+			if fs.Meta == nil {
+				fmt.Fprintln(w, generatedMarker)
+			}
+			return f.write(w, fs.forLang(lang))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package cli
+
+import "sync"
+
+// scanLangs records the files scanned only as another language than their
+// suffix tells.
+var scanLangs = &langRecord{m: make(map[string]string)}
+
+type langRecord struct {
+	lock sync.Mutex
+	m    map[string]string
+}
+
+func (r *langRecord) Set(file, lang string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.m[file] = lang
+}
+
+func (r *langRecord) Get(file string) string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.m[file]
+}
+
+// fileLang returns the language of file, the one its scan succeeded with if
+// it was retried.
+func fileLang(file string) string {
+	if lang := scanLangs.Get(file); lang != "" {
+		return lang
+	}
+	return langOf(file)
+}
+
+// retryLang returns the language a failed scan of file is retried as, "" if
+// none.
+func retryLang(file string) string {
+	switch {
+	case isAsm(file) || isCuda(file):
+		return ""
+	case scanLang(file) == "c":
+		return "c++"
+	}
+	return "c"
+}
+
+// retried records the language files retried as by a batch scan were
+// scanned with, for those the retry succeeded on.
+func (b *batchDeps) retried(files []string, lang string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, file := range files {
+		if _, ok := b.m[file]; ok {
+			scanLangs.Set(file, lang)
+		}
+	}
+}
+
+// flagsFor drops the flags invalid for lang, like -std=c++17 for C.
+func flagsFor(flags []string, lang string) []string {
+	var ret []string
+	for _, g := range flagGroups(flags) {
+		langs := flagLangs(g)
+		if langs != nil && !containsString(langs, lang) {
+			continue
+		}
+		ret = append(ret, g...)
+	}
+	return ret
+}
+
+// addScanLangs makes the outputs parse the files as the language their scan
+// succeeded with.
+func (fs *flagSet) addScanLangs() {
+	for _, file := range fs.Files {
+		lang := scanLangs.Get(file)
+		if lang == "" || lang == langOf(file) {
+			continue
+		}
+		if fs.FileFlags == nil {
+			fs.FileFlags = make(map[string][]string)
+		}
+		fs.FileFlags[file] = append([]string{"-x", lang}, fs.FileFlags[file]...)
+	}
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var learnSuffixes = cmdline.Bool("learn-suffixes", false, "look for C/C++ files with suffixes missing from -src_suffix and -header_suffix, report them and accept them for the run")
+
+// knownSources are source suffixes in use besides the defaults, the other
+// suffixes of files with include directives are taken as headers.
+var knownSources = map[string]bool{
+	".cxx": true, ".c++": true, ".cp": true, ".C": true, ".CPP": true, ".m": true, ".mm": true,
+}
+
+// templateSuffixes are the suffixes of the inputs configure and cmake turn
+// into headers, like config.h.in, which are never included themselves.
+var templateSuffixes = map[string]bool{
+	".in": true, ".cmake": true,
+}
+
+// learnedSuffix counts the files found with a suffix.
+type learnedSuffix struct {
+	ext   string
+	files int
+}
+
+// learn extends headerext and srcext with the suffixes of the C/C++ files in
+// dirs they miss, and reports them.
+func learn(dirs []string, headerext, srcext map[string]bool) {
+	counts := make(map[string]int)
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			name := info.Name()
+			if info.IsDir() {
+				if path != dir && (name[0] == '.' || isExcluded(name)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			ext := filepath.Ext(name)
+			if !info.Mode().IsRegular() || ext == "" || headerext[ext] || srcext[ext] || templateSuffixes[ext] || seen[path] {
+				return nil
+			}
+			seen[path] = true
+			if hasIncludes(path) {
+				counts[ext]++
+			}
+			return nil
+		})
+	}
+
+	var sources, headers []learnedSuffix
+	for ext, n := range counts {
+		if knownSources[ext] {
+			srcext[ext] = true
+			sources = append(sources, learnedSuffix{ext, n})
+		} else {
+			headerext[ext] = true
+			headers = append(headers, learnedSuffix{ext, n})
+		}
+	}
+	report := func(kind string, l []learnedSuffix) {
+		if len(l) == 0 {
+			return
+		}
+		sort.Slice(l, func(i, j int) bool { return l[i].files > l[j].files || l[i].files == l[j].files && l[i].ext < l[j].ext })
+		var parts []string
+		for _, s := range l {
+			parts = append(parts, fmt.Sprintf("%s (%d files)", s.ext, s.files))
+		}
+		fmt.Fprintf(os.Stderr, "learned %s suffixes: %s\n", kind, strings.Join(parts, ", "))
+	}
+	report("source", sources)
+	report("header", headers)
+}
+
+// hasIncludes reports whether the head of file p has an #include directive or
+// #pragma once. Other directives look too much like comments of scripts.
+func hasIncludes(p string) bool {
+	f, err := openRegular(p)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < 200 && scanner.Scan(); i++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if bytes.IndexByte(line, 0) != -1 {
+			return false
+		}
+		if len(line) == 0 || line[0] != '#' {
+			continue
+		}
+		directive := bytes.TrimSpace(line[1:])
+		if bytes.HasPrefix(directive, []byte("include")) {
+			rest := bytes.TrimSpace(directive[len("include"):])
+			if len(rest) > 0 && (rest[0] == '<' || rest[0] == '"') {
+				return true
+			}
+		}
+		if bytes.Equal(directive, []byte("pragma once")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cLocaleEnv returns the environment with the locale forced to C.
+func cLocaleEnv() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		switch strings.SplitN(kv, "=", 2)[0] {
+		case "LC_ALL", "LC_MESSAGES", "LANG", "LANGUAGE":
+			continue
+		}
+		env = append(env, kv)
+	}
+	return append(env, "LC_ALL=C", "LANG=C")
+}
+
+// verboseSearchDirs parses the include dirs of -v output without relying on
+// its English messages: they are the indented lines that follow the
+// "#include <...>" line, which translations keep.
+func verboseSearchDirs(out []byte) []string {
+	var ret []string
+	var started bool
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#include <") {
+			started = true
+			continue
+		}
+		if !started {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			break
+		}
+		dir := strings.TrimSpace(line)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			ret = append(ret, dir)
+		}
+	}
+	return ret
+}
+
+// installSearchDirs guesses the include dirs of the compiler from the install
+// dir printed by -print-search-dirs, when -v gave nothing usable.
+func installSearchDirs() []string {
+	out, _, err := runCompiler("-print-search-dirs")
+	if err != nil {
+		return nil
+	}
+	var ret []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "install:") {
+			continue
+		}
+		install := strings.TrimSpace(strings.TrimPrefix(line, "install:"))
+		for _, dir := range []string{
+			filepath.Join(install, "include"),
+			filepath.Join(install, "include-fixed"),
+			"/usr/local/include",
+			"/usr/include",
+		} {
+			if info, err := os.Stat(dir); err == nil && info.IsDir() {
+				ret = append(ret, dir)
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVerboseSearchDirs(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	for _, d := range []string{a, b} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	missing := filepath.Join(dir, "missing")
+
+	tests := []struct {
+		name string
+		out  string
+	}{
+		{"english", `ignoring nonexistent directory "` + missing + `"
+#include "..." search starts here:
+#include <...> search starts here:
+ ` + a + `
+ ` + b + `
+End of search list.
+`},
+		{"german", `ignoriere nicht existierendes Verzeichnis »` + missing + `«
+#include "..." - Suche beginnt hier:
+#include <...> - Suche beginnt hier:
+ ` + a + `
+ ` + missing + `
+ ` + b + `
+Ende der Suchliste.
+`},
+		{"french", `#include "..." la recherche débute ici :
+#include <...> la recherche débute ici :
+ ` + a + `
+ ` + b + `
+Fin de la liste de recherche.
+`},
+		{"chinese", `忽略不存在的目录“` + missing + `”
+#include "..." 搜索从这里开始：
+#include <...> 搜索从这里开始：
+ ` + a + `
+ ` + b + `
+搜索列表结束。
+ ` + missing + `
+`},
+		{"crlf", strings.Replace("#include <...> search starts here:\n "+a+"\n "+b+"\nEnd of search list.\n", "\n", "\r\n", -1)},
+	}
+	want := []string{a, b}
+	for _, tt := range tests {
+		if dirs := verboseSearchDirs([]byte(tt.out)); !reflect.DeepEqual(dirs, want) {
+			t.Errorf("%s: verboseSearchDirs = %q, want %q", tt.name, dirs, want)
+		}
+	}
+	if dirs := verboseSearchDirs([]byte(" " + a + "\n")); dirs != nil {
+		t.Errorf("verboseSearchDirs without the #include <...> line = %q", dirs)
+	}
+}
+
+func TestCLocaleEnv(t *testing.T) {
+	t.Setenv("LANG", "de_DE.UTF-8")
+	t.Setenv("LC_MESSAGES", "fr_FR.UTF-8")
+	t.Setenv("LANGUAGE", "zh_CN")
+	t.Setenv("LC_ALL", "")
+	var locale []string
+	for _, kv := range cLocaleEnv() {
+		switch strings.SplitN(kv, "=", 2)[0] {
+		case "LC_ALL", "LC_MESSAGES", "LANG", "LANGUAGE":
+			locale = append(locale, kv)
+		}
+	}
+	if want := []string{"LC_ALL=C", "LANG=C"}; !reflect.DeepEqual(locale, want) {
+		t.Errorf("locale of cLocaleEnv = %q, want %q", locale, want)
+	}
+}
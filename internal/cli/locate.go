@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	useLocate = cmdline.Bool("use-locate", false, "look up headers missing from the search roots in the locate database")
+	locateAdd = cmdline.Bool("locate-add", false, "add the best locate match as a new search root instead of only suggesting it")
+)
+
+type located struct {
+	dir string
+	err error
+}
+
+type locator struct {
+	lock  sync.Mutex
+	found map[string]located
+	added map[string]bool
+}
+
+var locateDB = &locator{
+	found: make(map[string]located),
+	added: make(map[string]bool),
+}
+
+// Lookup returns the include dir that makes header resolvable, or "" if the
+// locate database knows no file with that path suffix.
+func (l *locator) Lookup(header string) (string, error) {
+	l.lock.Lock()
+	r, ok := l.found[header]
+	l.lock.Unlock()
+	if ok {
+		return r.dir, r.err
+	}
+
+	dir, err := locateHeader(header)
+	l.lock.Lock()
+	l.found[header] = located{dir, err}
+	l.lock.Unlock()
+	return dir, err
+}
+
+// Add reports whether dir was not added as a search root before.
+func (l *locator) Add(dir string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.added[dir] {
+		return false
+	}
+	l.added[dir] = true
+	return true
+}
+
+func locateHeader(header string) (string, error) {
+	header = filepath.Clean(strings.TrimPrefix(header, "/"))
+	out, err := exec.Command("locate", "-b", `\`+filepath.Base(header)).Output()
+	if err != nil && len(out) == 0 {
+		return "", nil
+	}
+
+	var candidates []string
+	atRoot := false
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		p := scanner.Text()
+		if !strings.HasSuffix(p, string(filepath.Separator)+header) {
+			continue
+		}
+		dir := strings.TrimSuffix(p, string(filepath.Separator)+header)
+		// 在根目录下的头文件没有可用的搜索目录，空路径会被当成当前目录
+		if dir == "" {
+			atRoot = true
+			continue
+		}
+		if strings.Contains(dir, string(filepath.Separator)+".") {
+			continue
+		}
+		candidates = append(candidates, dir)
+	}
+	if len(candidates) == 0 && atRoot {
+		return "", fmt.Errorf("locate: %s is only found in %c", header, filepath.Separator)
+	}
+	if len(candidates) == 0 {
+		return "", nil
+	}
+	// 优先选择层次最浅、路径最短的目录，比如 /usr/include 而不是某个备份目录
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		da, db := strings.Count(a, string(filepath.Separator)), strings.Count(b, string(filepath.Separator))
+		if da != db {
+			return da < db
+		}
+		return a < b
+	})
+	return candidates[0], nil
+}
+
+func locateFallback(ctx context.Context, header string, t *tree, headerext map[string]bool) ([]string, error) {
+	dir, err := locateDB.Lookup(header)
+	if err != nil {
+		log.Debug("%s", err)
+		return nil, err
+	}
+	if dir == "" {
+		return nil, errNotFound
+	}
+	if !*locateAdd {
+		sessionOf(ctx).rep.Suggest(header, dir)
+		return nil, errNotFound
+	}
+	if locateDB.Add(dir) {
+		log.Debug("add search root %s for %s", dir, header)
+		err := t.Scan(dir, headerext)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t.Search(header)
+}
@@ -0,0 +1,1644 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	searchroots   rootSlice
+	ccflags       stringSlice
+	scanOnly      stringSlice
+	srcExtFlag    = cmdline.String("src_suffix", ".c .cc .cpp .cu .S .sx", "suffix of src or header file")
+	headerExtFlag = cmdline.String("header_suffix", ".h .hpp .hh .inl .tpp .ipp .inc .def", "suffix of include file")
+	sniff         = cmdline.Bool("sniff", false, "accept files with other suffixes as headers if they contain preprocessor directives")
+	output        = cmdline.String("o", ".clang_complete", "output file, '-' means stdout")
+	outFormat     = cmdline.String("format", "clang_complete", "output format: clang_complete, compile_flags, compdb, clangd or make")
+	ccWorkers     = cmdline.Int("cc-workers", runtime.NumCPU(), "number of concurrent compiler processes")
+	scanWorkers   = cmdline.Int("scan-workers", 4*runtime.NumCPU(), "number of concurrent directory reads while indexing")
+	debugon       = cmdline.Bool("v", false, "turn on debug")
+	skipErrors    = cmdline.Bool("skip-errors", true, "skip unreadable paths while scanning search roots")
+	maxDepth      = cmdline.Int("max-depth", 0, "do not index dirs nested deeper than this in a search root, 0 means no limit")
+	strictScan    = cmdline.Bool("strict-scan", false, "abort on the first unreadable path while scanning search roots")
+)
+
+// cmdline holds the options of clang_complete. It is not flag.CommandLine
+// so that programs embedding the resolver keep their own flags.
+var (
+	cmdline = flag.NewFlagSet("clang_complete", flag.ContinueOnError)
+	// cmdArgs are the arguments cmdline parsed
+	cmdArgs []string
+)
+
+var (
+	errSkip     = errors.New("skip")
+	errNotFound = errors.New("not found")
+	log         = &logger{}
+)
+
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return fmt.Sprintf("%q", []string(*s))
+}
+
+func (s *stringSlice) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// scanFlags returns the extra flags passed to the compiler while scanning.
+func scanFlags() []string {
+	return append(append([]string{}, ccflags...), scanOnly...)
+}
+
+type logger struct {
+	id int
+}
+
+var logSeq int64
+
+func (l *logger) New() *logger {
+	return &logger{id: int(atomic.AddInt64(&logSeq, 1))}
+}
+
+func (l *logger) Debug(fmtstr string, args ...interface{}) {
+	if *debugon {
+		buf := new(bytes.Buffer)
+		fmt.Fprintf(buf, "[%08d] [%s]", l.id, time.Now().Format("15:04:05"))
+		fmt.Fprintf(buf, fmtstr, args...)
+		fmt.Fprint(buf, "\n")
+		os.Stderr.Write(buf.Bytes())
+	}
+}
+
+// Fatal prints args and exits, running the cleanups of atExit first as
+// deferred calls are skipped.
+func (l *logger) Fatal(args ...interface{}) {
+	fmt.Fprint(os.Stderr, args...)
+	runExitCleanups()
+	os.Exit(-1)
+}
+
+var exitCleanups struct {
+	lock sync.Mutex
+	l    []func()
+}
+
+// atExit registers f, like removing a temporary dir, to run when log.Fatal
+// exits.
+func atExit(f func()) {
+	exitCleanups.lock.Lock()
+	defer exitCleanups.lock.Unlock()
+	exitCleanups.l = append(exitCleanups.l, f)
+}
+
+func runExitCleanups() {
+	exitCleanups.lock.Lock()
+	l := exitCleanups.l
+	exitCleanups.l = nil
+	exitCleanups.lock.Unlock()
+	for i := len(l) - 1; i >= 0; i-- {
+		l[i]()
+	}
+}
+
+// readDirBatch is the number of entries read from a directory at a time.
+const readDirBatch = 1024
+
+type node struct {
+	lock       sync.Mutex
+	Name       string
+	ParentPath string
+	// Children 按名字排序，除根节点外通常只有一个
+	Children []*node
+}
+
+func newNode(name string, parentPath string) *node {
+	return &node{
+		Name:       name,
+		ParentPath: parentPath,
+	}
+}
+
+func (n *node) AddChild(child *node) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.Children = append(n.Children, child)
+}
+
+// Sort orders the children of n by name, it must be called before Lookup
+// when children were added out of order.
+func (n *node) Sort() {
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		return n.Children[i].Name < n.Children[j].Name
+	})
+}
+
+// Lookup returns the children of n called name.
+func (n *node) Lookup(name string) []*node {
+	l := n.Children
+	i := sort.Search(len(l), func(i int) bool { return l[i].Name >= name })
+	j := i
+	for j < len(l) && l[j].Name == name {
+		j++
+	}
+	return l[i:j]
+}
+
+func (n *node) Path() string {
+	return filepath.Join(n.ParentPath, n.Name)
+}
+
+type tree struct {
+	lock  sync.RWMutex
+	roots map[string]*node
+	specs map[string]rootSpec
+	flats []*flatIndex
+	// 读 flats 的映射期间持有读锁，Spill 关闭旧索引前等它们读完
+	flatUse  sync.RWMutex
+	sem      chan struct{}
+	indexing bool
+	done     chan error
+	elapsed  time.Duration
+	deferred map[string]bool
+	// 重新扫描过的子目录，覆盖原来索引中这些目录下的条目
+	overlays map[string]overlay
+	// 索引时跳过的路径报告到这里
+	rep *report
+}
+
+func newTree() *tree {
+	n := *scanWorkers - 1
+	if n < 0 {
+		n = 0
+	}
+	return &tree{
+		sem:      make(chan struct{}, n),
+		roots:    make(map[string]*node),
+		specs:    make(map[string]rootSpec),
+		deferred: make(map[string]bool),
+		overlays: make(map[string]overlay),
+		rep:      rep,
+	}
+}
+
+// ScanAsync indexes roots in the background, see Wait.
+func (t *tree) ScanAsync(roots rootSlice, acceptext map[string]bool) {
+	t.indexing = true
+	t.done = make(chan error, 1)
+	go func() {
+		b := time.Now()
+		var err error
+		for _, root := range roots {
+			err = t.ScanRoot(root, acceptext)
+			if err != nil {
+				break
+			}
+			if overMemLimit() {
+				err = t.Spill()
+				if err != nil {
+					break
+				}
+			}
+		}
+		t.lock.Lock()
+		t.indexing = false
+		t.elapsed = time.Now().Sub(b)
+		t.lock.Unlock()
+		t.done <- err
+	}()
+}
+
+func (t *tree) Wait() error {
+	if t.done == nil {
+		return nil
+	}
+	err := <-t.done
+	t.done = nil
+	return err
+}
+
+func (t *tree) Indexing() bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.indexing
+}
+
+// Defer records a source that missed headers while the index was incomplete.
+// It reports false once indexing is finished.
+func (t *tree) Defer(p string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.indexing {
+		return false
+	}
+	t.deferred[p] = true
+	return true
+}
+
+func (t *tree) Deferred() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var ret []string
+	for p := range t.deferred {
+		ret = append(ret, p)
+	}
+	t.deferred = make(map[string]bool)
+	sort.Strings(ret)
+	return ret
+}
+
+func (t *tree) Scan(p string, acceptext map[string]bool) error {
+	p, err := filepath.Abs(msys.Windows(p))
+	if err != nil {
+		return err
+	}
+	info, err := os.Lstat(p)
+	if err != nil {
+		return t.scanError(err)
+	}
+	root := newNode("", "")
+	_, err = t.buildtree(p, info.Mode(), 0, root, acceptext)
+	if err != nil && err != errSkip {
+		return err
+	}
+	root.Sort()
+	t.lock.Lock()
+	t.roots[p] = root
+	t.lock.Unlock()
+	return nil
+}
+
+func (t *tree) ScanRoot(root rootSpec, acceptext map[string]bool) error {
+	p, err := filepath.Abs(msys.Windows(root.Path))
+	if err != nil {
+		return err
+	}
+	t.lock.Lock()
+	t.specs[p] = root
+	t.lock.Unlock()
+	return t.Scan(p, acceptext)
+}
+
+func (t *tree) Search(header string) ([]string, error) {
+	dirs, _, err := t.SearchCase(header)
+	return dirs, err
+}
+
+type scannedRoot struct {
+	path string
+	node *node
+}
+
+// SearchCase is Search also returning, for a header found only ignoring
+// case with -case-insensitive, the names of the headers on disk.
+func (t *tree) SearchCase(header string) ([]string, []string, error) {
+	if len(header) > 0 && header[0] == '/' {
+		header = header[1:]
+	}
+	if dir, ok := mapHeader(header); ok {
+		return []string{dir}, nil, nil
+	}
+	seps := strings.Split(header, string(filepath.Separator))
+
+	var nodes []scannedRoot
+	t.flatUse.RLock()
+	defer t.flatUse.RUnlock()
+	t.lock.RLock()
+	for p, root := range t.roots {
+		nodes = append(nodes, scannedRoot{p, root})
+	}
+	for dir, o := range t.overlays {
+		nodes = append(nodes, scannedRoot{dir, o.node})
+	}
+	flats := t.flats
+	t.lock.RUnlock()
+
+	var ret []string
+	for _, root := range nodes {
+		nodelist := []*node{root.node}
+		for i := len(seps) - 1; i >= 0; i-- {
+			name := seps[i]
+			var nodelist1 []*node
+			for _, n := range nodelist {
+				nodelist1 = append(nodelist1, n.Lookup(name)...)
+			}
+			nodelist = nodelist1
+		}
+		for _, n := range nodelist {
+			dir := filepath.Dir(n.Path())
+			if !t.hidden(filepath.Join(dir, header), root.path) {
+				ret = append(ret, dir)
+			}
+		}
+	}
+	for _, idx := range flats {
+		for _, dir := range idx.Search(header) {
+			if !t.hidden(filepath.Join(dir, header), "") {
+				ret = append(ret, dir)
+			}
+		}
+	}
+	// 只有按原名找不到时才忽略大小写
+	if len(ret) == 0 && *caseInsensitive {
+		dirs, actual := t.searchFold(header, seps, nodes, flats)
+		if len(dirs) != 0 {
+			return dirs, actual, nil
+		}
+	}
+	if len(ret) == 0 {
+		return nil, nil, errNotFound
+	}
+	return ret, nil, nil
+}
+
+// buildtree adds p to the tree of root, mode is the type of p as reported by
+// its directory so entries need no stat of their own, depth its distance from
+// the root.
+func (t *tree) buildtree(p string, mode os.FileMode, depth int, root *node, acceptext map[string]bool) (*node, error) {
+	log := log.New()
+	ppath, name := filepath.Split(p)
+	if name[0] == '.' {
+		return nil, errSkip
+	}
+
+	// 指向文件的链接按链接名加入，单头文件库常这样放在 include 目录里，
+	// 指向目录的链接不跟随，避免循环
+	if mode&os.ModeSymlink != 0 {
+		if info, err := os.Stat(p); err == nil && info.Mode().IsRegular() {
+			mode = info.Mode()
+		}
+	}
+
+	// skip strange files
+	if !mode.IsRegular() && !mode.IsDir() {
+		return nil, errSkip
+	}
+
+	// 如果是文件则加入到根节点
+	if mode.IsRegular() {
+		ext := filepath.Ext(p)
+		if !acceptext[ext] && !(*sniff && sniffHeader(p)) {
+			return nil, errSkip
+		}
+		n := newNode(name, ppath)
+		root.AddChild(n)
+		return n, nil
+	}
+
+	if *maxDepth > 0 && depth > *maxDepth {
+		t.rep.TooDeep(p)
+		return nil, errSkip
+	}
+	log.Debug("scan dir %s", p)
+	// 如果是目录，递归创建父节点，然后把自己加入父节点的子节点中
+	dir, err := os.Open(p)
+	if err != nil {
+		return nil, t.scanError(err)
+	}
+	defer dir.Close()
+
+	n := newNode(name, ppath)
+
+	var wait sync.WaitGroup
+	var errlock sync.Mutex
+	var firstErr error
+	add := func(fullpath string, mode os.FileMode) {
+		parent, err := t.buildtree(fullpath, mode, depth+1, root, acceptext)
+		if err == errSkip {
+			return
+		}
+		if err != nil {
+			errlock.Lock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			errlock.Unlock()
+			return
+		}
+		parent.AddChild(n)
+	}
+	// 分批读取目录，超大的目录不必一次全部载入内存
+	nfiles := 0
+	for {
+		files, err := dir.ReadDir(readDirBatch)
+		for _, file := range files {
+			if isExcluded(file.Name()) {
+				continue
+			}
+			fullpath := filepath.Join(p, file.Name())
+			mode := file.Type()
+			// 有空闲的扫描线程时子目录并发扫描，否则在当前线程扫描
+			if file.IsDir() {
+				select {
+				case t.sem <- struct{}{}:
+					wait.Add(1)
+					go func() {
+						defer wait.Done()
+						add(fullpath, mode)
+						<-t.sem
+					}()
+					continue
+				default:
+				}
+			}
+			add(fullpath, mode)
+		}
+		nfiles += len(files)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			wait.Wait()
+			return nil, t.scanError(err)
+		}
+	}
+	wait.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if nfiles == 0 {
+		return nil, errSkip
+	}
+	return n, nil
+}
+
+// sniffHeader reports whether the head of file p has a preprocessor directive.
+func sniffHeader(p string) bool {
+	f, err := openRegular(p)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	buf := make([]byte, 4096)
+	n, _ := io.ReadFull(f, buf)
+	buf = buf[:n]
+	if bytes.IndexByte(buf, 0) != -1 {
+		return false
+	}
+	for _, line := range bytes.Split(buf, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] != '#' {
+			continue
+		}
+		directive := bytes.TrimSpace(line[1:])
+		for _, d := range []string{"include", "define", "if", "pragma", "undef", "error"} {
+			if bytes.HasPrefix(directive, []byte(d)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (t *tree) scanError(err error) error {
+	if *strictScan || !*skipErrors {
+		return err
+	}
+	log.Debug("skip %s", err)
+	t.rep.ScanError(err)
+	return errSkip
+}
+
+func isLocationKnownHeader(name string) bool {
+	return filepath.IsAbs(name) || msys.IsPosix(name)
+}
+
+func listheaders(ctx context.Context, file string, acceptsuffix map[string]bool, includes []string) ([]string, error) {
+	return scanHeaders(ctx, file, acceptsuffix, includes, fileScanFlags(file), *sniff)
+}
+
+func scanHeaders(ctx context.Context, file string, acceptsuffix map[string]bool, includes []string, extra []string, sniff bool) ([]string, error) {
+	if *depScanner == "native" {
+		return nativeHeaders(ctx, file, acceptsuffix, includes, extra, sniff)
+	}
+	args := func(lang string, extra []string) []string {
+		flags := []string{"-x" + lang, "-M", "-MG"}
+		if isCuda(file) && cudaHostOnly() {
+			flags = append(flags, cudaHostDefines...)
+		}
+		// -H 在 stderr 中按层级列出头文件
+		if *printStats || *htmlFile != "" {
+			flags = append(flags, "-H")
+		}
+		flags = append(flags, extra...)
+		flags = append(flags, includes...)
+		return append(flags, file)
+	}
+
+	lang := scanLang(file)
+	out, stderr, err := runCompilerContext(ctx, args(lang, flagsFor(extra, lang))...)
+	// 后缀不可靠，C 和 C++ 失败后换一种语言重试
+	if lang := retryLang(file); err != nil && lang != "" {
+		if o, e, rerr := runCompilerContext(ctx, args(lang, flagsFor(extra, lang))...); rerr == nil {
+			scanLangs.Set(file, lang)
+			out, stderr = o, e
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%s:%s", err, stderr)
+	}
+	if *printStats || *htmlFile != "" {
+		sessionOf(ctx).stats.Depth(file, includeDepth(stderr))
+	}
+
+	out = out[:len(out)-1]
+	out = bytes.Replace(out, []byte("\\\n"), []byte{}, -1)
+	return filterHeaders(ctx, file, bytes.Split(out, []byte(" "))[1:], acceptsuffix, sniff), nil
+}
+
+// filterHeaders returns the headers of a make rule that need an include dir.
+func filterHeaders(ctx context.Context, file string, deps [][]byte, acceptsuffix map[string]bool, sniff bool) []string {
+	sess := sessionOf(ctx)
+	var ret []string
+	n := 0
+	defer func() { sess.stats.Count(file, n) }()
+	if *sharedCache != "" {
+		l := make([]string, 0, len(deps))
+		for _, dep := range deps {
+			if len(dep) != 0 && string(dep) != file {
+				l = append(l, string(dep))
+			}
+		}
+		sess.stats.Deps(file, l)
+	}
+	for _, header := range deps {
+		if len(header) == 0 {
+			continue
+		}
+		s := string(header)
+		if s == file {
+			continue
+		}
+		n++
+		// with -sniff anything the compiler pulled in is include-like
+		if !acceptsuffix[filepath.Ext(s)] && !sniff {
+			continue
+		}
+		if isLocationKnownHeader(s) {
+			sess.sysUsed.Seen(s)
+			continue
+		}
+		ret = append(ret, s)
+	}
+	return ret
+}
+
+func collect(src string, l *list.List, acceptsuffix map[string]bool) error {
+	src, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if len(name) > 1 && name[0] == '.' || path != src && (isExcluded(name) || dirRules.Excluded(path)) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := filepath.Ext(name)
+		if !acceptsuffix[ext] {
+			return nil
+		}
+		// 和 buildtree 一样只收普通文件和指向它们的链接，
+		// 名为 foo.c 的 FIFO 会让编译器一直阻塞
+		if info.Mode()&os.ModeSymlink != 0 {
+			if target, err := os.Stat(path); err == nil {
+				info = target
+			}
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			rep.SkipFile(path, specialKind(info.Mode()))
+			return nil
+		}
+		// 超大的合并文件和误用后缀的二进制文件白白占用编译器
+		if reason := skipSource(path, info.Size()); reason != "" {
+			rep.SkipFile(path, reason)
+			return nil
+		}
+		l.PushBack(path)
+		return nil
+	})
+	return err
+}
+
+func systemheaders() ([]string, error) {
+	if *depScanner == "native" {
+		return nativeSysDirs(), nil
+	}
+	args := append([]string{"-xc++", "-E", "-v"}, sysrootFlags(scanFlags())...)
+	stdout, stderr, err := runCompiler(append(args, "-")...)
+	if err != nil {
+		return nil, err
+	}
+	out := append(stdout, stderr...)
+
+	var ret []string
+	var started bool
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#include <...> search starts here:") {
+			started = true
+			continue
+		}
+		if strings.HasPrefix(line, "End of search list.") {
+			break
+		}
+
+		if started {
+			ret = append(ret, line)
+		}
+
+	}
+	// 编译器输出被翻译时按格式解析
+	if len(ret) == 0 {
+		ret = verboseSearchDirs(out)
+	}
+	if len(ret) == 0 {
+		ret = installSearchDirs()
+	}
+	// clang 以 MSVC 为目标时已经列出，gcc 不读取 INCLUDE
+	ret = append(ret, msvcIncludes()...)
+	return cleanDirs(ret, nil), nil
+}
+
+func searchSystemHeader(name string, list []string) (string, error) {
+	for _, dir := range list {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return dir, nil
+		}
+	}
+	return "", errNotFound
+}
+
+type printer struct {
+	format *format
+	lock   sync.Mutex
+	m      map[string]bool
+	sys    []string
+	l      []string
+	flags  []string
+	files  []string
+	meta   *metadata
+
+	vendored []string
+	after    func(dir string) bool
+	role     func(dir string) string
+	stats    *statistics
+}
+
+func newPrinter(f *format) *printer {
+	return &printer{
+		format: f,
+		m:      make(map[string]bool),
+		stats:  stats,
+	}
+}
+
+func (p *printer) AddSys(sys []string) {
+	p.sys = sys
+}
+
+func (p *printer) AddVendored(roots []string) {
+	p.vendored = roots
+}
+
+func (p *printer) SetAfter(after func(dir string) bool) {
+	p.after = after
+}
+
+func (p *printer) isAfter(dir string) bool {
+	return p.after != nil && p.after(dir)
+}
+
+func (p *printer) SetRole(role func(dir string) string) {
+	p.role = role
+}
+
+// SetStats sets the statistics the uses of the dirs come from.
+func (p *printer) SetStats(s *statistics) {
+	p.stats = s
+}
+
+func (p *printer) isTest(dir string) bool {
+	return p.role != nil && p.role(dir) == "test"
+}
+
+func (p *printer) AddFiles(files []string) {
+	p.files = files
+}
+
+func (p *printer) SetMeta(m *metadata) {
+	p.meta = m
+}
+
+func (p *printer) AddFlags(flags []string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.flags = dedupFlags(append(p.flags, flags...))
+}
+
+func (p *printer) Printdirs(dirs []string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	log := log.New()
+	for _, h := range dirs {
+		if !p.m[h] {
+			log.Debug("new include dir: %s", h)
+			p.m[h] = true
+			p.l = append(p.l, h)
+		}
+	}
+}
+
+func (p *printer) Len() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return len(p.l)
+}
+
+func (p *printer) Includes() []string {
+	return p.IncludesFor(false)
+}
+
+// IncludesFor returns the include args to scan a source with. Dirs of test
+// roots come first for tests and are left out otherwise.
+func (p *printer) IncludesFor(test bool) []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var ret []string
+	dirs := append([]string{}, p.l...)
+	sort.Strings(dirs)
+	var tests []string
+	for _, dir := range dirs {
+		if p.isTest(dir) {
+			tests = append(tests, "-I"+dir)
+		}
+	}
+	if test {
+		ret = tests
+	}
+	for _, dir := range dirs {
+		if p.isTest(dir) {
+			continue
+		}
+		if p.isAfter(dir) {
+			ret = append(ret, "-idirafter", dir)
+			continue
+		}
+		ret = append(ret, "-I"+dir)
+	}
+	for _, dir := range p.sys {
+		ret = append(ret, "-I"+dir)
+	}
+	return ret
+}
+
+func (p *printer) FlagSet() *flagSet {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	fs := &flagSet{
+		Flags: p.flags,
+		Files: p.files,
+		Meta:  p.meta,
+	}
+	sort.Sort(sort.StringSlice(p.l))
+	for _, h := range cleanDirs(p.l, p.sys) {
+		if underAny(h, p.vendored) || isMsvcInclude(h) {
+			fs.Systems = append(fs.Systems, h)
+			continue
+		}
+		if p.isTest(h) {
+			fs.Test = append(fs.Test, h)
+			continue
+		}
+		if p.isAfter(h) {
+			fs.After = append(fs.After, h)
+			continue
+		}
+		fs.Includes = append(fs.Includes, h)
+	}
+	fs.Uses = p.stats.Uses()
+	fs.splitRare(p.stats)
+	fs.addDirFlags()
+	fs.addMesonFlags()
+	fs.addScanLangs()
+	fs.splitLangs()
+	fs.addCuda()
+	fs.addAsm()
+	fs.foldLangs()
+	fs.translate()
+	fs.mapPaths()
+	return fs
+}
+
+func (p *printer) Flush(name string) error {
+	return writeFlagSet(name, p.format, p.FlagSet())
+}
+
+// searchFile scans p and adds the dirs of its headers to printer, queueing p
+// again if it resolved new headers. Once ctx is done, the compiler is killed
+// and nothing more is added.
+func searchFile(ctx context.Context, p string, headerext map[string]bool, t *tree, printer *printer, lock *sync.Mutex, queue *list.List, retries *retrySet) {
+	log := log.New()
+	sess := sessionOf(ctx)
+
+	if *computedIncl {
+		for _, h := range computed.Check(ctx, p, printer) {
+			dirs, err := t.Search(h)
+			if err != nil {
+				continue
+			}
+			dirs, ok := allowDirs(selectVersions(ctx, scopeDirs(t, p, dirs)))
+			if !ok {
+				continue
+			}
+			log.Debug("computed include %s in %s", h, p)
+			sess.stats.Resolved(p, h, dirs)
+			printer.Printdirs(dirs)
+		}
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+	printer.Printdirs(testDirs(t, p))
+	includes := printer.IncludesFor(isTestSource(t, p))
+	headers, err := dependencies(ctx, p, headerext, includes)
+	// 超时放弃的扫描不再修改结果
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		// 缺少头文件的诊断按头文件汇总到报告里
+		missing := missingHeaders(p, err.Error())
+		if len(missing) == 0 {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		for h, site := range missing {
+			sess.rep.Unresolved(h, site)
+		}
+		return
+	}
+	log.Debug("process %s:%q", p, headers)
+	printer.AddFlags(embedFlags(ctx, p, headers))
+
+	if len(headers) == 0 {
+		return
+	}
+
+	var resolved []string
+	for _, h := range headers {
+		if checkRelative(ctx, p, h) {
+			continue
+		}
+		// 首先尝试从搜索树中搜索
+		dirs, actual, err := t.SearchCase(h)
+		if err == nil {
+			dirs = pins.Resolve(h, selectVersions(ctx, scopeDirs(t, p, dirs)))
+			allowed, ok := allowDirs(dirs)
+			if !ok {
+				sess.rep.Never(h, findIncludeSite(p, h, includes), dirs)
+				continue
+			}
+			dirs = allowed
+			if len(dirs) == 0 {
+				err = errNotFound
+			}
+			if len(actual) != 0 && err == nil && *reportCase {
+				sess.rep.CaseMismatch(h, actual, findIncludeSite(p, h, includes))
+			}
+		}
+		if err != nil {
+			if t.Defer(p) {
+				log.Debug("%s: retry %s after indexing", p, h)
+				continue
+			}
+			if *useLocate {
+				dirs, err = locateFallback(ctx, h, t, headerext)
+			}
+		}
+		if err != nil {
+			sess.rep.Unresolved(h, findIncludeSite(p, h, includes))
+			continue
+		}
+		resolved = append(resolved, h)
+		sess.stats.Resolved(p, h, dirs)
+		printer.Printdirs(dirs)
+	}
+	if len(resolved) != 0 && !retries.Stuck(p, resolved) {
+		lock.Lock()
+		// 头文件的第一个包含者先重新搜索，等待它的文件排在后面，
+		// 那时它找到的目录已经加入
+		if retries.Claim(p, resolved) {
+			queue.PushFront(p)
+		} else {
+			queue.PushBack(p)
+			sess.stats.Defer()
+		}
+		lock.Unlock()
+	}
+}
+
+// isFlagSet reports whether the named flag was given on the command line.
+func isFlagSet(name string) bool {
+	set := false
+	cmdline.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func suffixes(cfg *config) (headerext, srcext map[string]bool) {
+	if cfg.Sniff {
+		*sniff = true
+	}
+	headerext = make(map[string]bool)
+	for _, s := range strings.Split(*headerExtFlag, " ") {
+		headerext[s] = true
+	}
+	for _, s := range cfg.HeaderSuffix {
+		headerext[s] = true
+	}
+	srcext = make(map[string]bool)
+	for _, s := range strings.Split(*srcExtFlag, " ") {
+		srcext[s] = true
+	}
+	for _, s := range cfg.SrcSuffix {
+		srcext[s] = true
+	}
+	return headerext, srcext
+}
+
+func init() {
+	cmdline.Var(&searchroots, "s", "search root, path[:after] to search it with -idirafter")
+	cmdline.IntVar(ccWorkers, "work", runtime.NumCPU(), "deprecated, same as -cc-workers")
+	cmdline.Var(&ccflags, "x", "extra cc flags")
+	cmdline.Var(&scanOnly, "x-scan-only", "extra cc flags used while scanning but not emitted in the outputs")
+	cmdline.Var(&printSystem, "sys", "print system headers get from 'gcc -xc++ -E -v -', or with -sys=used only those that provided a header")
+	cmdline.Var(&headerMaps, "map", "resolve headers included as prefix/x.h from dir/x.h, as prefix/=dir/")
+	cmdline.Var(&pathMaps, "path-map", "rewrite emitted paths starting with from to start with to instead, as from=to")
+	cmdline.Var(&redactions, "redact", "hide a path prefix in the outputs, as prefix[=placeholder], home for the home dir with -format make or relative for paths under the output dir")
+}
+
+// parseFlags parses the options before the src_dir or the subcommand.
+func parseFlags(args []string) error {
+	cmdArgs = args
+	return cmdline.Parse(args)
+}
+
+// checkFlags checks the parsed options.
+func checkFlags() error {
+	err := checkSandbox()
+	if err != nil {
+		return err
+	}
+	err = checkRelativeFlag()
+	if err != nil {
+		return err
+	}
+	err = checkScanner()
+	if err != nil {
+		return err
+	}
+	err = checkValidate()
+	if err != nil {
+		return err
+	}
+	err = checkTestPattern()
+	if err != nil {
+		return err
+	}
+	err = checkForceInclude()
+	if err != nil {
+		return err
+	}
+	err = checkTargetCompiler()
+	if err != nil {
+		return err
+	}
+	err = checkNix()
+	if err != nil {
+		return err
+	}
+	err = checkNDK()
+	if err != nil {
+		return err
+	}
+	err = checkPins()
+	if err != nil {
+		return err
+	}
+	err = checkPrefixes()
+	if err != nil {
+		return err
+	}
+	err = checkNative()
+	if err != nil {
+		return err
+	}
+	err = checkCompdb()
+	if err != nil {
+		return err
+	}
+	err = checkNeverInclude()
+	if err != nil {
+		return err
+	}
+	err = checkTimeouts()
+	if err != nil {
+		return err
+	}
+	err = checkWorkers()
+	if err != nil {
+		return err
+	}
+	err = checkSysFlag()
+	if err != nil {
+		return err
+	}
+	err = checkVersionPolicy()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Main runs clang_complete with the arguments of the process.
+func Main() {
+	// 和 flag.ExitOnError 一样退出，错误已经由 cmdline 打印
+	err := parseFlags(os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+	err = checkFlags()
+	if err != nil {
+		log.Fatal(err)
+	}
+	stopProfile, err := startProfile()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stopProfile()
+
+	// 和子命令同名的源码目录要用 -- 或者 ./name 指定
+	if cmd, ok := commands[cmdline.Arg(0)]; ok && !afterDashes() {
+		err := cmd.run(cmdline.Args()[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *workerAddr != "" {
+		err = runWorker(*workerAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *coordinatorAddr != "" {
+		err = startCoordinator(*coordinatorAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		workers.Wait(*waitWorkers, time.Minute)
+	}
+
+	if cmdline.NArg() < 1 {
+		fmt.Println("usage clang_complete [options] [--] src_dir")
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Println("      clang_complete " + commands[name].usage)
+		}
+	}
+	srcroot := cmdline.Arg(0)
+	srcroot, err = filepath.Abs(srcroot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if isArchive(srcroot) {
+		dir, tmp, err := extractSource(srcroot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer os.RemoveAll(tmp)
+		atExit(func() { os.RemoveAll(tmp) })
+		srcroot = dir
+	}
+	dirRules.SetRoot(srcroot)
+	versionRoot = srcroot
+	embedRoot = srcroot
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = cfg.apply()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(searchroots) == 0 {
+		// 没有 -s 时在源码目录里找 include 目录，并告诉用户用了哪些
+		for _, root := range defaultRoots(srcroot) {
+			searchroots.Set(root)
+		}
+		fmt.Fprintf(os.Stderr, "no -s given, searching %s\n", strings.Join(searchroots.Paths(), " "))
+	}
+	resources.roots = append([]string{srcroot}, searchroots.Paths()...)
+	headerext, srcext := suffixes(cfg)
+	if *learnSuffixes {
+		learn(append([]string{srcroot}, searchroots.Paths()...), headerext, srcext)
+	}
+
+	format, err := lookupFormat(*outFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = checkRedact(format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !isFlagSet("o") {
+		*output = format.output
+	}
+	if len(cfg.Configurations) != 0 && *configName == "" {
+		err = runConfigurations(cfg, *output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *checkStale {
+		l := list.New()
+		err = collect(srcroot, l, srcext)
+		if err != nil {
+			log.Fatal(err)
+		}
+		reason, err := checkOutput(*output, format, l)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if reason != "" {
+			fmt.Fprintf(os.Stderr, "%s is stale: %s\n", *output, reason)
+			runExitCleanups()
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 比较写入前后的文件，两边都经过了 -redact 和路径映射
+	var previous []string
+	if *notifyTarget != "" || *auditLog != "" {
+		previous = outputLines(*output, format)
+	}
+
+	sandboxAllow(srcroot)
+	for _, root := range searchroots.Paths() {
+		if abs, err := filepath.Abs(root); err == nil {
+			sandboxAllow(abs)
+		}
+	}
+	err = stageHeaderMaps(srcroot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	setCaseRoot(srcroot)
+
+	if *fixtureDir != "" {
+		err = startFixture(*fixtureDir, srcroot, searchroots, func(path string) bool {
+			ext := filepath.Ext(path)
+			return headerext[ext] || srcext[ext]
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	printer := newPrinter(format)
+
+	// 获取系统搜索目录
+	sysheaders, err := systemheaders()
+	if err != nil {
+		log.Fatal(err)
+	}
+	printer.AddSys(sysheaders)
+
+	if printSystem == "true" {
+		printer.Printdirs(sysheaders)
+	}
+
+	if *baseline != "" {
+		dirs, flags, err := loadBaseline(*baseline)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printer.Printdirs(dirs)
+		ccflags = dedupFlags(append(flags, ccflags...))
+	}
+	if *xcodeproj != "" {
+		dirs, flags, err := xcodeFlags(*xcodeproj)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printer.Printdirs(dirs)
+		ccflags = dedupFlags(append(ccflags, flags...))
+	}
+	covered := make(map[string]bool)
+	if *mesonBuild != "" {
+		mesonSources, err = mesonFlags(*mesonBuild)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for src := range mesonSources {
+			covered[src] = true
+		}
+		// 没有按文件参数的格式只能合并所有目标的参数
+		if format.name != "compdb" {
+			dirs, flags := mesonUnion(mesonSources)
+			printer.Printdirs(dirs)
+			ccflags = dedupFlags(append(ccflags, flags...))
+			mesonSources = nil
+		}
+	}
+	if *gnOut != "" {
+		dirs, flags, sources, err := gnFlags(*gnOut)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printer.Printdirs(dirs)
+		ccflags = dedupFlags(append(ccflags, flags...))
+		for _, src := range sources {
+			covered[src] = true
+		}
+	}
+	if *qmakePro != "" {
+		dirs, flags, err := qmakeFlags(*qmakePro)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printer.Printdirs(dirs)
+		ccflags = dedupFlags(append(ccflags, flags...))
+	}
+	if *androidBuild {
+		dirs, flags, err := androidBuildFlags(srcroot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printer.Printdirs(dirs)
+		ccflags = dedupFlags(append(ccflags, flags...))
+	}
+	printer.AddFlags(ccflags)
+	printer.AddFlags(targetFlags())
+
+	var vendored []string
+	if *detectVendor {
+		vendored = findVendored(srcroot)
+		printer.AddVendored(vendored)
+		rep.Vendored(vendored)
+	}
+
+	// 构造搜索树，和依赖搜索同时进行
+	t := newTree()
+	b := time.Now()
+	printer.SetAfter(t.After)
+	printer.SetRole(t.Role)
+	roots := searchroots
+	if *loadIndex != "" {
+		idx, err := openIndex(*loadIndex)
+		switch {
+		case err == errStaleIndex:
+			fmt.Fprintf(os.Stderr, "%s: %s, scanning its roots again\n", *loadIndex, err)
+		case err != nil:
+			log.Fatal(err)
+		default:
+			defer idx.Close()
+			roots = uncovered(searchroots, t.Load(idx))
+		}
+	}
+	t.ScanAsync(roots, headerext)
+
+	// 构造源码列表
+	l := list.New()
+	err = collect(srcroot, l, srcext)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var sources []string
+	for e := l.Front(); e != nil; e = e.Next() {
+		sources = append(sources, e.Value.(string))
+	}
+	printer.AddFiles(sources)
+	if *detectConfig {
+		// 要沿着包含的头文件找 config.h，需要等索引完成
+		err = t.Wait()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if flags := configIncludes(srcroot, sources, t); len(flags) != 0 {
+		ccflags = dedupFlags(append(ccflags, flags...))
+		printer.AddFlags(ccflags)
+	}
+	if *writeMeta {
+		printer.SetMeta(newMetadata(sources))
+	}
+	// 构建系统已经给出参数的源码不需要再搜索
+	for e := l.Front(); e != nil; {
+		next := e.Next()
+		if covered[e.Value.(string)] {
+			l.Remove(e)
+		}
+		e = next
+	}
+
+	var shared *sharedSources
+	if *sharedCache != "" {
+		// 缓存的键包含索引的版本，需要等索引完成
+		err = t.Wait()
+		if err != nil {
+			log.Fatal(err)
+		}
+		shared = newSharedSources(t, srcroot)
+		n := l.Len()
+		for e := l.Front(); e != nil; {
+			next := e.Next()
+			if dirs, ok := shared.Get(e.Value.(string)); ok {
+				printer.Printdirs(dirs)
+				stats.Use(e.Value.(string), dirs)
+				l.Remove(e)
+			}
+			e = next
+		}
+		fmt.Fprintf(os.Stderr, "shared cache: reused %d of %d sources\n", n-l.Len(), n)
+	}
+
+	lock := new(sync.Mutex)
+	retries := newRetrySet(rep)
+	// 广度优先搜索
+	bsearch := time.Now()
+	total := l.Len()
+	scanned := make(map[string]bool)
+	stopped := ""
+	// 超时的源码不再重新扫描
+	hung := make(map[string]bool)
+	tuner := newWorkerTuner()
+	var indexDeadline time.Time
+	if *indexTimeout > 0 {
+		indexDeadline = b.Add(*indexTimeout)
+	}
+	for {
+		if budgetExhausted() {
+			stopped = fmt.Sprintf("time budget of %s exhausted", *timeBudget)
+			break
+		}
+		if l.Len() == 0 {
+			err = t.WaitUntil(indexDeadline)
+			if err == errIndexTimeout {
+				rep.TimedOut(fmt.Sprintf("index after %s, roots not indexed", *indexTimeout), t.pending(roots))
+				flushPartial(printer, *output)
+				if *onTimeout == "exit" {
+					stopped = fmt.Sprintf("index timeout of %s", *indexTimeout)
+					break
+				}
+				err = nil
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			// 索引完成之前没有找到头文件的源码需要重新搜索
+			for _, p := range t.Deferred() {
+				if !hung[p] {
+					l.PushBack(p)
+				}
+			}
+			// 宏展开的头文件在新的搜索目录加入后可能已经可以解析
+			for _, p := range computed.Retry(printer.Len()) {
+				if !hung[p] {
+					l.PushBack(p)
+				}
+			}
+			if l.Len() == 0 {
+				break
+			}
+		}
+		queue := list.New()
+		width := tuner.Workers() + workers.Slots()
+		round := width
+		switch *depScanner {
+		case "clang-scan-deps":
+			// 整轮文件一次交给 clang-scan-deps
+			var files []string
+			for e := l.Front(); e != nil; e = e.Next() {
+				files = append(files, e.Value.(string))
+			}
+			err = batch.Scan(files, headerext, printer.Includes())
+			if err != nil {
+				log.Fatal(err)
+			}
+			round = len(files)
+		case "cc-batch":
+			// 每个编译器进程处理多个文件
+			round = width * *batchSize
+			var files []string
+			for e := l.Front(); e != nil && len(files) < round; e = e.Next() {
+				files = append(files, e.Value.(string))
+			}
+			batch.ScanCC(files, headerext, printer.Includes())
+			round = len(files)
+		}
+		pool := newPool(width)
+		// 超时后取消这一轮还在运行的扫描
+		ctx, cancel := context.WithCancel(context.Background())
+		bround := time.Now()
+		var latency int64
+		files := 0
+		running := make(map[string]bool)
+		for n := round; l.Len() != 0 && n > 0 && !budgetExhausted(); n-- {
+			e := l.Front()
+			l.Remove(e)
+			p := e.Value.(string)
+			scanned[p] = true
+			rel, _ := filepath.Rel(srcroot, p)
+			fmt.Fprintln(os.Stderr, rel)
+			files++
+			lock.Lock()
+			running[p] = true
+			lock.Unlock()
+			pool.Run(func() {
+				b := time.Now()
+				searchFile(ctx, p, headerext, t, printer, lock, queue, retries)
+				atomic.AddInt64(&latency, int64(time.Since(b)))
+				lock.Lock()
+				delete(running, p)
+				lock.Unlock()
+			})
+		}
+		if !pool.WaitTimeout(*roundTimeout) {
+			// 取消前记下仍在运行的扫描，取消后它们很快返回
+			lock.Lock()
+			var left []string
+			for p := range running {
+				left = append(left, p)
+			}
+			lock.Unlock()
+			// 杀掉卡住的编译器，扫描返回时不再修改结果
+			cancel()
+			pool.WaitTimeout(time.Second)
+			lock.Lock()
+			for _, p := range left {
+				delete(scanned, p)
+				hung[p] = true
+			}
+			l.PushFrontList(queue)
+			queue = list.New()
+			lock.Unlock()
+			sort.Strings(left)
+			rep.TimedOut(fmt.Sprintf("round after %s, scans still running", *roundTimeout), left)
+			flushPartial(printer, *output)
+			if *onTimeout == "exit" {
+				stopped = fmt.Sprintf("round timeout of %s", *roundTimeout)
+				break
+			}
+			continue
+		}
+		cancel()
+		if *depScanner != "clang-scan-deps" {
+			tuner.Observe(files, time.Since(bround), time.Duration(latency))
+		}
+		l.PushFrontList(queue)
+	}
+	if stopped != "" {
+		// 没有扫描到的源码使用上次的结果
+		addCached(printer, *output, format)
+		printCoverage(os.Stderr, stopped, len(scanned), total)
+	}
+	if shared != nil {
+		err = shared.Put(scanned, searchDirs(printer.Includes()))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	if *regenScript != "" {
+		err = writeRegen(*regenScript)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *inventoryFile != "" {
+		err = writeInventory(*inventoryFile, srcroot, vendored)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *ownersFile != "" {
+		err = writeOwners(*ownersFile, srcroot)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *saveIndex != "" {
+		err = t.Save(*saveIndex)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	tsearch := time.Now().Sub(bsearch)
+	ttotal := time.Now().Sub(b)
+	if printSystem == "used" {
+		printer.Printdirs(sysUsed.Dirs(sysheaders))
+	}
+	for _, problem := range printer.Validate(headerext) {
+		fmt.Fprintf(os.Stderr, "include dir %s\n", problem)
+	}
+	if *exportPins != "" {
+		err = pins.Export(*exportPins, printer.searchOrder())
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *checkShadowing {
+		rep.Shadowed(findShadowing(printer.searchOrder()))
+	}
+	err = printer.Flush(*output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *fixtureDir != "" {
+		err = finishFixture(format, *output, printer.FlagSet())
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	var written []string
+	if *notifyTarget != "" || *auditLog != "" {
+		written = outputLines(*output, format)
+	}
+	err = notifyChanges(*output, previous, written)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "notify:", err)
+	}
+	err = auditChanges(*output, previous, written)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "audit log:", err)
+	}
+	fmt.Fprintf(os.Stderr, "total:%.2fs index:%.2fs search:%.2fs\n",
+		ttotal.Seconds(), t.elapsed.Seconds(), tsearch.Seconds())
+	if *sarifFile != "" {
+		err = rep.WriteSARIF(*sarifFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *htmlFile != "" {
+		err = writeHTML(*htmlFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	rep.Print(os.Stderr)
+	if *printStats {
+		stats.Print(os.Stderr)
+	}
+	watch(srcroot, srcext, searchroots.Paths())
+}
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// make variables of the language sections
+var langMakeVar = map[string]string{
+	"c":             "CFLAGS",
+	"c++":           "CXXFLAGS",
+	"objective-c":   "OBJCFLAGS",
+	"objective-c++": "OBJCXXFLAGS",
+	"cuda":          "CUDAFLAGS",
+}
+
+func makeQuote(s string) string {
+	s = strings.Replace(s, "$", "$$", -1)
+	// -redact home 的占位符由 make 展开
+	s = strings.Replace(s, "$$(HOME)", "$(HOME)", -1)
+	s = strings.Replace(s, "#", `\#`, -1)
+	if strings.ContainsAny(s, " \t'\"") {
+		s = "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+	}
+	return s
+}
+
+func makeUnquote(s string) string {
+	s = strings.Replace(s, "$$", "$", -1)
+	return strings.Replace(s, `\#`, "#", -1)
+}
+
+func writeMake(w io.Writer, fs *flagSet) error {
+	bw := bufio.NewWriter(w)
+	if fs.Meta != nil {
+		fs.Meta.writeComments(bw)
+	}
+	write := func(name string, args []string) {
+		for _, g := range flagGroups(args) {
+			var l []string
+			for _, arg := range g {
+				l = append(l, makeQuote(arg))
+			}
+			fmt.Fprintf(bw, "%s += %s\n", name, strings.Join(l, " "))
+		}
+	}
+	write("CPPFLAGS", fs.Args())
+	for _, lang := range fs.Langs() {
+		write(langMakeVar[lang], fs.Lang[lang])
+	}
+	return bw.Flush()
+}
+
+func readMake(r io.Reader, base string) (*flagSet, error) {
+	langs := make(map[string]string)
+	for lang, name := range langMakeVar {
+		langs[name] = lang
+	}
+	var args []string
+	sections := make(map[string][]string)
+	meta := new(metadata)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || meta.parseComment(line) {
+			continue
+		}
+		i := strings.Index(line, "+=")
+		if i < 0 {
+			continue
+		}
+		name := strings.TrimSpace(line[:i])
+		var l []string
+		for _, arg := range splitQuoted(line[i+2:]) {
+			l = append(l, makeUnquote(arg))
+		}
+		if name == "CPPFLAGS" {
+			args = append(args, l...)
+		} else if lang, ok := langs[name]; ok {
+			sections[lang] = append(sections[lang], l...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	fs := new(flagSet)
+	fs.addArgs(args, base)
+	for lang, flags := range sections {
+		fs.addLang(lang, flags)
+	}
+	fs.setMeta(meta)
+	return fs, nil
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var mesonBuild = cmdline.String("meson", "", "take the include dirs and compile args of the sources of a meson build dir from their targets, per source with -format compdb")
+
+type mesonTarget struct {
+	Name          string `json:"name"`
+	TargetSources []struct {
+		Language   string   `json:"language"`
+		Parameters []string `json:"parameters"`
+		Sources    []string `json:"sources"`
+	} `json:"target_sources"`
+}
+
+func mesonIntrospect(builddir string, v interface{}, args ...string) error {
+	args = append([]string{"introspect", builddir}, args...)
+	out, err := exec.Command("meson", args...).Output()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(out, v)
+}
+
+// mesonSources are the include dirs and preprocessor flags of the sources of
+// the -meson targets, each from its own target.
+var mesonSources map[string][]string
+
+// mesonFlags returns the include dirs, as -I, and remaining preprocessor args
+// of each source of the targets of builddir. The parameters of a target
+// already carry the compile args of its dependencies.
+func mesonFlags(builddir string) (map[string][]string, error) {
+	builddir, err := filepath.Abs(builddir)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []mesonTarget
+	err = mesonIntrospect(builddir, &targets, "--targets")
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string][]string)
+	for _, t := range targets {
+		for _, ts := range t.TargetSources {
+			dirs, flags := splitIncludes(ts.Parameters, builddir)
+			var args []string
+			for _, dir := range dirs {
+				args = append(args, "-I"+dir)
+			}
+			args = append(args, flags...)
+			for _, src := range ts.Sources {
+				if !filepath.IsAbs(src) {
+					src = filepath.Join(builddir, src)
+				}
+				src = filepath.Clean(src)
+				// 同一个源码属于多个目标时合并它们的参数
+				ret[src] = dedupFlags(append(ret[src], args...))
+			}
+		}
+	}
+	return ret, nil
+}
+
+// mesonUnion returns the include dirs and flags of all the sources, for the
+// formats that have no per-file flags.
+func mesonUnion(sources map[string][]string) (dirs []string, flags []string) {
+	var names []string
+	for src := range sources {
+		names = append(names, src)
+	}
+	sort.Strings(names)
+	var args []string
+	for _, src := range names {
+		args = append(args, sources[src]...)
+	}
+	dirs, flags = splitIncludes(args, "")
+	return dedup(dirs), dedupFlags(flags)
+}
+
+// addMesonFlags gives the sources of the -meson targets the flags of their
+// target.
+func (fs *flagSet) addMesonFlags() {
+	for _, file := range fs.Files {
+		args, ok := mesonSources[file]
+		if !ok {
+			continue
+		}
+		if fs.FileFlags == nil {
+			fs.FileFlags = make(map[string][]string)
+		}
+		fs.FileFlags[file] = append(fs.FileFlags[file], args...)
+	}
+}
+
+// splitIncludes separates -I dirs from the preprocessor flags in args, dropping
+// everything that does not affect header lookup or macros.
+func splitIncludes(args []string, base string) (dirs []string, flags []string) {
+	abs := func(p string) string {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(base, p)
+		}
+		return filepath.Clean(p)
+	}
+	for _, g := range flagGroups(args) {
+		opt := g[0]
+		switch {
+		case opt == "-I" && len(g) == 2:
+			dirs = append(dirs, abs(g[1]))
+		case strings.HasPrefix(opt, "-I"):
+			dirs = append(dirs, abs(opt[2:]))
+		case (opt == "-isystem" || opt == "-idirafter" || opt == "-iquote") && len(g) == 2:
+			flags = append(flags, opt, abs(g[1]))
+		case (opt == "-D" || opt == "-U" || opt == "-include") && len(g) == 2:
+			flags = append(flags, g...)
+		case strings.HasPrefix(opt, "-D"), strings.HasPrefix(opt, "-U"),
+			strings.HasPrefix(opt, "-std="), strings.HasPrefix(opt, "-isystem"):
+			flags = append(flags, opt)
+		}
+	}
+	return dirs, flags
+}
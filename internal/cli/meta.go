@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"bufio"
+	"container/list"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const version = "0.2.0"
+
+var (
+	writeMeta  = cmdline.Bool("meta", false, "record generation metadata in the output")
+	checkStale = cmdline.Bool("check", false, "exit with status 1 if the output is stale relative to src_dir")
+)
+
+// generatedMarker starts the files clang_complete writes with comments.
+const generatedMarker = "# generated by clang_complete"
+
+type metadata struct {
+	Version  string
+	Command  string
+	TreeHash string
+	Time     string
+}
+
+func newMetadata(sources []string) *metadata {
+	return &metadata{
+		Version:  version,
+		Command:  strings.Join(os.Args, " "),
+		TreeHash: treeHash(sources),
+		Time:     time.Now().Format(time.RFC3339),
+	}
+}
+
+// treeHash fingerprints the source list by path, size and mtime.
+func treeHash(sources []string) string {
+	h := sha1.New()
+	for _, p := range sources {
+		info, err := os.Stat(p)
+		if err != nil {
+			fmt.Fprintf(h, "%s\x00\x00", p)
+			continue
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00", p, info.Size(), info.ModTime().UnixNano())
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func (m *metadata) fields() [][2]string {
+	return [][2]string{
+		{"version", m.Version},
+		{"command", m.Command},
+		{"tree", m.TreeHash},
+		{"time", m.Time},
+	}
+}
+
+// writeComments writes m as "# key: value" lines.
+func (m *metadata) writeComments(w io.Writer) {
+	fmt.Fprintln(w, generatedMarker)
+	for _, f := range m.fields() {
+		fmt.Fprintf(w, "# %s: %s\n", f[0], f[1])
+	}
+}
+
+// parseComment fills m from a "# key: value" line and reports whether it was one.
+func (m *metadata) parseComment(line string) bool {
+	if !strings.HasPrefix(line, "#") {
+		return false
+	}
+	line = strings.TrimSpace(line[1:])
+	i := strings.Index(line, ": ")
+	if i == -1 {
+		return true
+	}
+	value := line[i+2:]
+	switch line[:i] {
+	case "version":
+		m.Version = value
+	case "command":
+		m.Command = value
+	case "tree":
+		m.TreeHash = value
+	case "time":
+		m.Time = value
+	}
+	return true
+}
+
+// isGenerated reports whether the file name starts with generatedMarker.
+func isGenerated(name string) bool {
+	f, err := os.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	return scanner.Scan() && scanner.Text() == generatedMarker
+}
+
+func metaFile(output string) string {
+	return output + ".meta"
+}
+
+func loadMeta(name string) (*metadata, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := new(metadata)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m.parseComment(strings.TrimSpace(scanner.Text()))
+	}
+	return m, scanner.Err()
+}
+
+// checkOutput reports why output is stale, or "" if it is up to date.
+func checkOutput(output string, f *format, sources *list.List) (string, error) {
+	fs, err := readFlagSet(output, f)
+	if err != nil {
+		return "", err
+	}
+	if fs.Meta == nil || fs.Meta.TreeHash == "" {
+		return "no generation metadata, regenerate with -meta", nil
+	}
+	var l []string
+	for e := sources.Front(); e != nil; e = e.Next() {
+		l = append(l, e.Value.(string))
+	}
+	if fs.Meta.Version != version {
+		return fmt.Sprintf("generated by version %s, current is %s", fs.Meta.Version, version), nil
+	}
+	if treeHash(l) != fs.Meta.TreeHash {
+		return "sources changed since " + fs.Meta.Time, nil
+	}
+	return "", nil
+}
+
+// stale reports whether output is stale relative to the sources under
+// srcroot, and why, for editors deciding to regenerate without a scan.
+func stale(output, srcroot string, srcext map[string]bool) (bool, string, error) {
+	l := list.New()
+	if err := collect(srcroot, l, srcext); err != nil {
+		return false, "", err
+	}
+	reason, err := checkOutput(output, formatOf(output), l)
+	if err != nil {
+		return false, "", err
+	}
+	return reason != "", reason, nil
+}
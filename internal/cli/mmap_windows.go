@@ -0,0 +1,11 @@
+package cli
+
+import "io/ioutil"
+
+func mmapFile(name string) ([]byte, func() error, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+var (
+	msvcOnce sync.Once
+	msvcDirs []string
+)
+
+// msvcIncludes returns the dirs of the INCLUDE variable vcvarsall and the
+// Visual Studio developer prompts set, the system headers of the MSVC
+// toolchain. It is empty outside windows, where INCLUDE is not a convention.
+func msvcIncludes() []string {
+	msvcOnce.Do(func() {
+		if runtime.GOOS != "windows" {
+			return
+		}
+		for _, dir := range filepath.SplitList(os.Getenv("INCLUDE")) {
+			if dir == "" {
+				continue
+			}
+			dir = filepath.Clean(dir)
+			if info, err := os.Stat(dir); err == nil && info.IsDir() {
+				msvcDirs = append(msvcDirs, dir)
+			}
+		}
+		msvcDirs = dedup(msvcDirs)
+	})
+	return msvcDirs
+}
+
+func isMsvcInclude(dir string) bool {
+	for _, d := range msvcIncludes() {
+		if d == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// systemFlag returns the option of system include dirs for -target-compiler,
+// clang-cl taking them with -imsvc.
+func systemFlag() string {
+	if *targetCompiler == "clang-cl" {
+		return "-imsvc"
+	}
+	return "-isystem"
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+var msysFlag = cmdline.String("msys", "auto", "translate MSYS2/MinGW paths to windows paths with cygpath: auto, on or off")
+
+type msysTranslator struct {
+	once    sync.Once
+	enabled bool
+	// cygpath converts a path to its windows form
+	cygpath func(p string) (string, error)
+	lock    sync.Mutex
+	cache   map[string]string
+}
+
+var msys = &msysTranslator{cygpath: runCygpath, cache: make(map[string]string)}
+
+func runCygpath(p string) (string, error) {
+	out, err := exec.Command("cygpath", "-w", p).Output()
+	if err != nil {
+		return "", err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Scan()
+	return scanner.Text(), nil
+}
+
+func (m *msysTranslator) Enabled() bool {
+	m.once.Do(func() {
+		switch *msysFlag {
+		case "on":
+			m.enabled = true
+		case "auto":
+			_, err := exec.LookPath("cygpath")
+			m.enabled = os.Getenv("MSYSTEM") != "" && err == nil
+		}
+	})
+	return m.enabled
+}
+
+// IsPosix reports whether p is an MSYS style absolute path like /mingw64/include.
+func (m *msysTranslator) IsPosix(p string) bool {
+	return m.Enabled() && strings.HasPrefix(p, "/")
+}
+
+// Windows translates MSYS style paths to windows paths, other paths are
+// returned unchanged.
+func (m *msysTranslator) Windows(p string) string {
+	if !m.IsPosix(p) {
+		return p
+	}
+	m.lock.Lock()
+	w, ok := m.cache[p]
+	m.lock.Unlock()
+	if ok {
+		return w
+	}
+
+	w, err := m.cygpath(p)
+	if err != nil || w == "" {
+		w = p
+	}
+	m.lock.Lock()
+	m.cache[p] = w
+	m.lock.Unlock()
+	return w
+}
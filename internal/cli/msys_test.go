@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeCygpath converts like cygpath -w in an MSYS2 installed at C:\msys64,
+// counting the calls.
+func fakeCygpath(calls *int) func(p string) (string, error) {
+	return func(p string) (string, error) {
+		*calls++
+		if strings.HasPrefix(p, "/fail/") {
+			return "", errors.New("cygpath: failed")
+		}
+		rest := strings.TrimPrefix(p, "/cygdrive")
+		if len(rest) >= 2 && rest[0] == '/' && (len(rest) == 2 || rest[2] == '/') {
+			drive := strings.ToUpper(rest[1:2])
+			return drive + ":\\" + strings.Replace(strings.TrimPrefix(rest[2:], "/"), "/", "\\", -1), nil
+		}
+		return `C:\msys64` + strings.Replace(p, "/", "\\", -1), nil
+	}
+}
+
+// testMsys returns a translator with the -msys mode given and cygpath faked.
+func testMsys(t *testing.T, mode string, calls *int) *msysTranslator {
+	old := *msysFlag
+	*msysFlag = mode
+	t.Cleanup(func() { *msysFlag = old })
+	return &msysTranslator{cygpath: fakeCygpath(calls), cache: make(map[string]string)}
+}
+
+func TestMsysWindows(t *testing.T) {
+	tests := []struct {
+		path    string
+		windows string
+		posix   bool
+	}{
+		// MSYS2 和 MinGW 的安装目录
+		{"/mingw64/include", `C:\msys64\mingw64\include`, true},
+		{"/usr/include/w32api", `C:\msys64\usr\include\w32api`, true},
+		// MSYS2 的盘符路径
+		{"/c/Users/dev/include", `C:\Users\dev\include`, true},
+		{"/d", `D:\`, true},
+		// cygwin 的盘符路径
+		{"/cygdrive/c/Program Files/include", `C:\Program Files\include`, true},
+		// 已经是 windows 路径的不转换
+		{`C:\msys64\mingw64\include`, `C:\msys64\mingw64\include`, false},
+		{"C:/msys64/mingw64/include", "C:/msys64/mingw64/include", false},
+		{`\\server\share\include`, `\\server\share\include`, false},
+		{"include/sub", "include/sub", false},
+		// cygpath 失败时保留原路径
+		{"/fail/include", "/fail/include", true},
+	}
+	var calls int
+	m := testMsys(t, "on", &calls)
+	for _, tt := range tests {
+		if posix := m.IsPosix(tt.path); posix != tt.posix {
+			t.Errorf("IsPosix(%q) = %v, want %v", tt.path, posix, tt.posix)
+		}
+		if w := m.Windows(tt.path); w != tt.windows {
+			t.Errorf("Windows(%q) = %q, want %q", tt.path, w, tt.windows)
+		}
+	}
+
+	calls = 0
+	for _, tt := range tests {
+		m.Windows(tt.path)
+	}
+	if calls != 0 {
+		t.Errorf("cygpath called %d times for cached paths", calls)
+	}
+}
+
+func TestMsysOff(t *testing.T) {
+	var calls int
+	m := testMsys(t, "off", &calls)
+	for _, p := range []string{"/mingw64/include", "/c/Users", "/cygdrive/c/x"} {
+		if m.IsPosix(p) {
+			t.Errorf("IsPosix(%q) with -msys off", p)
+		}
+		if w := m.Windows(p); w != p {
+			t.Errorf("Windows(%q) = %q with -msys off", p, w)
+		}
+	}
+	if calls != 0 {
+		t.Errorf("cygpath called %d times with -msys off", calls)
+	}
+}
+
+func TestMsysMapPath(t *testing.T) {
+	var calls int
+	old, oldMaps := msys, pathMaps
+	msys = testMsys(t, "on", &calls)
+	t.Cleanup(func() { msys, pathMaps = old, oldMaps })
+	pathMaps = pathMapSlice{{from: filepath.Clean("/home/dev/src"), to: filepath.Clean("/c/src")}}
+
+	tests := []struct {
+		path, mapped string
+	}{
+		{"/home/dev/src/inc", `C:\src\inc`},
+		{"/mingw64/include", `C:\msys64\mingw64\include`},
+		{`D:\sdk\include`, `D:\sdk\include`},
+	}
+	for _, tt := range tests {
+		if p := mapPath(tt.path); p != tt.mapped {
+			t.Errorf("mapPath(%q) = %q, want %q", tt.path, p, tt.mapped)
+		}
+	}
+
+	fs := &flagSet{Includes: []string{"/mingw64/include"}}
+	fs.mapPaths()
+	if want := `C:\msys64\mingw64\include`; len(fs.Includes) != 1 || fs.Includes[0] != want {
+		t.Errorf("mapPaths = %q, want %q", fs.Includes, want)
+	}
+}
+
+// TestCygpath checks the real cygpath where MSYS2 or cygwin is installed.
+func TestCygpath(t *testing.T) {
+	if _, err := exec.LookPath("cygpath"); err != nil {
+		t.Skip("cygpath not found")
+	}
+	for _, p := range []string{"/usr/include", "/c/Windows", "/cygdrive/c/Windows"} {
+		w, err := runCygpath(p)
+		if err != nil {
+			t.Fatalf("cygpath -w %s: %s", p, err)
+		}
+		if len(w) < 3 || w[1] != ':' || w[2] != '\\' {
+			t.Errorf("cygpath -w %s = %q, want a windows path", p, w)
+		}
+	}
+}
@@ -0,0 +1,669 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// nativeDepth bounds the nesting of includes followed by the native scanner.
+const nativeDepth = 200
+
+// checkNative falls back to the native scanner when the compiler is missing,
+// so trees can still be scanned on machines without one.
+func checkNative() error {
+	if *depScanner != "cc" && *depScanner != "cc-batch" {
+		return nil
+	}
+	if _, err := exec.LookPath(compiler()); err == nil {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "compiler %s not found, scanning includes without it\n", compiler())
+	*depScanner = "native"
+	return nil
+}
+
+var nativeSys struct {
+	sync.Once
+	dirs []string
+}
+
+// nativeSysDirs guesses the system include dirs of a machine without a
+// compiler from the environment and the usual places.
+func nativeSysDirs() []string {
+	nativeSys.Do(func() {
+		var dirs []string
+		for _, env := range []string{"CPATH", "C_INCLUDE_PATH", "CPLUS_INCLUDE_PATH", "INCLUDE"} {
+			dirs = append(dirs, filepath.SplitList(os.Getenv(env))...)
+		}
+		if runtime.GOOS != "windows" {
+			// 只取最新版本的 libstdc++
+			if l, _ := filepath.Glob("/usr/include/c++/*"); len(l) != 0 {
+				sort.Strings(l)
+				dirs = append(dirs, l[len(l)-1])
+			}
+			if l, _ := filepath.Glob("/usr/include/*-linux-gnu/c++/*"); len(l) != 0 {
+				sort.Strings(l)
+				dirs = append(dirs, l[len(l)-1])
+			}
+			l, _ := filepath.Glob("/usr/include/*-linux-gnu")
+			dirs = append(dirs, l...)
+			dirs = append(dirs, "/usr/local/include", "/usr/include")
+		}
+		for _, dir := range dirs {
+			if info, err := os.Stat(dir); err == nil && info.IsDir() {
+				nativeSys.dirs = append(nativeSys.dirs, dir)
+			}
+		}
+		nativeSys.dirs = cleanDirs(nativeSys.dirs, nil)
+	})
+	return nativeSys.dirs
+}
+
+// searchDirs returns the dirs of the include flags, in search order.
+func searchDirs(includes []string) []string {
+	var dirs []string
+	for _, g := range flagGroups(includes) {
+		switch {
+		case len(g) == 2:
+			dirs = append(dirs, g[1])
+		case strings.HasPrefix(g[0], "-I"):
+			dirs = append(dirs, g[0][2:])
+		}
+	}
+	return dirs
+}
+
+type macro struct {
+	value string
+	// function-like macros are never expanded in conditions
+	function bool
+}
+
+// nativeScanner follows the includes of a source like the preprocessor,
+// evaluating conditionals with the macros it has seen. It reports what -M -MG
+// would: the paths of the headers found and the names of the others.
+type nativeScanner struct {
+	dirs   []string
+	sys    []string
+	macros map[string]macro
+	seen   map[string]bool
+	deps   []string
+}
+
+func newNativeScanner(file string, includes, extra []string) *nativeScanner {
+	s := &nativeScanner{
+		dirs:   searchDirs(includes),
+		sys:    nativeSysDirs(),
+		macros: make(map[string]macro),
+		seen:   make(map[string]bool),
+	}
+	define := func(def string) {
+		name, value := def, "1"
+		if i := strings.IndexByte(def, '='); i >= 0 {
+			name, value = def[:i], def[i+1:]
+		}
+		if i := strings.IndexByte(name, '('); i >= 0 {
+			s.macros[name[:i]] = macro{value, true}
+			return
+		}
+		s.macros[name] = macro{value: value}
+	}
+	define("__STDC__")
+	switch langOf(file) {
+	case "c", "objective-c":
+		define("__STDC_VERSION__=201710L")
+	case "assembler-with-cpp":
+		define("__ASSEMBLER__")
+	default:
+		define("__cplusplus=201703L")
+	}
+	if isCuda(file) {
+		define("__CUDACC__")
+	}
+	switch runtime.GOOS {
+	case "windows":
+		define("_WIN32")
+	case "darwin":
+		define("__APPLE__")
+		define("__MACH__")
+	default:
+		define("__" + runtime.GOOS + "__")
+		define("__unix__")
+	}
+	switch runtime.GOARCH {
+	case "amd64":
+		define("__x86_64__")
+	case "arm64":
+		define("__aarch64__")
+	case "386":
+		define("__i386__")
+	}
+	for _, g := range flagGroups(extra) {
+		arg := strings.Join(g, "")
+		switch {
+		case strings.HasPrefix(arg, "-D"):
+			define(arg[2:])
+		case strings.HasPrefix(arg, "-U"):
+			delete(s.macros, arg[2:])
+		}
+	}
+	return s
+}
+
+// nativeHeaders lists the headers of file without the compiler.
+func nativeHeaders(ctx context.Context, file string, acceptsuffix map[string]bool, includes, extra []string, sniff bool) ([]string, error) {
+	s := newNativeScanner(file, includes, extra)
+	s.seen[file] = true
+	if err := s.scan(file, 0); err != nil {
+		return nil, err
+	}
+	deps := make([][]byte, len(s.deps))
+	for i, dep := range s.deps {
+		deps[i] = []byte(dep)
+	}
+	return filterHeaders(ctx, file, deps, acceptsuffix, sniff), nil
+}
+
+// condFrame is the state of an #if group.
+type condFrame struct {
+	// the enclosing group is active
+	outer bool
+	// a branch of the group was taken
+	taken  bool
+	active bool
+}
+
+func (s *nativeScanner) scan(file string, depth int) error {
+	f, err := openRegular(file)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	var stack []condFrame
+	active := true
+	for _, line := range strings.Split(string(stripSource(decodeSource(data))), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(line[1:])
+		i := 0
+		for i < len(line) && isIdentByte(line[i]) {
+			i++
+		}
+		directive, rest := line[:i], strings.TrimSpace(line[i:])
+		switch directive {
+		case "if", "ifdef", "ifndef":
+			frame := condFrame{outer: active}
+			if active {
+				switch directive {
+				case "if":
+					frame.active = s.eval(rest, file) != 0
+				case "ifdef":
+					_, frame.active = s.macros[firstIdent(rest)]
+				case "ifndef":
+					_, ok := s.macros[firstIdent(rest)]
+					frame.active = !ok
+				}
+				frame.taken = frame.active
+			}
+			stack = append(stack, frame)
+			active = frame.active
+		case "elif", "elifdef", "elifndef", "else":
+			if len(stack) == 0 {
+				continue
+			}
+			frame := &stack[len(stack)-1]
+			frame.active = false
+			if frame.outer && !frame.taken {
+				switch directive {
+				case "elif":
+					frame.active = s.eval(rest, file) != 0
+				case "elifdef":
+					_, frame.active = s.macros[firstIdent(rest)]
+				case "elifndef":
+					_, ok := s.macros[firstIdent(rest)]
+					frame.active = !ok
+				default:
+					frame.active = true
+				}
+				frame.taken = frame.active
+			}
+			active = frame.active
+		case "endif":
+			if len(stack) == 0 {
+				continue
+			}
+			active = stack[len(stack)-1].outer
+			stack = stack[:len(stack)-1]
+		case "define":
+			if active {
+				s.define(rest)
+			}
+		case "undef":
+			if active {
+				delete(s.macros, firstIdent(rest))
+			}
+		case "include", "include_next", "import":
+			if active && depth < nativeDepth {
+				s.include(file, rest, directive == "include_next", depth)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *nativeScanner) define(rest string) {
+	i := 0
+	for i < len(rest) && isIdentByte(rest[i]) {
+		i++
+	}
+	if i == 0 {
+		return
+	}
+	name := rest[:i]
+	if i < len(rest) && rest[i] == '(' {
+		s.macros[name] = macro{function: true}
+		return
+	}
+	s.macros[name] = macro{value: strings.TrimSpace(rest[i:])}
+}
+
+// include records the header named by rest and follows it unless it is a
+// system header, which never includes the headers of the tree.
+func (s *nativeScanner) include(from, rest string, next bool, depth int) {
+	if rest != "" && isIdentByte(rest[0]) {
+		// #include HEADER 只能展开成字符串的宏
+		m, ok := s.macros[firstIdent(rest)]
+		if !ok || m.function {
+			return
+		}
+		rest = m.value
+	}
+	if len(rest) < 2 || rest[0] != '"' && rest[0] != '<' {
+		return
+	}
+	end := byte('"')
+	if rest[0] == '<' {
+		end = '>'
+	}
+	i := strings.IndexByte(rest[1:], end)
+	if i < 0 {
+		return
+	}
+	name := rest[1 : i+1]
+	p, sys := s.resolve(from, name, rest[0] == '"', next)
+	if p == "" {
+		s.deps = append(s.deps, name)
+		return
+	}
+	if s.seen[p] {
+		return
+	}
+	s.seen[p] = true
+	s.deps = append(s.deps, p)
+	if !sys {
+		s.scan(p, depth+1)
+	}
+}
+
+// resolve searches name like the preprocessor, returning its path and whether
+// it is in a system dir. #include_next starts after the dir of from.
+func (s *nativeScanner) resolve(from, name string, quoted, next bool) (string, bool) {
+	if filepath.IsAbs(name) {
+		if _, err := os.Stat(name); err == nil {
+			return name, false
+		}
+		return "", false
+	}
+	dir := filepath.Dir(from)
+	if quoted && !next {
+		if p := filepath.Join(dir, name); isFile(p) {
+			return p, false
+		}
+	}
+	skip := next
+	for _, d := range append(append([]string{}, s.dirs...), s.sys...) {
+		if skip {
+			if filepath.Join(d, name) == from {
+				skip = false
+			}
+			continue
+		}
+		if p := filepath.Join(d, name); isFile(p) {
+			// 输出里的系统目录也会作为 -I 传进来
+			return p, inDirs(d, s.sys)
+		}
+	}
+	if next && skip {
+		// 找不到当前文件所在目录时按普通 #include 处理
+		return s.resolve(from, name, quoted, false)
+	}
+	return "", false
+}
+
+func inDirs(dir string, sys []string) bool {
+	for _, d := range sys {
+		if d == dir {
+			return true
+		}
+	}
+	return false
+}
+
+func isFile(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func firstIdent(s string) string {
+	i := 0
+	for i < len(s) && isIdentByte(s[i]) {
+		i++
+	}
+	return s[:i]
+}
+
+// eval evaluates the expression of an #if in from. Unknown identifiers are 0 and
+// calls of function-like macros or builtins other than __has_include are 0.
+func (s *nativeScanner) eval(expr, from string) int64 {
+	toks := s.expand(tokenizeExpr(expr), nil, from)
+	p := &exprParser{toks: toks}
+	return p.ternary()
+}
+
+// expand replaces defined, __has_include and macros in toks by numbers.
+func (s *nativeScanner) expand(toks []string, expanding map[string]bool, from string) []string {
+	var ret []string
+	for i := 0; i < len(toks); i++ {
+		tok := toks[i]
+		if tok == "" || !isIdentByte(tok[0]) || '0' <= tok[0] && tok[0] <= '9' {
+			ret = append(ret, tok)
+			continue
+		}
+		switch tok {
+		case "defined":
+			var name string
+			if i+1 < len(toks) && toks[i+1] == "(" && i+3 < len(toks) {
+				name = toks[i+2]
+				i += 3
+			} else if i+1 < len(toks) {
+				name = toks[i+1]
+				i++
+			}
+			_, ok := s.macros[name]
+			ret = append(ret, boolTok(ok))
+			continue
+		case "__has_include", "__has_include_next":
+			// 记号化时 <a/b.h> 被拆开，重新拼接
+			j := i + 1
+			var arg string
+			depth := 0
+			for ; j < len(toks); j++ {
+				if toks[j] == "(" {
+					depth++
+					if depth == 1 {
+						continue
+					}
+				}
+				if toks[j] == ")" {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+				arg += toks[j]
+			}
+			i = j
+			found := false
+			if len(arg) > 2 && (arg[0] == '<' || arg[0] == '"') {
+				p, _ := s.resolve(from, arg[1:len(arg)-1], arg[0] == '"', false)
+				found = p != ""
+			}
+			ret = append(ret, boolTok(found))
+			continue
+		case "true":
+			ret = append(ret, "1")
+			continue
+		}
+		m, ok := s.macros[tok]
+		if ok && !m.function && !expanding[tok] {
+			inner := make(map[string]bool, len(expanding)+1)
+			for k := range expanding {
+				inner[k] = true
+			}
+			inner[tok] = true
+			ret = append(ret, s.expand(tokenizeExpr(m.value), inner, from)...)
+			continue
+		}
+		// 函数式宏和 __has_feature 这类内建函数都按 0 处理
+		if i+1 < len(toks) && toks[i+1] == "(" {
+			depth := 0
+			for i++; i < len(toks); i++ {
+				if toks[i] == "(" {
+					depth++
+				} else if toks[i] == ")" {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+		}
+		ret = append(ret, "0")
+	}
+	return ret
+}
+
+func boolTok(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// tokenizeExpr splits a preprocessor expression into identifiers, numbers,
+// character literals and operators.
+func tokenizeExpr(expr string) []string {
+	var toks []string
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case isIdentByte(c):
+			j := i
+			for j < len(expr) && (isIdentByte(expr[j]) || expr[j] == '.' && '0' <= c && c <= '9') {
+				j++
+			}
+			toks = append(toks, expr[i:j])
+			i = j
+		case c == '\'' || c == '"':
+			j := i + 1
+			for j < len(expr) && expr[j] != c {
+				if expr[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			// 未结束的字面量可以以反斜杠结尾，j 会越过末尾
+			if j < len(expr) {
+				j++
+			} else {
+				j = len(expr)
+			}
+			toks = append(toks, expr[i:j])
+			i = j
+		default:
+			op := expr[i : i+1]
+			if i+1 < len(expr) {
+				switch two := expr[i : i+2]; two {
+				case "&&", "||", "==", "!=", "<=", ">=", "<<", ">>":
+					op = two
+				}
+			}
+			toks = append(toks, op)
+			i += len(op)
+		}
+	}
+	return toks
+}
+
+// exprParser evaluates an expanded expression by precedence climbing.
+type exprParser struct {
+	toks []string
+	pos  int
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+var binaryPrec = map[string]int{
+	"||": 1, "&&": 2, "|": 3, "^": 4, "&": 5,
+	"==": 6, "!=": 6, "<": 7, ">": 7, "<=": 7, ">=": 7,
+	"<<": 8, ">>": 8, "+": 9, "-": 9, "*": 10, "/": 10, "%": 10,
+}
+
+func (p *exprParser) ternary() int64 {
+	cond := p.binary(1)
+	if p.peek() != "?" {
+		return cond
+	}
+	p.next()
+	a := p.ternary()
+	if p.peek() == ":" {
+		p.next()
+	}
+	b := p.ternary()
+	if cond != 0 {
+		return a
+	}
+	return b
+}
+
+func (p *exprParser) binary(prec int) int64 {
+	lhs := p.unary()
+	for {
+		op := p.peek()
+		opPrec, ok := binaryPrec[op]
+		if !ok || opPrec < prec {
+			return lhs
+		}
+		p.next()
+		rhs := p.binary(opPrec + 1)
+		switch op {
+		case "||":
+			lhs = truth(lhs != 0 || rhs != 0)
+		case "&&":
+			lhs = truth(lhs != 0 && rhs != 0)
+		case "|":
+			lhs |= rhs
+		case "^":
+			lhs ^= rhs
+		case "&":
+			lhs &= rhs
+		case "==":
+			lhs = truth(lhs == rhs)
+		case "!=":
+			lhs = truth(lhs != rhs)
+		case "<":
+			lhs = truth(lhs < rhs)
+		case ">":
+			lhs = truth(lhs > rhs)
+		case "<=":
+			lhs = truth(lhs <= rhs)
+		case ">=":
+			lhs = truth(lhs >= rhs)
+		case "<<":
+			lhs <<= uint64(rhs)
+		case ">>":
+			lhs >>= uint64(rhs)
+		case "+":
+			lhs += rhs
+		case "-":
+			lhs -= rhs
+		case "*":
+			lhs *= rhs
+		case "/", "%":
+			if rhs == 0 {
+				lhs = 0
+			} else if op == "/" {
+				lhs /= rhs
+			} else {
+				lhs %= rhs
+			}
+		}
+	}
+}
+
+func (p *exprParser) unary() int64 {
+	switch tok := p.next(); tok {
+	case "!":
+		return truth(p.unary() == 0)
+	case "-":
+		return -p.unary()
+	case "+":
+		return p.unary()
+	case "~":
+		return ^p.unary()
+	case "(":
+		v := p.ternary()
+		if p.peek() == ")" {
+			p.next()
+		}
+		return v
+	default:
+		return parseNumber(tok)
+	}
+}
+
+func truth(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// parseNumber parses an integer or character literal, 0 if it is neither.
+func parseNumber(tok string) int64 {
+	if len(tok) >= 3 && tok[0] == '\'' {
+		if v, _, _, err := strconv.UnquoteChar(tok[1:len(tok)-1], '\''); err == nil {
+			return int64(v)
+		}
+		return 0
+	}
+	tok = strings.TrimRight(tok, "uUlL")
+	v, err := strconv.ParseInt(tok, 0, 64)
+	if err != nil {
+		u, _ := strconv.ParseUint(tok, 0, 64)
+		return int64(u)
+	}
+	return v
+}
@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testScanner is a native scanner with the search dirs given and no system
+// dirs, so the results do not depend on the machine.
+func testScanner(dirs []string, macros map[string]string) *nativeScanner {
+	s := &nativeScanner{
+		dirs:   dirs,
+		macros: make(map[string]macro),
+		seen:   make(map[string]bool),
+	}
+	for name, value := range macros {
+		s.macros[name] = macro{value: value}
+	}
+	return s
+}
+
+// writeTree writes files, slash separated paths to contents, under dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTokenizeExpr(t *testing.T) {
+	tests := []struct {
+		expr string
+		toks []string
+	}{
+		{"A && B", []string{"A", "&&", "B"}},
+		{"defined(X)||Y>=2", []string{"defined", "(", "X", ")", "||", "Y", ">=", "2"}},
+		{"1.5 < 2", []string{"1.5", "<", "2"}},
+		{`X == 'a'`, []string{"X", "==", "'a'"}},
+		{`X == '\''`, []string{"X", "==", `'\''`}},
+		// 未结束的字面量一直到行尾
+		{`X == 'a`, []string{"X", "==", "'a"}},
+		{`X == '\`, []string{"X", "==", `'\`}},
+		{`"\`, []string{`"\`}},
+	}
+	for _, tt := range tests {
+		if toks := tokenizeExpr(tt.expr); !reflect.DeepEqual(toks, tt.toks) {
+			t.Errorf("tokenizeExpr(%q) = %q, want %q", tt.expr, toks, tt.toks)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	s := testScanner(nil, map[string]string{
+		"ONE":     "1",
+		"TWO":     "2",
+		"EMPTY":   "",
+		"SUM":     "ONE + TWO",
+		"SELF":    "SELF + 1",
+		"VERSION": "201703L",
+	})
+	s.macros["FUNC"] = macro{function: true}
+	tests := []struct {
+		expr string
+		want int64
+	}{
+		{"1", 1},
+		{"0", 0},
+		{"ONE", 1},
+		{"UNKNOWN", 0},
+		{"!UNKNOWN", 1},
+		{"defined(ONE)", 1},
+		{"defined ONE && !defined(UNKNOWN)", 1},
+		{"defined(EMPTY)", 1},
+		{"SUM == 3", 1},
+		// 宏按记号替换，不加括号
+		{"SUM * 2", 5},
+		{"SELF", 1},
+		{"VERSION >= 201103L", 1},
+		{"VERSION < 201103L", 0},
+		{"ONE ? TWO : 5", 2},
+		{"UNKNOWN ? TWO : 5", 5},
+		{"(ONE + TWO) * TWO", 6},
+		{"ONE + TWO * TWO", 5},
+		{"1 << 4 | 1", 17},
+		{"0x10 == 16", 1},
+		{"-ONE", -1},
+		{"FUNC(1)", 0},
+		{"__has_feature(cxx_rtti) || ONE", 1},
+		{"true", 1},
+		{"TWO > ONE && ONE > 0 || 0", 1},
+		// 写坏的表达式不能中断扫描
+		{`ONE == '\`, 0},
+		{"ONE +", 1},
+		{"(", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := s.eval(tt.expr, "a.c"); got != tt.want {
+			t.Errorf("eval(%q) = %d, want %d", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestScanConditionals(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.h": "", "b.h": "", "c.h": "", "d.h": "", "e.h": "", "f.h": "", "g.h": "", "h.h": "",
+		"main.c": `
+#define LEVEL 2
+#if LEVEL == 1
+#include "a.h"
+#elif LEVEL == 2
+#include "b.h"
+#  if defined(INNER)
+#include "c.h"
+#  else
+#include "d.h"
+#  endif
+#elif LEVEL == 2
+#include "e.h"
+#else
+#include "f.h"
+#endif
+#ifdef LEVEL
+#undef LEVEL
+#endif
+#ifndef LEVEL
+#include "g.h"
+#endif
+#if 0
+#  if 1
+#include "h.h"
+#  else
+#include "h.h"
+#  endif
+#endif
+`,
+	})
+	s := testScanner(nil, nil)
+	if err := s.scan(filepath.Join(dir, "main.c"), 0); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "b.h"), filepath.Join(dir, "d.h"), filepath.Join(dir, "g.h")}
+	if !reflect.DeepEqual(s.deps, want) {
+		t.Errorf("deps = %q, want %q", s.deps, want)
+	}
+}
+
+func TestScanHasInclude(t *testing.T) {
+	dir := t.TempDir()
+	inc := filepath.Join(dir, "inc")
+	writeTree(t, dir, map[string]string{
+		"inc/sys/present.h": "",
+		"local.h":           "",
+		"yes.h":             "",
+		"quoted.h":          "",
+		"no.h":              "",
+		"main.c": `
+#if __has_include(<sys/present.h>)
+#include "yes.h"
+#endif
+#if __has_include(<sys/missing.h>)
+#include "no.h"
+#endif
+#if defined(__has_include) || __has_include("local.h")
+#include "quoted.h"
+#endif
+`,
+	})
+	s := testScanner([]string{inc}, nil)
+	if err := s.scan(filepath.Join(dir, "main.c"), 0); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "yes.h"), filepath.Join(dir, "quoted.h")}
+	if !reflect.DeepEqual(s.deps, want) {
+		t.Errorf("deps = %q, want %q", s.deps, want)
+	}
+}
+
+func TestScanIncludeNext(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first")
+	second := filepath.Join(dir, "second")
+	writeTree(t, dir, map[string]string{
+		"first/wrap.h":   "#include_next <wrap.h>\n",
+		"second/wrap.h":  "#include <inner.h>\n",
+		"second/inner.h": "",
+		"main.c":         "#include <wrap.h>\n#include <missing.h>\n",
+	})
+	s := testScanner([]string{first, second}, nil)
+	s.seen[filepath.Join(dir, "main.c")] = true
+	if err := s.scan(filepath.Join(dir, "main.c"), 0); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(first, "wrap.h"),
+		filepath.Join(second, "wrap.h"),
+		filepath.Join(second, "inner.h"),
+		"missing.h",
+	}
+	if !reflect.DeepEqual(s.deps, want) {
+		t.Errorf("deps = %q, want %q", s.deps, want)
+	}
+}
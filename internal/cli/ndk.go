@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+var (
+	ndkRoot      = cmdline.String("ndk", "", "android NDK dir, the outputs target -ndk-abi at -ndk-api with its llvm toolchain and sysroot")
+	ndkABI       = cmdline.String("ndk-abi", "arm64-v8a", "android ABI: arm64-v8a, armeabi-v7a, x86 or x86_64")
+	ndkAPI       = cmdline.Int("ndk-api", 21, "minimum android API level")
+	androidBuild = cmdline.Bool("android-build", false, "merge include dirs and cflags of the Android.mk and Android.bp files in src_dir")
+)
+
+// ndkTriples are the target triples of the ABIs.
+var ndkTriples = map[string]string{
+	"arm64-v8a":   "aarch64-linux-android",
+	"armeabi-v7a": "armv7a-linux-androideabi",
+	"x86":         "i686-linux-android",
+	"x86_64":      "x86_64-linux-android",
+}
+
+// ndkToolchain returns the prebuilt llvm toolchain of the NDK for this host.
+func ndkToolchain(ndk string) (string, error) {
+	dir := filepath.Join(ndk, "toolchains", "llvm", "prebuilt", runtime.GOOS+"-x86_64")
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+	// 其他主机的工具链也能提供头文件
+	l, _ := filepath.Glob(filepath.Join(ndk, "toolchains", "llvm", "prebuilt", "*"))
+	if len(l) == 0 {
+		return "", fmt.Errorf("no llvm toolchain in NDK %s", ndk)
+	}
+	return l[0], nil
+}
+
+// ndkCompiler is the NDK clang the scans run unless CC is set. It is not put
+// in the environment, where capture and the builds it wraps would see it.
+var ndkCompiler string
+
+// checkNDK adds the target, sysroot and STL flags of -ndk to ccflags, and
+// scans with the NDK clang unless CC is set.
+func checkNDK() error {
+	if *ndkRoot == "" {
+		return nil
+	}
+	triple, ok := ndkTriples[*ndkABI]
+	if !ok {
+		return fmt.Errorf("unknown NDK ABI %q", *ndkABI)
+	}
+	toolchain, err := ndkToolchain(*ndkRoot)
+	if err != nil {
+		return err
+	}
+	toolchain, err = filepath.Abs(toolchain)
+	if err != nil {
+		return err
+	}
+	sysroot := filepath.Join(toolchain, "sysroot")
+	api := strconv.Itoa(*ndkAPI)
+	flags := []string{
+		"--target=" + triple + api,
+		"--sysroot=" + sysroot,
+		"-isystem", filepath.Join(sysroot, "usr", "include", "c++", "v1"),
+		"-D__ANDROID__",
+		"-D__ANDROID_API__=" + api,
+	}
+	ccflags = dedupFlags(append(ccflags, flags...))
+	ndkCompiler = filepath.Join(toolchain, "bin", "clang++")
+	return nil
+}
+
+var (
+	mkVarRe   = regexp.MustCompile(`^(LOCAL_C_INCLUDES|LOCAL_EXPORT_C_INCLUDES|LOCAL_CFLAGS|LOCAL_CPPFLAGS|LOCAL_EXPORT_CFLAGS)\s*[:+]?=(.*)$`)
+	bpListRe  = regexp.MustCompile(`(?s)\b(local_include_dirs|export_include_dirs|include_dirs|export_system_include_dirs|cflags|cppflags)\s*:\s*\[([^\]]*)\]`)
+	bpQuoteRe = regexp.MustCompile(`"([^"]*)"`)
+)
+
+// androidBuildFlags returns the include dirs and preprocessor flags of the
+// Android.mk and Android.bp files under root.
+func androidBuildFlags(root string) ([]string, []string, error) {
+	var args []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path != root && (info.Name()[0] == '.' || isExcluded(info.Name())) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch info.Name() {
+		case "Android.mk":
+			l, err := androidMkArgs(path, root)
+			if err != nil {
+				return err
+			}
+			args = append(args, l...)
+		case "Android.bp":
+			l, err := androidBpArgs(path, root)
+			if err != nil {
+				return err
+			}
+			args = append(args, l...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	dirs, flags := splitIncludes(args, root)
+	return dedup(dirs), dedupFlags(flags), nil
+}
+
+// androidMkArgs reads the flags of an Android.mk. Relative LOCAL_C_INCLUDES
+// are relative to the top of the tree, $(LOCAL_PATH) to the dir of the file.
+func androidMkArgs(file, root string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(file)
+	var args []string
+	scanner := bufio.NewScanner(f)
+	var line string
+	for scanner.Scan() {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		if strings.HasSuffix(text, "\\") {
+			line += text[:len(text)-1] + " "
+			continue
+		}
+		stmt := strings.TrimSpace(line + text)
+		line = ""
+		m := mkVarRe.FindStringSubmatch(stmt)
+		if m == nil {
+			continue
+		}
+		include := strings.HasSuffix(m[1], "INCLUDES")
+		for _, v := range strings.Fields(m[2]) {
+			v = strings.Replace(v, "$(LOCAL_PATH)", dir, -1)
+			// 其他变量无法展开
+			if strings.Contains(v, "$(") {
+				continue
+			}
+			if !include {
+				args = append(args, v)
+				continue
+			}
+			if !filepath.IsAbs(v) {
+				v = filepath.Join(root, v)
+			}
+			args = append(args, "-I"+v)
+		}
+	}
+	return args, scanner.Err()
+}
+
+// androidBpArgs reads the lists of an Android.bp. include_dirs are relative
+// to the top of the tree, the other dirs to the dir of the file.
+func androidBpArgs(file, root string) ([]string, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(file)
+	var args []string
+	for _, m := range bpListRe.FindAllStringSubmatch(string(buf), -1) {
+		for _, q := range bpQuoteRe.FindAllStringSubmatch(m[2], -1) {
+			v := q[1]
+			switch m[1] {
+			case "cflags", "cppflags":
+				args = append(args, v)
+			case "include_dirs":
+				args = append(args, "-I"+filepath.Join(root, v))
+			default:
+				args = append(args, "-I"+filepath.Join(dir, v))
+			}
+		}
+	}
+	return args, nil
+}
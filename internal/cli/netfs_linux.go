@@ -0,0 +1,21 @@
+package cli
+
+import "syscall"
+
+// The statfs magic numbers of the filesystems that deliver no change events
+// for edits made on other hosts.
+var networkMagics = map[uint32]bool{
+	0x6969:     true, // NFS
+	0x517b:     true, // SMB
+	0xff534d42: true, // CIFS
+	0xfe534d42: true, // SMB2
+}
+
+// networkFS reports whether p is on a network filesystem.
+func networkFS(p string) bool {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(p, &st); err != nil {
+		return false
+	}
+	return networkMagics[uint32(st.Type)]
+}
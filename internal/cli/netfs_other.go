@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package cli
+
+// networkFS reports whether p is on a network filesystem, which is only
+// known on linux; elsewhere use serve -revalidate-roots all.
+func networkFS(p string) bool {
+	return false
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var neverInclude stringSlice
+
+func init() {
+	cmdline.Var(&neverInclude, "never-include", "glob of dirs that are indexed but never emitted as include dirs, like **/internal/private_headers; includes they would resolve are reported")
+}
+
+// neverDirs are the compiled -never-include globs.
+var neverDirs []*regexp.Regexp
+
+// checkNeverInclude compiles -never-include. Globs not starting with / or **
+// are relative to the current dir.
+func checkNeverInclude() error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	for _, glob := range neverInclude {
+		if !filepath.IsAbs(glob) && !strings.HasPrefix(glob, "**") {
+			glob = filepath.Join(cwd, glob)
+		}
+		re, err := globRegexp(strings.TrimSuffix(filepath.ToSlash(glob), "/"))
+		if err != nil {
+			return fmt.Errorf("never include: %s", err)
+		}
+		neverDirs = append(neverDirs, re)
+	}
+	return nil
+}
+
+// neverIncluded reports whether dir matches -never-include.
+func neverIncluded(dir string) bool {
+	dir = filepath.ToSlash(dir)
+	for _, re := range neverDirs {
+		if re.MatchString(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowDirs drops the -never-include dirs from the dirs resolving a header.
+// It reports false when none is left, the include then breaks the layering.
+func allowDirs(dirs []string) ([]string, bool) {
+	if len(neverDirs) == 0 {
+		return dirs, true
+	}
+	var ret []string
+	for _, dir := range dirs {
+		if !neverIncluded(dir) {
+			ret = append(ret, dir)
+		}
+	}
+	return ret, len(ret) != 0 || len(dirs) == 0
+}
+
+// Never records site including header that only the -never-include dirs
+// resolve.
+func (r *report) Never(header string, site includeSite, dirs []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, s := range r.never[header] {
+		if s == site {
+			return
+		}
+	}
+	r.never[header] = append(r.never[header], site)
+	r.neverDirs[header] = dedup(append(r.neverDirs[header], dirs...))
+}
+
+func (r *report) printNever(w io.Writer) {
+	var headers []string
+	for h := range r.never {
+		headers = append(headers, h)
+	}
+	sort.Strings(headers)
+	fmt.Fprintf(w, "includes resolved only by -never-include dirs:\n")
+	for _, h := range headers {
+		var dirs []string
+		for _, dir := range r.neverDirs[h] {
+			dirs = append(dirs, mapPath(dir))
+		}
+		fmt.Fprintf(w, "  %s, in %s:\n", h, strings.Join(dirs, " "))
+		sites := r.never[h]
+		sort.Slice(sites, func(i, j int) bool { return sites[i].String() < sites[j].String() })
+		for _, site := range sites {
+			fmt.Fprintf(w, "    %s\n", site)
+		}
+	}
+}
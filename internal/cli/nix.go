@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var unwrapNix = cmdline.Bool("unwrap-nix", true, "emit the include dirs and defines a nix cc-wrapper adds, so tools running the bare compiler see them too")
+
+// nixSupportFiles hold the flags a nix cc-wrapper adds, under nix-support in
+// the wrapper.
+var nixSupportFiles = []string{"libc-cflags", "cc-cflags", "libcxx-cxxflags"}
+
+// nixWrapper returns the dir of the nix cc-wrapper of the compiler, or "".
+func nixWrapper() string {
+	p, err := exec.LookPath(compiler())
+	if err != nil {
+		return ""
+	}
+	p, err = filepath.EvalSymlinks(p)
+	if err != nil || !strings.HasPrefix(p, "/nix/store/") {
+		return ""
+	}
+	dir := filepath.Dir(filepath.Dir(p))
+	if _, err := os.Stat(filepath.Join(dir, "nix-support")); err != nil {
+		return ""
+	}
+	return dir
+}
+
+// checkNix adds to ccflags the header flags the nix cc-wrapper and
+// NIX_CFLAGS_COMPILE add, which clangd and editors calling the compiler
+// outside nix-shell would miss.
+func checkNix() error {
+	if !*unwrapNix {
+		return nil
+	}
+	args := strings.Fields(os.Getenv("NIX_CFLAGS_COMPILE"))
+	if wrapper := nixWrapper(); wrapper != "" {
+		for _, name := range nixSupportFiles {
+			buf, err := ioutil.ReadFile(filepath.Join(wrapper, "nix-support", name))
+			if err != nil {
+				continue
+			}
+			args = append(args, strings.Fields(string(buf))...)
+		}
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	// 只保留影响头文件查找和宏的参数，-frandom-seed 之类的丢掉
+	dirs, flags := splitIncludes(args, "")
+	for _, dir := range dirs {
+		flags = append(flags, "-I"+dir)
+	}
+	log.Debug("nix flags: %v", flags)
+	ccflags = dedupFlags(append(ccflags, flags...))
+	return nil
+}
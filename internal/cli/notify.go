@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+var notifyTarget = cmdline.String("notify", "", "report flag changes against the previous output to stderr, desktop or an http(s) webhook url")
+
+// notifyChanges sends the difference between the previous and the new flags,
+// as outputLines gives them, if any, to the -notify target.
+func notifyChanges(output string, old, cur []string) error {
+	if *notifyTarget == "" {
+		return nil
+	}
+	lines := diffLines(old, cur)
+	if len(lines) == 0 {
+		return nil
+	}
+	title := fmt.Sprintf("%s changed, reload flags in your editor", output)
+
+	switch target := *notifyTarget; {
+	case target == "stderr":
+		fmt.Fprintln(os.Stderr, title)
+		for _, line := range lines {
+			fmt.Fprintln(os.Stderr, "  "+line)
+		}
+		return nil
+	case target == "desktop":
+		return desktopNotify(title, strings.Join(lines, "\n"))
+	case strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://"):
+		return webhookNotify(target, output, title, lines)
+	default:
+		return fmt.Errorf("unknown notify target %q", target)
+	}
+}
+
+func desktopNotify(title, body string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", body, title)
+		cmd = exec.Command("osascript", "-e", script)
+	default:
+		cmd = exec.Command("notify-send", title, body)
+	}
+	return cmd.Run()
+}
+
+func webhookNotify(url, output, title string, lines []string) error {
+	var added, removed []string
+	for _, line := range lines {
+		if line[0] == '+' {
+			added = append(added, line[1:])
+		} else {
+			removed = append(removed, line[1:])
+		}
+	}
+	buf, err := json.Marshal(map[string]interface{}{
+		"text":    title,
+		"output":  output,
+		"added":   added,
+		"removed": removed,
+	})
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %s: %s", url, resp.Status)
+	}
+	return nil
+}
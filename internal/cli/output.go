@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// outputTx stages the files of one output next to it, with its side files
+// and per-language files, and moves them into place only when all of them
+// were written, so a failed run leaves the previous files intact.
+type outputTx struct {
+	stage string
+	files map[string]string
+	stale []string
+}
+
+func newOutputTx(name string) (*outputTx, error) {
+	stage, err := ioutil.TempDir(filepath.Dir(name), ".clang_complete.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &outputTx{stage: stage, files: make(map[string]string)}, nil
+}
+
+// Create returns a writer of the staged content of name.
+func (tx *outputTx) Create(name string) (io.WriteCloser, error) {
+	staged := filepath.Join(tx.stage, filepath.Base(name))
+	f, err := os.Create(staged)
+	if err != nil {
+		return nil, err
+	}
+	tx.files[name] = staged
+	return f, nil
+}
+
+// Remove deletes name on commit, unless it is written again.
+func (tx *outputTx) Remove(name string) {
+	tx.stale = append(tx.stale, name)
+}
+
+// Commit moves the staged files into place and removes the stale ones. The
+// files replaced or removed are kept in the stage until all moves are done,
+// and put back when one fails.
+func (tx *outputTx) Commit() error {
+	defer os.RemoveAll(tx.stage)
+	old, err := ioutil.TempDir(tx.stage, "old")
+	if err != nil {
+		return err
+	}
+	var names []string
+	for name := range tx.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range tx.stale {
+		if _, ok := tx.files[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	// 已完成的替换，回滚时按相反顺序恢复
+	var done [][2]string
+	for i, name := range names {
+		backup := filepath.Join(old, strconv.Itoa(i))
+		err = os.Rename(name, backup)
+		if os.IsNotExist(err) {
+			backup, err = "", nil
+		}
+		if err == nil {
+			if staged, ok := tx.files[name]; ok {
+				err = os.Rename(staged, name)
+				if err != nil && backup != "" {
+					os.Rename(backup, name)
+				}
+			}
+		}
+		if err != nil {
+			for j := len(done) - 1; j >= 0; j-- {
+				if done[j][1] == "" {
+					os.Remove(done[j][0])
+				} else {
+					os.Rename(done[j][1], done[j][0])
+				}
+			}
+			return err
+		}
+		done = append(done, [2]string{name, backup})
+	}
+	return nil
+}
+
+func (tx *outputTx) Abort() {
+	os.RemoveAll(tx.stage)
+}
+
+func (tx *outputTx) write(name string, write func(w io.Writer) error) error {
+	w, err := tx.Create(name)
+	if err != nil {
+		return err
+	}
+	err = write(w)
+	if err1 := w.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputTxRollback(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a": "old a", "c": "old c"})
+	tx, err := newOutputTx(filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "nodir/x"} {
+		name := name
+		err := tx.write(filepath.Join(dir, name), func(w io.Writer) error {
+			_, err := io.WriteString(w, "new "+name)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tx.Remove(filepath.Join(dir, "c"))
+	// nodir 不存在，最后一个文件移动失败
+	if err := tx.Commit(); err == nil {
+		t.Fatal("Commit into a missing dir succeeded")
+	}
+	for name, want := range map[string]string{"a": "old a", "c": "old c"} {
+		buf, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(buf) != want {
+			t.Errorf("%s = %q, %v after rollback, want %q", name, buf, err, want)
+		}
+	}
+	infos, _ := ioutil.ReadDir(dir)
+	if len(infos) != 2 {
+		t.Errorf("%d files left after rollback, want 2", len(infos))
+	}
+}
+
+func TestWriteLangFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"util.c":                  "int util;\n",
+		"util.lang-c":             generatedMarker + "\n-I/old\n",
+		"util.lang-cuda":          generatedMarker + "\n-I/old\n",
+		"util.lang-objective-c":   "-I/mine\n",
+		"util.lang-c++":           generatedMarker + "\n-I/old\n",
+		"util.lang-assembler.txt": "notes\n",
+	})
+	output := filepath.Join(dir, "util")
+	fs := &flagSet{
+		Files:    []string{filepath.Join(dir, "util.c"), filepath.Join(dir, "main.cc")},
+		Includes: []string{"/inc"},
+		Lang:     map[string][]string{"c++": {"-std=c++20"}},
+	}
+	if err := writeFlagSet(output, formats["clang_complete"], fs); err != nil {
+		t.Fatal(err)
+	}
+	for name, exists := range map[string]bool{
+		"util":                    true,
+		"util.c":                  true,
+		"util.lang-c":             true,
+		"util.lang-cuda":          false,
+		"util.lang-objective-c":   true,
+		"util.lang-c++":           true,
+		"util.lang-assembler.txt": true,
+	} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if (err == nil) != exists {
+			t.Errorf("%s exists: %v, want %v", name, err == nil, exists)
+		}
+	}
+	buf, _ := ioutil.ReadFile(filepath.Join(dir, "util.lang-c++"))
+	if want := generatedMarker + "\n-I/inc\n-std=c++20\n"; string(buf) != want {
+		t.Errorf("util.lang-c++ = %q, want %q", buf, want)
+	}
+}
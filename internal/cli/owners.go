@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var ownersFile = cmdline.String("owners", "", "write each include dir with the top-level dir providing it and the sources depending on it to file as json")
+
+// ownership is an include dir and the sources that use its headers.
+type ownership struct {
+	Dir       string   `json:"dir"`
+	Component string   `json:"component"`
+	Headers   []string `json:"headers"`
+	Sources   []string `json:"sources"`
+}
+
+// writeOwners writes the include dirs of the scanned sources with the top-level
+// dir under srcroot or the search roots that provides them, for tools that
+// tell owners about new dependencies on their headers.
+func writeOwners(name, srcroot string) error {
+	tops := []string{srcroot}
+	for _, root := range searchroots.Paths() {
+		if abs, err := filepath.Abs(msys.Windows(root)); err == nil {
+			tops = append(tops, abs)
+		}
+	}
+
+	stats.lock.Lock()
+	items := []ownership{}
+	for dir, srcs := range stats.dirSrcs {
+		item := ownership{Dir: mapPath(dir)}
+		// 源码目录内的组件使用相对路径，和 CODEOWNERS 一致
+		c := component(dir, tops)
+		if rel, err := filepath.Rel(srcroot, c); err == nil && !strings.HasPrefix(rel, "..") {
+			item.Component = rel
+		} else {
+			item.Component = mapPath(c)
+		}
+		for h := range stats.dirHdrs[dir] {
+			item.Headers = append(item.Headers, h)
+		}
+		for src := range srcs {
+			if rel, err := filepath.Rel(srcroot, src); err == nil && !strings.HasPrefix(rel, "..") {
+				src = rel
+			}
+			item.Sources = append(item.Sources, src)
+		}
+		sort.Strings(item.Headers)
+		sort.Strings(item.Sources)
+		items = append(items, item)
+	}
+	stats.lock.Unlock()
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Dir < items[j].Dir
+	})
+
+	buf, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	buf = append(buf, '\n')
+	if name == "-" {
+		_, err = os.Stdout.Write(buf)
+		return err
+	}
+	return ioutil.WriteFile(name, buf, 0644)
+}
+
+// component returns the top-level dir of dir under the innermost of tops, or
+// dir itself when it is outside all of them.
+func component(dir string, tops []string) string {
+	best := ""
+	for _, top := range tops {
+		if underAny(dir, []string{top}) && len(top) > len(best) {
+			best = top
+		}
+	}
+	if best == "" || best == dir {
+		return dir
+	}
+	rel, _ := filepath.Rel(best, dir)
+	return filepath.Join(best, strings.Split(rel, string(filepath.Separator))[0])
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+type pathMap struct {
+	from, to string
+}
+
+type pathMapSlice []pathMap
+
+var pathMaps pathMapSlice
+
+func (s *pathMapSlice) String() string {
+	var l []string
+	for _, m := range *s {
+		l = append(l, m.from+"="+m.to)
+	}
+	return fmt.Sprintf("%q", l)
+}
+
+func (s *pathMapSlice) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i <= 0 {
+		return fmt.Errorf("bad path map %q, want from=to", value)
+	}
+	*s = append(*s, pathMap{filepath.Clean(value[:i]), filepath.Clean(value[i+1:])})
+	return nil
+}
+
+// mapPath rewrites the longest matching prefix of p and translates MSYS
+// style paths for windows editors.
+func mapPath(p string) string {
+	var best *pathMap
+	for i, m := range pathMaps {
+		if p != m.from && !strings.HasPrefix(p, m.from+string(filepath.Separator)) {
+			continue
+		}
+		if best == nil || len(m.from) > len(best.from) {
+			best = &pathMaps[i]
+		}
+	}
+	if best != nil {
+		p = best.to + p[len(best.from):]
+	}
+	return msys.Windows(p)
+}
+
+var pathFlags = []string{"-isystem", "-imsvc", "-idirafter", "-iquote", "-include", "-imacros", "-isysroot", "--sysroot=", "--embed-dir=", "-I", "-F"}
+
+// mapFlags applies mapPath to the path arguments of flags.
+func mapFlags(flags []string) []string {
+	return mapFlagsWith(flags, mapPath)
+}
+
+func mapFlagsWith(flags []string, mapPath func(string) string) []string {
+	var ret []string
+	for _, g := range flagGroups(flags) {
+		if len(g) == 2 && isPathFlag(g[0]) {
+			ret = append(ret, g[0], mapPath(g[1]))
+			continue
+		}
+		arg := g[0]
+		for _, f := range pathFlags {
+			if strings.HasPrefix(arg, f) && len(arg) > len(f) {
+				arg = f + mapPath(arg[len(f):])
+				break
+			}
+		}
+		ret = append(ret, arg)
+	}
+	return ret
+}
+
+func isPathFlag(s string) bool {
+	for _, f := range pathFlags {
+		if s == f {
+			return true
+		}
+	}
+	return false
+}
+
+func (fs *flagSet) mapPaths() {
+	if len(pathMaps) == 0 && !msys.Enabled() {
+		return
+	}
+	mapAll := func(l []string) []string {
+		ret := make([]string, len(l))
+		for i, p := range l {
+			ret[i] = mapPath(p)
+		}
+		return ret
+	}
+	fs.Includes = mapAll(fs.Includes)
+	fs.Systems = mapAll(fs.Systems)
+	fs.After = mapAll(fs.After)
+	fs.Test = mapAll(fs.Test)
+	fs.Files = mapAll(fs.Files)
+	fs.Flags = mapFlags(fs.Flags)
+	for lang, flags := range fs.Lang {
+		fs.Lang[lang] = mapFlags(flags)
+	}
+	if fs.FileIncludes != nil {
+		m := make(map[string][]string)
+		for file, dirs := range fs.FileIncludes {
+			m[mapPath(file)] = mapAll(dirs)
+		}
+		fs.FileIncludes = m
+	}
+	if fs.FileFlags != nil {
+		m := make(map[string][]string)
+		for file, flags := range fs.FileFlags {
+			m[mapPath(file)] = mapFlags(flags)
+		}
+		fs.FileFlags = m
+	}
+	if fs.Uses != nil {
+		m := make(map[string][]string)
+		for dir, srcs := range fs.Uses {
+			m[mapPath(dir)] = mapAll(srcs)
+		}
+		fs.Uses = m
+	}
+}
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	indexTimeout = cmdline.Duration("index-timeout", 0, "stop waiting for the search roots still being indexed after this long, 0 means no limit")
+	roundTimeout = cmdline.Duration("round-timeout", 0, "stop waiting for the scans of a round still running after this long, like a hung compiler, 0 means no limit")
+	onTimeout    = cmdline.String("on-timeout", "continue", "after a phase timed out and the partial output was written: continue with what was found, or exit")
+)
+
+var errIndexTimeout = errors.New("index timeout")
+
+func checkTimeouts() error {
+	switch *onTimeout {
+	case "continue", "exit":
+		return nil
+	}
+	return fmt.Errorf("unknown -on-timeout %q, want continue or exit", *onTimeout)
+}
+
+// WaitUntil is Wait giving up at deadline, a zero deadline meaning none.
+// The roots still being indexed are left to finish in the background, and
+// the sources missing headers are no longer deferred.
+func (t *tree) WaitUntil(deadline time.Time) error {
+	if t.done == nil || deadline.IsZero() || !t.Indexing() {
+		return t.Wait()
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case err := <-t.done:
+		t.done = nil
+		return err
+	case <-timer.C:
+	}
+	t.lock.Lock()
+	t.indexing = false
+	t.lock.Unlock()
+	t.done = nil
+	return errIndexTimeout
+}
+
+// pending returns the roots not indexed yet.
+func (t *tree) pending(roots rootSlice) []string {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	var ret []string
+	for _, root := range roots.Paths() {
+		abs, err := filepath.Abs(msys.Windows(root))
+		if err != nil {
+			continue
+		}
+		if _, ok := t.roots[abs]; !ok && !t.flatRoot(abs) {
+			ret = append(ret, root)
+		}
+	}
+	return ret
+}
+
+// WaitTimeout is Wait giving up after d, 0 meaning no limit. It reports
+// whether all the functions returned.
+func (p *pool) WaitTimeout(d time.Duration) bool {
+	if d <= 0 {
+		p.Wait()
+		return true
+	}
+	done := make(chan struct{})
+	go func() {
+		p.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// TimedOut records a phase that took too long and what it left undone.
+func (r *report) TimedOut(phase string, left []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for i := range left {
+		left[i] = mapPath(left[i])
+	}
+	r.timedOut = append(r.timedOut, fmt.Sprintf("%s: %s", phase, strings.Join(left, " ")))
+}
+
+// flushPartial writes what was found when a phase timed out, so a run that
+// hangs later still leaves an output.
+func flushPartial(printer *printer, output string) {
+	if err := printer.Flush(output); err != nil {
+		fmt.Fprintln(os.Stderr, "partial output:", err)
+	}
+}
@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	pinsFile   = cmdline.String("pins", "", "file of header dir lines pinning headers found in several dirs to one of them")
+	exportPins = cmdline.String("export-pins", "", "write the headers found in several dirs to this file, pinned to the first dir, for -pins")
+)
+
+// headerPins holds the pinned dir of headers and the headers found in several
+// dirs during the run.
+type headerPins struct {
+	lock      sync.Mutex
+	pinned    map[string]string
+	ambiguous map[string][]string
+}
+
+var pins = &headerPins{
+	pinned:    make(map[string]string),
+	ambiguous: make(map[string][]string),
+}
+
+// checkPins loads -pins. Relative dirs are relative to the file so it can be
+// shared across checkouts.
+func checkPins() error {
+	if *pinsFile == "" {
+		return nil
+	}
+	f, err := os.Open(*pinsFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	base, err := filepath.Abs(filepath.Dir(*pinsFile))
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return fmt.Errorf("%s:%d: want header dir", *pinsFile, n)
+		}
+		dir := fields[1]
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(base, dir)
+		}
+		pins.pinned[fields[0]] = filepath.Clean(dir)
+	}
+	return scanner.Err()
+}
+
+// Resolve returns the dir header is pinned to, or dirs recording them when
+// there are several.
+func (p *headerPins) Resolve(header string, dirs []string) []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if dir, ok := p.pinned[header]; ok {
+		return []string{dir}
+	}
+	if len(dirs) > 1 {
+		p.ambiguous[header] = dedup(append(p.ambiguous[header], dirs...))
+	}
+	return dirs
+}
+
+// Export writes the pins and the headers found in several dirs, pinned to
+// the first of them in the search order with the others in a comment, to
+// name.
+func (p *headerPins) Export(name string, order []string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	base, err := filepath.Abs(filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+	rel := func(dir string) string {
+		if r, err := filepath.Rel(base, dir); err == nil && !strings.HasPrefix(r, "..") {
+			return r
+		}
+		return dir
+	}
+	var headers []string
+	for h := range p.pinned {
+		headers = append(headers, h)
+	}
+	for h := range p.ambiguous {
+		if _, ok := p.pinned[h]; !ok {
+			headers = append(headers, h)
+		}
+	}
+	sort.Strings(headers)
+
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, "# header dir, for clang_complete -pins")
+	for _, h := range headers {
+		if dir, ok := p.pinned[h]; ok {
+			fmt.Fprintf(w, "%s %s\n", h, rel(dir))
+			continue
+		}
+		dirs := append([]string{}, p.ambiguous[h]...)
+		sortSearchOrder(dirs, order)
+		var others []string
+		for _, dir := range dirs[1:] {
+			others = append(others, rel(dir))
+		}
+		fmt.Fprintf(w, "%s %s # also in %s\n", h, rel(dirs[0]), strings.Join(others, " "))
+	}
+	err = w.Flush()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// sortSearchOrder sorts dirs as they come in order, the dirs missing from it
+// last.
+func sortSearchOrder(dirs, order []string) {
+	pos := make(map[string]int)
+	for i, dir := range order {
+		if _, ok := pos[dir]; !ok {
+			pos[dir] = i
+		}
+	}
+	rank := func(dir string) int {
+		if i, ok := pos[dir]; ok {
+			return i
+		}
+		return len(order)
+	}
+	sort.SliceStable(dirs, func(i, j int) bool {
+		if rank(dirs[i]) != rank(dirs[j]) {
+			return rank(dirs[i]) < rank(dirs[j])
+		}
+		return dirs[i] < dirs[j]
+	})
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+var searchPrefixes rootSlice
+
+func init() {
+	cmdline.Var(&searchPrefixes, "s-prefix", "dir of installed SDKs, path[:after], each child with an include dir becomes a search root")
+}
+
+var sdkVersionRe = regexp.MustCompile(`\d+(\.\d+)*`)
+
+// checkPrefixes adds the include dirs of the children of -s-prefix to the
+// search roots, with the attributes of their prefix. Of several versions of an
+// SDK the newest comes first.
+func checkPrefixes() error {
+	for _, prefix := range searchPrefixes {
+		files, err := ioutil.ReadDir(prefix.Path)
+		if err != nil {
+			return err
+		}
+		var names []string
+		for _, f := range files {
+			if f.Name()[0] == '.' || isExcluded(f.Name()) {
+				continue
+			}
+			info, err := os.Stat(filepath.Join(prefix.Path, f.Name(), "include"))
+			if err == nil && info.IsDir() {
+				names = append(names, f.Name())
+			}
+		}
+		sort.Slice(names, func(i, j int) bool {
+			a, b := sdkVersionRe.FindString(names[i]), sdkVersionRe.FindString(names[j])
+			if a != b {
+				return newerVersion(a, b)
+			}
+			return names[i] < names[j]
+		})
+		if len(names) == 0 {
+			fmt.Fprintf(os.Stderr, "-s-prefix %s: no child has an include dir\n", prefix.Path)
+		}
+		for _, name := range names {
+			root := prefix
+			root.Path = filepath.Join(prefix.Path, name, "include")
+			searchroots = append(searchroots, root)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+var (
+	cpuprofile = cmdline.String("cpuprofile", "", "write cpu profile to file")
+	memprofile = cmdline.String("memprofile", "", "write heap profile to file on exit")
+	traceFile  = cmdline.String("trace", "", "write execution trace to file")
+	maxMem     = cmdline.Int64("max-mem", 0, "soft memory limit in MB, the gc works harder to stay below it and the header index moves to a temporary file past it")
+)
+
+// startProfile enables the requested profiles and returns a function that
+// stops them.
+func startProfile() (func(), error) {
+	var stops []func()
+	stop := func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+
+	if *maxMem > 0 {
+		debug.SetMemoryLimit(*maxMem << 20)
+	}
+	if *cpuprofile != "" {
+		f, err := os.Create(*cpuprofile)
+		if err != nil {
+			return nil, err
+		}
+		err = pprof.StartCPUProfile(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		})
+	}
+	if *traceFile != "" {
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		err = trace.Start(f)
+		if err != nil {
+			f.Close()
+			stop()
+			return nil, err
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			f.Close()
+		})
+	}
+	if *memprofile != "" {
+		stops = append(stops, func() {
+			f, err := os.Create(*memprofile)
+			if err != nil {
+				log.Debug("memprofile: %s", err)
+				return
+			}
+			runtime.GC()
+			pprof.WriteHeapProfile(f)
+			f.Close()
+		})
+	}
+	return stop, nil
+}
@@ -0,0 +1,337 @@
+package cli
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+var (
+	qmakePro = cmdline.String("qmake", "", "merge include dirs and defines from a qmake .pro file and its Qt kit")
+	qmakeOut = cmdline.String("qmake-out", "", "build dir of the .pro file for moc and uic outputs, the .pro dir by default")
+)
+
+var qmakeVarRe = regexp.MustCompile(`\$\$(\{[A-Za-z_][\w.]*\}|\[[A-Za-z_][\w/]*\]|\([A-Za-z_]\w*\)|[A-Za-z_][\w.]*)`)
+
+// module names which are not Qt + capitalized name
+var qtModules = map[string]string{
+	"testlib": "QtTest", "printsupport": "QtPrintSupport", "dbus": "QtDBus",
+	"websockets": "QtWebSockets", "serialport": "QtSerialPort", "opengl": "QtOpenGL",
+	"quickcontrols2": "QtQuickControls2", "webenginewidgets": "QtWebEngineWidgets",
+}
+
+type qmakeProject struct {
+	vars  map[string][]string
+	props map[string]string
+}
+
+// qmakeQuery returns the properties of the installed Qt kit.
+func qmakeQuery() map[string]string {
+	props := make(map[string]string)
+	for _, name := range []string{"qmake", "qmake6", "qmake-qt5"} {
+		out, err := exec.Command(name, "-query").Output()
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			if i := strings.Index(line, ":"); i > 0 {
+				props[line[:i]] = strings.TrimSpace(line[i+1:])
+			}
+		}
+		break
+	}
+	return props
+}
+
+func (q *qmakeProject) expand(s string) string {
+	return qmakeVarRe.ReplaceAllStringFunc(s, func(m string) string {
+		name := m[2:]
+		switch name[0] {
+		case '{':
+			name = name[1 : len(name)-1]
+		case '[':
+			return q.props[name[1:len(name)-1]]
+		case '(':
+			return os.Getenv(name[1 : len(name)-1])
+		}
+		return strings.Join(q.vars[name], " ")
+	})
+}
+
+// cond evaluates a scope condition. Only the platform scopes are known,
+// others like CONFIG(debug) are taken as true.
+func qmakeCond(cond string) bool {
+	cond = strings.TrimSpace(cond)
+	if strings.HasPrefix(cond, "!") {
+		return !qmakeCond(cond[1:])
+	}
+	for _, c := range strings.Split(cond, "|") {
+		switch strings.TrimSpace(c) {
+		case "unix":
+			if runtime.GOOS != "windows" {
+				return true
+			}
+		case "linux":
+			if runtime.GOOS == "linux" {
+				return true
+			}
+		case "mac", "macx", "macos", "darwin":
+			if runtime.GOOS == "darwin" {
+				return true
+			}
+		case "win32", "windows", "mingw", "msvc":
+			if runtime.GOOS == "windows" {
+				return true
+			}
+		case "android", "ios", "wasm", "emscripten":
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// splitScope splits "a:b:VAR += x" into the conditions and the statement,
+// leaving ":" inside function calls and values alone.
+func splitScope(line string) ([]string, string) {
+	var conds []string
+	depth := 0
+	start := 0
+	for i, c := range line {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '=', '{', '}':
+			if depth == 0 {
+				return conds, line[start:]
+			}
+		case ':':
+			if depth == 0 {
+				conds = append(conds, line[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return conds, line[start:]
+}
+
+func (q *qmakeProject) parse(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(file)
+	// 每层作用域是否生效
+	var scopes []bool
+	active := func() bool {
+		for _, ok := range scopes {
+			if !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	scanner := bufio.NewScanner(f)
+	var line string
+	for scanner.Scan() {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		if strings.HasSuffix(text, "\\") {
+			line += text[:len(text)-1] + " "
+			continue
+		}
+		line = strings.TrimSpace(line + text)
+		stmt := line
+		line = ""
+		if stmt == "" {
+			continue
+		}
+
+		q.vars["PWD"] = []string{dir}
+		for strings.HasPrefix(stmt, "}") {
+			if len(scopes) > 0 {
+				last := scopes[len(scopes)-1]
+				scopes = scopes[:len(scopes)-1]
+				stmt = strings.TrimSpace(stmt[1:])
+				if strings.HasPrefix(stmt, "else") {
+					stmt = strings.TrimSpace(stmt[len("else"):])
+					if strings.HasPrefix(stmt, "{") {
+						scopes = append(scopes, !last)
+						stmt = strings.TrimSpace(stmt[1:])
+					}
+				}
+			} else {
+				stmt = strings.TrimSpace(stmt[1:])
+			}
+		}
+		if stmt == "" {
+			continue
+		}
+
+		conds, rest := splitScope(stmt)
+		ok := true
+		for _, c := range conds {
+			ok = ok && qmakeCond(c)
+		}
+		rest = strings.TrimSpace(rest)
+		if strings.HasSuffix(rest, "{") && !strings.Contains(rest, "=") {
+			cond := strings.TrimSpace(strings.TrimSuffix(rest, "{"))
+			scopes = append(scopes, ok && (cond == "" || qmakeCond(cond)))
+			continue
+		}
+		if !ok || !active() {
+			continue
+		}
+		if strings.HasPrefix(rest, "include(") && strings.HasSuffix(rest, ")") {
+			name := q.expand(strings.Trim(rest[len("include("):len(rest)-1], `" `))
+			if !filepath.IsAbs(name) {
+				name = filepath.Join(dir, name)
+			}
+			err = q.parse(name)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		q.assign(rest)
+	}
+	return scanner.Err()
+}
+
+func (q *qmakeProject) assign(stmt string) {
+	i := strings.Index(stmt, "=")
+	if i <= 0 {
+		return
+	}
+	op := "="
+	name := stmt[:i]
+	if strings.ContainsAny(name[len(name)-1:], "+-*~") {
+		op = name[len(name)-1:] + "="
+		name = name[:len(name)-1]
+	}
+	name = strings.TrimSpace(name)
+	values := splitQuoted(q.expand(stmt[i+1:]))
+	switch op {
+	case "=":
+		q.vars[name] = values
+	case "+=":
+		q.vars[name] = append(q.vars[name], values...)
+	case "*=":
+		for _, v := range values {
+			if !containsString(q.vars[name], v) {
+				q.vars[name] = append(q.vars[name], v)
+			}
+		}
+	case "-=":
+		var l []string
+		for _, v := range q.vars[name] {
+			if !containsString(values, v) {
+				l = append(l, v)
+			}
+		}
+		q.vars[name] = l
+	}
+}
+
+// qmakeFlags returns the include dirs and defines of a .pro file, with those
+// of the Qt modules it uses and its moc and uic output dirs.
+func qmakeFlags(pro string) (dirs []string, flags []string, err error) {
+	pro, err = filepath.Abs(pro)
+	if err != nil {
+		return nil, nil, err
+	}
+	prodir := filepath.Dir(pro)
+	outdir := prodir
+	if *qmakeOut != "" {
+		outdir, err = filepath.Abs(*qmakeOut)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	q := &qmakeProject{
+		vars: map[string][]string{
+			"QT":             {"core", "gui"},
+			"_PRO_FILE_":     {pro},
+			"_PRO_FILE_PWD_": {prodir},
+			"OUT_PWD":        {outdir},
+			"TARGET":         {strings.TrimSuffix(filepath.Base(pro), ".pro")},
+			"QMAKE_HOST.os":  {runtime.GOOS},
+		},
+		props: qmakeQuery(),
+	}
+	err = q.parse(pro)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	abs := func(base, p string) string {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(base, p)
+		}
+		return filepath.Clean(p)
+	}
+	for _, dir := range q.vars["INCLUDEPATH"] {
+		dirs = append(dirs, abs(prodir, dir))
+	}
+	for _, name := range []string{"UI_DIR", "MOC_DIR", "RCC_DIR"} {
+		for _, dir := range q.vars[name] {
+			dirs = append(dirs, abs(outdir, dir))
+		}
+	}
+	dirs = append(dirs, outdir)
+	for _, d := range q.vars["DEFINES"] {
+		flags = append(flags, "-D"+d)
+	}
+
+	headers := q.props["QT_INSTALL_HEADERS"]
+	if headers == "" {
+		return dedup(dirs), flags, nil
+	}
+	dirs = append(dirs, headers)
+	if spec := q.props["QMAKE_SPEC"]; spec != "" {
+		for _, key := range []string{"QT_HOST_DATA", "QT_INSTALL_ARCHDATA"} {
+			mkspec := filepath.Join(q.props[key], "mkspecs", spec)
+			if _, err := os.Stat(mkspec); err == nil {
+				dirs = append(dirs, mkspec)
+				break
+			}
+		}
+	}
+	libs := q.props["QT_INSTALL_LIBS"]
+	frameworks := false
+	for _, m := range q.vars["QT"] {
+		m = strings.TrimSuffix(m, "-private")
+		name, ok := qtModules[m]
+		if !ok {
+			name = "Qt" + strings.ToUpper(m[:1]) + m[1:]
+		}
+		flags = append(flags, "-DQT_"+strings.ToUpper(m)+"_LIB")
+		if _, err := os.Stat(filepath.Join(headers, name)); err == nil {
+			dirs = append(dirs, filepath.Join(headers, name))
+			continue
+		}
+		// macOS 上 Qt 以 framework 形式安装
+		fw := filepath.Join(libs, name+".framework", "Headers")
+		if _, err := os.Stat(fw); err == nil {
+			dirs = append(dirs, fw)
+			frameworks = true
+		}
+	}
+	if frameworks {
+		flags = append(flags, "-F"+libs)
+	}
+	return dedup(dirs), flags, nil
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// runQuery lists the indexed headers matching a glob or a regexp with the
+// roots providing them.
+func runQuery(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	index := fs.String("index", *loadIndex, "index saved with -save-index, the search roots are scanned without one")
+	useRegexp := fs.Bool("regexp", false, "the pattern is a regular expression matched against the full header paths")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: clang_complete " + commands["query"].usage)
+	}
+
+	var re *regexp.Regexp
+	var err error
+	if *useRegexp {
+		re, err = regexp.Compile(fs.Arg(0))
+	} else {
+		re, err = globRegexp(fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+
+	t := newTree()
+	roots := searchroots
+	if *index != "" {
+		idx, err := openIndex(*index)
+		switch {
+		case err == errStaleIndex:
+			fmt.Fprintf(os.Stderr, "%s: %s, scanning its roots again\n", *index, err)
+		case err != nil:
+			return err
+		default:
+			defer idx.Close()
+			roots = uncovered(roots, t.Load(idx))
+		}
+	}
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	headerext, _ := suffixes(cfg)
+	for _, root := range roots {
+		if err := t.ScanRoot(root, headerext); err != nil {
+			return err
+		}
+	}
+
+	specs, entries := t.indexEntries()
+	for _, e := range entries {
+		if !matchEntry(re, *useRegexp, e) {
+			continue
+		}
+		var root rootSpec
+		for _, spec := range specs {
+			if underAny(e.path, []string{spec.Path}) && len(spec.Path) > len(root.Path) {
+				root = spec
+			}
+		}
+		root.Path = mapPath(root.Path)
+		fmt.Printf("%s\t%s\n", mapPath(e.path), root)
+	}
+	return nil
+}
+
+// matchEntry reports whether one of the names e can be included by matches
+// re.
+// Regular expressions match the full path instead.
+func matchEntry(re *regexp.Regexp, full bool, e indexEntry) bool {
+	p := e.path
+	if full {
+		return re.MatchString(filepath.ToSlash(p))
+	}
+	// 和搜索一样，头文件名不能超出根目录
+	for i := 0; i < len(p); i++ {
+		if p[i] == filepath.Separator && i+1 >= e.min && re.MatchString(filepath.ToSlash(p[i+1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// globRegexp compiles a glob where * and ? stay within a path element and **
+// spans any number of them.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			j := strings.IndexByte(glob[i:], ']')
+			if j < 0 {
+				return nil, fmt.Errorf("bad pattern %q", glob)
+			}
+			class := glob[i+1 : i+j]
+			// shell 的 [!x] 在正则中写作 [^x]，同样不匹配路径分隔符
+			if strings.HasPrefix(class, "!") {
+				class = "^/" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += j
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
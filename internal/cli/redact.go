@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// redaction replaces the prefix of paths in the outputs. An empty prefix
+// makes the paths under the dir of the output relative to it.
+type redaction struct {
+	prefix, placeholder string
+	// 只有 make 会展开 $(HOME)
+	home bool
+}
+
+type redactionSlice []redaction
+
+var redactions redactionSlice
+
+func (s *redactionSlice) String() string {
+	var l []string
+	for _, r := range *s {
+		l = append(l, r.prefix+"="+r.placeholder)
+	}
+	return fmt.Sprintf("%q", l)
+}
+
+// Set takes "relative", "home", "prefix" or "prefix=placeholder".
+func (s *redactionSlice) Set(value string) error {
+	switch value {
+	case "relative":
+		*s = append(*s, redaction{})
+		return nil
+	case "home":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		*s = append(*s, redaction{filepath.Clean(home), "$(HOME)", true})
+		return nil
+	}
+	prefix, placeholder := value, "<redacted>"
+	if i := strings.Index(value, "="); i >= 0 {
+		prefix, placeholder = value[:i], value[i+1:]
+	}
+	if !filepath.IsAbs(prefix) {
+		return fmt.Errorf("redacted prefix %q is not absolute", prefix)
+	}
+	*s = append(*s, redaction{filepath.Clean(prefix), placeholder, false})
+	return nil
+}
+
+// checkRedact refuses -redact home for the formats whose readers do not
+// expand $(HOME): clang does not expand ~ or variables in -I either.
+func checkRedact(f *format) error {
+	for _, r := range redactions {
+		if r.home && f.name != "make" {
+			return fmt.Errorf("-redact home needs -format make, %s is read without expanding the home dir", f.output)
+		}
+	}
+	return nil
+}
+
+// redactPath applies the redactions to p, written to an output in dir, or
+// with dir empty where paths must stay absolute.
+func redactPath(p, dir string) string {
+	for _, r := range redactions {
+		if r.prefix == "" {
+			if dir == "" {
+				continue
+			}
+			if rel, err := filepath.Rel(dir, p); err == nil && filepath.IsAbs(p) && !strings.HasPrefix(rel, "..") {
+				return rel
+			}
+			continue
+		}
+		if p == r.prefix || strings.HasPrefix(p, r.prefix+string(filepath.Separator)) {
+			return r.placeholder + p[len(r.prefix):]
+		}
+	}
+	return p
+}
+
+// redacted returns a copy of fs with the paths redacted for an output of
+// format f in dir.
+func (fs *flagSet) redacted(f *format, dir string) *flagSet {
+	if len(redactions) == 0 {
+		return fs
+	}
+	// compile_commands.json 的相对路径相对于每一项的 directory
+	if f.name == "compdb" {
+		dir = ""
+	}
+	redact := func(p string) string { return redactPath(p, dir) }
+	all := func(l []string) []string {
+		var ret []string
+		for _, p := range l {
+			ret = append(ret, redact(p))
+		}
+		return ret
+	}
+	ret := *fs
+	// 来源记录只给 rescan 用，不随脱敏的输出写出
+	ret.Uses = nil
+	ret.Includes = all(fs.Includes)
+	ret.Systems = all(fs.Systems)
+	ret.After = all(fs.After)
+	ret.Test = all(fs.Test)
+	ret.Files = all(fs.Files)
+	ret.Flags = mapFlagsWith(fs.Flags, redact)
+	if fs.Lang != nil {
+		ret.Lang = make(map[string][]string)
+		for lang, flags := range fs.Lang {
+			ret.Lang[lang] = mapFlagsWith(flags, redact)
+		}
+	}
+	if fs.FileIncludes != nil {
+		ret.FileIncludes = make(map[string][]string)
+		for file, dirs := range fs.FileIncludes {
+			ret.FileIncludes[redact(file)] = all(dirs)
+		}
+	}
+	if fs.FileFlags != nil {
+		ret.FileFlags = make(map[string][]string)
+		for file, flags := range fs.FileFlags {
+			ret.FileFlags[redact(file)] = mapFlagsWith(flags, redact)
+		}
+	}
+	// 命令行里的路径也要处理
+	if fs.Meta != nil {
+		m := *fs.Meta
+		var args []string
+		for _, arg := range strings.Fields(m.Command) {
+			if filepath.IsAbs(arg) {
+				arg = redact(arg)
+			}
+			args = append(args, mapFlagsWith([]string{arg}, redact)...)
+		}
+		m.Command = strings.Join(args, " ")
+		ret.Meta = &m
+	}
+	return &ret
+}
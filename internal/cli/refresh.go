@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"container/list"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// recentFiles are the files an editor asked for last, most recent first.
+type recentFiles struct {
+	lock sync.Mutex
+	max  int
+	l    *list.List
+	m    map[string]*list.Element
+}
+
+func newRecentFiles(max int) *recentFiles {
+	return &recentFiles{
+		max: max,
+		l:   list.New(),
+		m:   make(map[string]*list.Element),
+	}
+}
+
+// Touch moves path to the front, dropping the oldest file when full.
+func (r *recentFiles) Touch(path string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if e, ok := r.m[path]; ok {
+		r.l.MoveToFront(e)
+		return
+	}
+	r.m[path] = r.l.PushFront(path)
+	if r.l.Len() > r.max {
+		e := r.l.Back()
+		r.l.Remove(e)
+		delete(r.m, e.Value.(string))
+	}
+}
+
+func (r *recentFiles) Has(path string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	_, ok := r.m[path]
+	return ok
+}
+
+func (r *recentFiles) List() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var ret []string
+	for e := r.l.Front(); e != nil; e = e.Next() {
+		ret = append(ret, e.Value.(string))
+	}
+	return ret
+}
+
+// refresh re-resolves the changed files every interval until ctx is done,
+// the recent files ahead of the others: they are checked again before each
+// file of the background work.
+func (s *server) refresh(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var background []string
+		for _, path := range s.r.Cached() {
+			if !s.recent.Has(path) && s.r.Stale(path) {
+				background = append(background, path)
+			}
+		}
+		sort.Strings(background)
+		for {
+			s.refreshRecent(ctx)
+			if len(background) == 0 || ctx.Err() != nil {
+				break
+			}
+			path := background[0]
+			background = background[1:]
+			s.refreshFile(ctx, path)
+		}
+	}
+}
+
+// refreshRecent re-resolves the recent files that changed.
+func (s *server) refreshRecent(ctx context.Context) {
+	for _, path := range s.recent.List() {
+		if ctx.Err() != nil {
+			return
+		}
+		if s.r.Stale(path) {
+			s.refreshFile(ctx, path)
+		}
+	}
+}
+
+func (s *server) refreshFile(ctx context.Context, path string) {
+	log.Debug("refresh %s", path)
+	if _, err := s.r.FlagsForFile(ctx, path); err != nil {
+		log.Debug("refresh %s: %s", path, err)
+	}
+}
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var regenScript = cmdline.String("regen", "", "write a shell script to file that reruns this generation with the same command line and environment")
+
+// environment variables that change what the compiler and the scan see
+var regenEnv = []string{
+	"CC", "CPATH", "C_INCLUDE_PATH", "CPLUS_INCLUDE_PATH", "OBJC_INCLUDE_PATH",
+	"SDKROOT", "DEVELOPER_DIR", "CUDA_PATH", "CUDA_HOME", "MSYSTEM", "PATH",
+}
+
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-./=:,+@%") == "" {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func writeRegen(name string) error {
+	buf := new(bytes.Buffer)
+	fmt.Fprintln(buf, "#!/bin/sh")
+	fmt.Fprintf(buf, "# generated by clang_complete %s\n", version)
+
+	cc := compiler()
+	path, err := exec.LookPath(cc)
+	if err == nil {
+		cc = path
+	}
+	out, _ := ccCommand("--version").Output()
+	if i := bytes.IndexByte(out, '\n'); i >= 0 {
+		out = out[:i]
+	}
+	fmt.Fprintf(buf, "# compiler: %s (%s)\n", cc, bytes.TrimSpace(out))
+	fmt.Fprintln(buf, "set -e")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(buf, "cd %s\n", shellQuote(wd))
+	for _, key := range regenEnv {
+		if value, ok := os.LookupEnv(key); ok {
+			fmt.Fprintf(buf, "export %s=%s\n", key, shellQuote(value))
+		}
+	}
+
+	args := []string{shellQuote(executable())}
+	for _, arg := range os.Args[1:] {
+		args = append(args, shellQuote(arg))
+	}
+	fmt.Fprintf(buf, "exec %s\n", strings.Join(args, " "))
+	return ioutil.WriteFile(name, buf.Bytes(), 0755)
+}
+
+// executable returns the path of the running binary, which os.Args[0] is not
+// once it was found in PATH or the cwd changed.
+func executable() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return os.Args[0]
+	}
+	return exe
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var relativeIncl = cmdline.String("relative-includes", "search", `headers named like "../x.h": search looks them up in the search roots like other headers, source resolves them next to the including file only`)
+
+func isRelativeInclude(h string) bool {
+	return strings.HasPrefix(h, "./") || strings.HasPrefix(h, "../")
+}
+
+// checkRelative handles a relative include h of p that the compiler did not
+// find. It reports false if h should be searched like other headers.
+func checkRelative(ctx context.Context, p, h string) bool {
+	if *relativeIncl != "source" || !isRelativeInclude(h) {
+		return false
+	}
+	// 相对当前文件可以找到的头文件不需要任何 -I
+	if _, err := os.Stat(filepath.Join(filepath.Dir(p), h)); err != nil {
+		sessionOf(ctx).rep.Relative(p, h)
+	}
+	return true
+}
+
+func checkRelativeFlag() error {
+	switch *relativeIncl {
+	case "search", "source":
+		return nil
+	}
+	return fmt.Errorf("unknown -relative-includes %q, want search or source", *relativeIncl)
+}
@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type report struct {
+	lock       sync.Mutex
+	scanErrors []error
+	vendored   []string
+	suggested  map[string]string
+	relative   map[string][]string
+	stuck      map[string][]string
+	tooDeep    []string
+	shadowed   []shadowed
+	unresolved map[string][]includeSite
+	skipped    []string
+	never      map[string][]includeSite
+	neverDirs  map[string][]string
+	timedOut   []string
+	versions   map[string]string
+	caseSites  map[string][]includeSite
+	caseNames  map[string][]string
+}
+
+var rep = newReport()
+
+func newReport() *report {
+	return &report{
+		suggested:  make(map[string]string),
+		relative:   make(map[string][]string),
+		stuck:      make(map[string][]string),
+		unresolved: make(map[string][]includeSite),
+		never:      make(map[string][]includeSite),
+		neverDirs:  make(map[string][]string),
+		versions:   make(map[string]string),
+		caseSites:  make(map[string][]includeSite),
+		caseNames:  make(map[string][]string),
+	}
+}
+
+func (r *report) ScanError(err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.scanErrors = append(r.scanErrors, err)
+}
+
+func (r *report) Vendored(roots []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.vendored = roots
+}
+
+func (r *report) Suggest(header, dir string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.suggested[header] = dir
+}
+
+func (r *report) Relative(src, header string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, h := range r.relative[src] {
+		if h == header {
+			return
+		}
+	}
+	r.relative[src] = append(r.relative[src], header)
+}
+
+func (r *report) TooDeep(dir string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.tooDeep = append(r.tooDeep, dir)
+}
+
+func (r *report) Stuck(src string, headers []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.stuck[src] = headers
+}
+
+func (r *report) Shadowed(l []shadowed) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.shadowed = l
+}
+
+func (r *report) Print(w io.Writer) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.stuck) != 0 {
+		var srcs []string
+		for src := range r.stuck {
+			srcs = append(srcs, src)
+		}
+		sort.Strings(srcs)
+		fmt.Fprintf(w, "gave up rescanning files resolving the same headers every round, include cycles or headers generated by the build:\n")
+		for _, src := range srcs {
+			fmt.Fprintf(w, "  %s: %s\n", mapPath(src), strings.Join(r.stuck[src], " "))
+		}
+	}
+
+	if len(r.unresolved) != 0 {
+		r.printUnresolved(w)
+	}
+	if len(r.never) != 0 {
+		r.printNever(w)
+	}
+	if len(r.caseSites) != 0 {
+		r.printCase(w)
+	}
+	if len(r.versions) != 0 {
+		r.printVersions(w)
+	}
+	if len(r.timedOut) != 0 {
+		fmt.Fprintf(w, "phases that timed out:\n")
+		for _, s := range r.timedOut {
+			fmt.Fprintf(w, "  %s\n", s)
+		}
+	}
+	if len(r.tooDeep) != 0 {
+		sort.Strings(r.tooDeep)
+		fmt.Fprintf(w, "skipped %d dirs deeper than -max-depth %d:\n", len(r.tooDeep), *maxDepth)
+		for _, dir := range r.tooDeep {
+			fmt.Fprintf(w, "  %s\n", mapPath(dir))
+		}
+	}
+	if len(r.skipped) != 0 {
+		sort.Strings(r.skipped)
+		fmt.Fprintf(w, "skipped %d files with source suffixes:\n", len(r.skipped))
+		for _, p := range r.skipped {
+			fmt.Fprintf(w, "  %s\n", p)
+		}
+	}
+	if len(r.scanErrors) != 0 {
+		fmt.Fprintf(w, "skipped %d unreadable paths:\n", len(r.scanErrors))
+		for _, err := range r.scanErrors {
+			fmt.Fprintf(w, "  %s\n", err)
+		}
+	}
+	if len(r.suggested) != 0 {
+		var headers []string
+		for h := range r.suggested {
+			headers = append(headers, h)
+		}
+		sort.Strings(headers)
+		fmt.Fprintf(w, "missing headers found by locate, add them with -s or -locate-add:\n")
+		for _, h := range headers {
+			fmt.Fprintf(w, "  %s: %s\n", h, r.suggested[h])
+		}
+	}
+	if len(r.relative) != 0 {
+		var srcs []string
+		for src := range r.relative {
+			srcs = append(srcs, src)
+		}
+		sort.Strings(srcs)
+		fmt.Fprintf(w, "relative includes not found next to their source:\n")
+		for _, src := range srcs {
+			for _, h := range r.relative[src] {
+				fmt.Fprintf(w, "  %s: %s\n", mapPath(src), h)
+			}
+		}
+	}
+	if len(r.shadowed) != 0 {
+		fmt.Fprintf(w, "headers in several include dirs, the first one wins:\n")
+		for _, s := range r.shadowed {
+			fmt.Fprintf(w, "  %s:\n", s.header)
+			for _, dir := range s.dirs {
+				fmt.Fprintf(w, "    %s\n", mapPath(dir))
+			}
+			srcs := s.srcs
+			if len(srcs) > *statsTop {
+				srcs = srcs[:*statsTop]
+			}
+			for i := range srcs {
+				srcs[i] = mapPath(srcs[i])
+			}
+			more := ""
+			if len(s.srcs) > len(srcs) {
+				more = fmt.Sprintf(" and %d more", len(s.srcs)-len(srcs))
+			}
+			fmt.Fprintf(w, "    included by %s%s\n", strings.Join(srcs, " "), more)
+		}
+	}
+	if len(r.vendored) != 0 {
+		fmt.Fprintf(w, "vendored libraries (-isystem):\n")
+		for _, dir := range r.vendored {
+			fmt.Fprintf(w, "  %s\n", mapPath(dir))
+		}
+	}
+}
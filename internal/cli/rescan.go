@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// runRescan resolves again the sources under a dir and merges the results
+// into the existing output, the rest of the sources are kept as they are.
+func runRescan(args []string) error {
+	fs := flag.NewFlagSet("rescan", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: clang_complete " + commands["rescan"].usage)
+	}
+	dir, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	if err := cfg.apply(); err != nil {
+		return err
+	}
+	headerext, srcext := suffixes(cfg)
+	f, err := lookupFormat(*outFormat)
+	if err != nil {
+		return err
+	}
+	if !isFlagSet("o") {
+		*output = f.output
+	}
+	set, err := readFlagSet(*output, f)
+	if err != nil {
+		return fmt.Errorf("rescan needs an existing output: %v", err)
+	}
+
+	// 输出文件一般在项目根目录，上层目录的规则也要生效
+	root := dir
+	if out, err := filepath.Abs(filepath.Dir(*output)); err == nil && underAny(dir, []string{out}) {
+		root = out
+	}
+	dirRules.SetRoot(root)
+
+	l := list.New()
+	if err := collect(dir, l, srcext); err != nil {
+		return err
+	}
+	var sources []string
+	for e := l.Front(); e != nil; e = e.Next() {
+		sources = append(sources, e.Value.(string))
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("no sources under %s", dir)
+	}
+
+	sandboxAllow(dir)
+	for _, root := range searchroots.Paths() {
+		if abs, err := filepath.Abs(root); err == nil {
+			sandboxAllow(abs)
+		}
+	}
+	r := newResolver(searchroots, headerext)
+	cur, err := r.resolve(context.Background(), sources)
+	if err != nil {
+		return err
+	}
+
+	// 删除的源码不再列出
+	var files []string
+	for _, file := range set.Files {
+		if !underAny(file, []string{dir}) {
+			files = append(files, file)
+		}
+	}
+	set.Files = files
+	old := outputLines(*output, f)
+	if set.Uses != nil {
+		set.dropSources(dir)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s is missing, include dirs the sources under %s no longer need are kept\n", usesFile(*output), dir)
+	}
+	set.merge(cur)
+	fmt.Fprintf(os.Stderr, "rescanned %d sources under %s\n", len(sources), dir)
+	if err := writeFlagSet(*output, f, set); err != nil {
+		return err
+	}
+	return auditChanges(*output, old, outputLines(*output, f))
+}
@@ -0,0 +1,371 @@
+package cli
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resolver computes the flags of single files on demand. The header index is
+// built on first use and results are memoized until the file changes.
+// It is safe for concurrent use.
+type resolver struct {
+	roots     rootSlice
+	headerext map[string]bool
+	// index loaded instead of -load-index, see restoreState
+	indexFile string
+	// listings are the listing hashes of the roots when they were indexed,
+	// kept for the state of serve when fingerprint is set
+	fingerprint bool
+	listings    map[string]string
+
+	// index 失败后下次调用重试，indexed 之后不再改变 t 和 sys
+	indexLock sync.Mutex
+	indexed   bool
+	closed    bool
+	t         *tree
+	sys       []string
+	// 每个 resolver 自己的统计和报告，不和其他调用共享
+	sess *session
+
+	lock  sync.Mutex
+	cache map[string]resolved
+}
+
+var errClosed = errors.New("resolver is closed")
+
+type resolved struct {
+	mtime time.Time
+	flags []string
+}
+
+func newResolver(roots rootSlice, headerext map[string]bool) *resolver {
+	// 没有源码目录的子命令只在搜索根目录里找 #embed 的资源
+	if resources.roots == nil {
+		resources.roots = roots.Paths()
+	}
+	// 同样，.clang_complete_dir 读到当前目录为止
+	if dirRules.getRoot() == "" {
+		if wd, err := os.Getwd(); err == nil {
+			dirRules.SetRoot(wd)
+		}
+	}
+	return &resolver{
+		roots:     roots,
+		headerext: headerext,
+		sess:      newSession(),
+		cache:     make(map[string]resolved),
+	}
+}
+
+// index builds the header index on first use. After an error the next call
+// tries again.
+func (r *resolver) index() error {
+	r.indexLock.Lock()
+	defer r.indexLock.Unlock()
+	if r.closed {
+		return errClosed
+	}
+	if r.indexed {
+		return nil
+	}
+	sys, err := systemheaders()
+	if err != nil {
+		return err
+	}
+	t := newTree()
+	t.rep = r.sess.rep
+	roots := r.roots
+	name := *loadIndex
+	if r.indexFile != "" {
+		name = r.indexFile
+	}
+	if name != "" {
+		idx, err := openIndex(name)
+		switch {
+		case err == errStaleIndex:
+			fmt.Fprintf(os.Stderr, "%s: %s, scanning its roots again\n", name, err)
+		case err != nil:
+			return err
+		default:
+			roots = uncovered(roots, t.Load(idx))
+		}
+	}
+	for _, root := range roots {
+		// 先记下目录列表，索引期间的修改会在下次启动时发现
+		if p, err := filepath.Abs(root.Path); err == nil && r.fingerprint {
+			r.SetListing(p, listingHash(fingerprint(p, nil)))
+		}
+		if err := t.ScanRoot(root, r.headerext); err != nil {
+			t.Close()
+			return err
+		}
+	}
+	r.t, r.sys, r.indexed = t, sys, true
+	return nil
+}
+
+// Close releases the header index, after it is built if that is under way.
+func (r *resolver) Close() {
+	r.indexLock.Lock()
+	defer r.indexLock.Unlock()
+	r.closed = true
+	if r.t != nil {
+		r.t.Close()
+	}
+}
+
+// SetListing records the listing hash the index of root was built from,
+// empty when it is not known any more.
+func (r *resolver) SetListing(root, hash string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.listings == nil {
+		r.listings = make(map[string]string)
+	}
+	r.listings[root] = hash
+}
+
+// Listings returns the listing hashes of the indexed roots.
+func (r *resolver) Listings() map[string]string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	ret := make(map[string]string, len(r.listings))
+	for root, hash := range r.listings {
+		ret[root] = hash
+	}
+	return ret
+}
+
+func (r *resolver) FlagsForFile(ctx context.Context, path string) ([]string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	c, ok := r.cache[path]
+	r.lock.Unlock()
+	if ok && c.mtime.Equal(info.ModTime()) {
+		return c.flags, nil
+	}
+
+	fs, err := r.resolve(ctx, []string{path})
+	if err != nil {
+		return nil, err
+	}
+	flags := fs.ArgsFor(path)
+	r.lock.Lock()
+	r.cache[path] = resolved{info.ModTime(), flags}
+	r.lock.Unlock()
+	return flags, nil
+}
+
+// Cached returns the files with memoized flags.
+func (r *resolver) Cached() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var ret []string
+	for path := range r.cache {
+		ret = append(ret, path)
+	}
+	return ret
+}
+
+// Forget drops the memoized flags of paths.
+func (r *resolver) Forget(paths []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, path := range paths {
+		delete(r.cache, path)
+	}
+}
+
+// Stale reports whether path changed since its flags were memoized. Removed
+// files are forgotten.
+func (r *resolver) Stale(path string) bool {
+	r.lock.Lock()
+	c, ok := r.cache[path]
+	r.lock.Unlock()
+	if !ok {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		r.lock.Lock()
+		delete(r.cache, path)
+		r.lock.Unlock()
+		return false
+	}
+	return !c.mtime.Equal(info.ModTime())
+}
+
+// Invalidate makes all memoized flags stale, after the index changed.
+func (r *resolver) Invalidate() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for path, c := range r.cache {
+		c.mtime = time.Time{}
+		r.cache[path] = c
+	}
+}
+
+// resolve computes the flags shared by paths, which must be absolute.
+func (r *resolver) resolve(ctx context.Context, paths []string) (*flagSet, error) {
+	if err := r.index(); err != nil {
+		return nil, err
+	}
+
+	ctx = withSession(ctx, r.sess)
+	printer := newPrinter(nil)
+	printer.SetStats(r.sess.stats)
+	printer.SetAfter(r.t.After)
+	printer.SetRole(r.t.Role)
+	printer.AddSys(r.sys)
+	if printSystem == "true" {
+		printer.Printdirs(r.sys)
+	}
+	printer.AddFlags(ccflags)
+	printer.AddFlags(targetFlags())
+
+	lock := new(sync.Mutex)
+	queue := list.New()
+	retries := newRetrySet(r.sess.rep)
+	for _, path := range paths {
+		queue.PushBack(path)
+	}
+	// 每一轮都可能因为新的搜索目录发现更多头文件
+	for queue.Len() != 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n := printer.Len()
+		queue.Init()
+		for _, path := range paths {
+			searchFile(ctx, path, r.headerext, r.t, printer, lock, queue, retries)
+		}
+		if printer.Len() == n {
+			break
+		}
+	}
+
+	if printSystem == "used" {
+		printer.Printdirs(r.sess.sysUsed.Dirs(r.sys))
+	}
+	printer.AddFiles(paths)
+	return printer.FlagSet(), nil
+}
+
+// Resolver resolves single files in process for pkg/clangcomplete.
+type Resolver struct {
+	r      *resolver
+	srcext map[string]bool
+}
+
+// options are the options of the process, parsed by the first NewResolver
+// unless Main parsed them.
+var options struct {
+	lock   sync.Mutex
+	parsed bool
+	args   []string
+	err    error
+}
+
+// configure parses args, the options given before a subcommand, and applies
+// the config file they name, once per process.
+func configure(args []string) error {
+	options.lock.Lock()
+	defer options.lock.Unlock()
+	if options.parsed {
+		if strings.Join(options.args, "\x00") != strings.Join(args, "\x00") {
+			return fmt.Errorf("options already set to %q", options.args)
+		}
+		return options.err
+	}
+	options.parsed, options.args = true, args
+	options.err = func() error {
+		// 嵌入时不打印用法
+		cmdline.SetOutput(ioutil.Discard)
+		err := parseFlags(args)
+		if err != nil {
+			return err
+		}
+		if cmdline.NArg() != 0 {
+			return fmt.Errorf("unexpected arguments %q", cmdline.Args())
+		}
+		err = checkFlags()
+		if err != nil {
+			return err
+		}
+		cfg, err := loadConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		return cfg.apply()
+	}()
+	return options.err
+}
+
+// NewResolver returns a Resolver configured by args, the options given
+// before a subcommand. The options are the same for the whole process, so
+// every call must pass the same args. Relative search roots are relative to
+// dir, which is the search root when args give none.
+func NewResolver(dir string, args []string) (*Resolver, error) {
+	if err := configure(args); err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return nil, err
+	}
+	headerext, srcext := suffixes(cfg)
+	var roots rootSlice
+	for _, root := range searchroots {
+		if !filepath.IsAbs(root.Path) {
+			root.Path = filepath.Join(dir, root.Path)
+		}
+		roots = append(roots, root)
+	}
+	if len(roots) == 0 {
+		roots = rootSlice{{Path: dir}}
+	}
+	for _, root := range roots.Paths() {
+		sandboxAllow(root)
+	}
+	return &Resolver{r: newResolver(roots, headerext), srcext: srcext}, nil
+}
+
+// FlagsForFile returns the flags of path, one flag per element, memoized
+// until the file changes.
+func (r *Resolver) FlagsForFile(ctx context.Context, path string) ([]string, error) {
+	return r.r.FlagsForFile(ctx, path)
+}
+
+// Stale reports whether output, written with -meta, is stale relative to
+// the sources under srcDir, the dir of output if empty, and why.
+func (r *Resolver) Stale(output, srcDir string) (bool, string, error) {
+	if srcDir == "" {
+		srcDir = filepath.Dir(output)
+	}
+	return stale(output, srcDir, r.srcext)
+}
+
+// Close releases the header index. The Resolver cannot be used after.
+func (r *Resolver) Close() {
+	r.r.Close()
+}
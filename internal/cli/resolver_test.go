@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// nativeOptions sets the options of a resolver test: the native scanner and
+// no system dirs, so the results do not depend on the machine.
+func nativeOptions(t *testing.T) {
+	scanner, sys := *depScanner, printSystem
+	*depScanner, printSystem = "native", "false"
+	t.Cleanup(func() { *depScanner, printSystem = scanner, sys })
+}
+
+func TestResolverIndexRetry(t *testing.T) {
+	nativeOptions(t)
+	strict := *strictScan
+	*strictScan = true
+	defer func() { *strictScan = strict }()
+
+	dir := t.TempDir()
+	root := filepath.Join(dir, "include")
+	r := newResolver(rootSlice{{Path: root}}, map[string]bool{".h": true})
+	if err := r.index(); err == nil {
+		t.Fatal("no error indexing a missing root")
+	}
+	writeTree(t, dir, map[string]string{"include/x.h": ""})
+	if err := r.index(); err != nil {
+		t.Fatalf("index after the root appeared: %s", err)
+	}
+	if _, err := r.t.Search("x.h"); err != nil {
+		t.Errorf("x.h not indexed: %s", err)
+	}
+}
+
+func TestResolverSession(t *testing.T) {
+	nativeOptions(t)
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"include/x.h": "",
+		"src/a.c":     "#include <x.h>\n#include <missing_resolver_test.h>\n",
+	})
+	r := newResolver(rootSlice{{Path: filepath.Join(dir, "include")}}, map[string]bool{".h": true})
+	flags, err := r.FlagsForFile(context.Background(), filepath.Join(dir, "src", "a.c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsString(flags, "-I"+filepath.Join(dir, "include")) {
+		t.Errorf("flags = %q, want the dir of x.h", flags)
+	}
+	if _, ok := r.sess.rep.unresolved["missing_resolver_test.h"]; !ok {
+		t.Error("unresolved header missing from the report of the resolver")
+	}
+	if _, ok := rep.unresolved["missing_resolver_test.h"]; ok {
+		t.Error("unresolved header reported to the package level report")
+	}
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	retryAll   = cmdline.Bool("retry-all", false, "rescan the files that resolved headers in scan order, without putting the first includer of each header first")
+	maxRetries = cmdline.Int("max-retries", 3, "stop rescanning a file after this many rounds resolving the same headers")
+)
+
+// retrySet decides the order files are scanned again after their headers
+// were resolved. A resolved header only needs one includer rescanned to
+// discover its own includes, so that one goes ahead of the others, which
+// then find the dirs it added. A set lasts one run or one resolve: the
+// headers claimed while resolving other files say nothing about this one.
+type retrySet struct {
+	lock    sync.Mutex
+	claimed map[string]string
+	// 每个文件上一轮解析出的头文件和没有变化的轮数
+	last   map[string]string
+	stalls map[string]int
+	// 不再重新扫描的文件报告到这里
+	rep *report
+}
+
+func newRetrySet(rep *report) *retrySet {
+	return &retrySet{
+		rep:     rep,
+		claimed: make(map[string]string),
+		last:    make(map[string]string),
+		stalls:  make(map[string]int),
+	}
+}
+
+// Stuck reports whether p resolved the same headers for -max-retries rounds
+// in a row, which the compiler then still did not find: an include cycle or a
+// header that is only generated by the build. Such files are not rescanned
+// again and are reported.
+func (r *retrySet) Stuck(p string, headers []string) bool {
+	l := append([]string{}, headers...)
+	sort.Strings(l)
+	key := strings.Join(l, "\x00")
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.last[p] != key {
+		r.last[p] = key
+		r.stalls[p] = 0
+		return false
+	}
+	r.stalls[p]++
+	if r.stalls[p] < *maxRetries {
+		return false
+	}
+	r.rep.Stuck(p, l)
+	return true
+}
+
+// Claim reports whether p is the first includer of one of the newly
+// resolved headers, to be rescanned ahead of the files waiting on it.
+func (r *retrySet) Claim(p string, headers []string) bool {
+	if *retryAll {
+		return true
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	first := false
+	for _, h := range headers {
+		if by, ok := r.claimed[h]; ok && by != p {
+			continue
+		}
+		r.claimed[h] = p
+		first = true
+	}
+	return first
+}
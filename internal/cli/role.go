@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"path/filepath"
+	"regexp"
+)
+
+var testPattern = cmdline.String("test-pattern", `(^|/)(tests?|testing|unittests?|mocks?)/|(_test|_unittest|Test|Tests)\.[^/]*$|(^|/)test_[^/]*$`,
+	"regexp of the source paths that are tests, besides the sources under role=test roots")
+
+var testRe *regexp.Regexp
+
+func checkTestPattern() (err error) {
+	testRe, err = regexp.Compile(*testPattern)
+	return err
+}
+
+// isTestSource reports whether src is a test, which may resolve headers into
+// role=test roots.
+func isTestSource(t *tree, src string) bool {
+	if t != nil && t.Role(src) == "test" {
+		return true
+	}
+	return testRe != nil && testRe.MatchString(filepath.ToSlash(src))
+}
+
+// scopeDirs limits the dirs a header of src resolved to by the roles of their
+// roots: production sources never use test roots, tests prefer them.
+func scopeDirs(t *tree, src string, dirs []string) []string {
+	var test, other []string
+	for _, dir := range dirs {
+		if t.Role(dir) == "test" {
+			test = append(test, dir)
+		} else {
+			other = append(other, dir)
+		}
+	}
+	if isTestSource(t, src) && len(test) != 0 {
+		return test
+	}
+	return other
+}
+
+// testDirs returns the test root dirs the direct includes of the test src
+// resolve to. The compiler would find the same names in production dirs
+// already known, so these are looked up before scanning.
+func testDirs(t *tree, src string) []string {
+	if !isTestSource(t, src) {
+		return nil
+	}
+	incs, err := parseIncludesFile(src)
+	if err != nil {
+		return nil
+	}
+	var ret []string
+	for _, inc := range incs {
+		if inc.Macro {
+			continue
+		}
+		dirs, err := t.Search(inc.Name)
+		if err != nil {
+			continue
+		}
+		for _, dir := range dirs {
+			if t.Role(dir) == "test" {
+				ret = append(ret, dir)
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// rootSpec is a search root given with -s path[:attr...].
+type rootSpec struct {
+	Path string
+	// headers under the root are searched after the system dirs
+	After bool
+	// role=test roots only serve test sources
+	Role string
+}
+
+func parseRoot(value string) (rootSpec, error) {
+	var spec rootSpec
+	for {
+		i := strings.LastIndex(value, ":")
+		// a drive letter like C: is part of the path
+		if i <= 1 {
+			break
+		}
+		switch attr := value[i+1:]; {
+		case attr == "after":
+			spec.After = true
+		case strings.HasPrefix(attr, "role=") && len(attr) > len("role="):
+			spec.Role = attr[len("role="):]
+		default:
+			spec.Path = value
+			return spec, nil
+		}
+		value = value[:i]
+	}
+	if value == "" {
+		return spec, fmt.Errorf("empty search root")
+	}
+	spec.Path = value
+	return spec, nil
+}
+
+func (r rootSpec) String() string {
+	s := r.Path
+	if r.After {
+		s += ":after"
+	}
+	if r.Role != "" {
+		s += ":role=" + r.Role
+	}
+	return s
+}
+
+type rootSlice []rootSpec
+
+func (s *rootSlice) String() string {
+	var l []string
+	for _, r := range *s {
+		l = append(l, r.String())
+	}
+	return fmt.Sprintf("%q", l)
+}
+
+func (s *rootSlice) Set(value string) error {
+	spec, err := parseRoot(value)
+	if err != nil {
+		return err
+	}
+	*s = append(*s, spec)
+	return nil
+}
+
+func (s rootSlice) Paths() []string {
+	var ret []string
+	for _, r := range s {
+		ret = append(ret, r.Path)
+	}
+	return ret
+}
+
+// rootOf returns the spec of the innermost root containing dir.
+func (t *tree) rootOf(dir string) (rootSpec, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	var best rootSpec
+	found := false
+	for path, spec := range t.specs {
+		if dir != path && !strings.HasPrefix(dir, path+string(filepath.Separator)) {
+			continue
+		}
+		if !found || len(path) > len(best.Path) {
+			best = spec
+			best.Path = path
+			found = true
+		}
+	}
+	return best, found
+}
+
+// After reports whether dir belongs to a root searched after the system dirs.
+func (t *tree) After(dir string) bool {
+	spec, _ := t.rootOf(dir)
+	return spec.After
+}
+
+// Role returns the role of the root containing dir.
+func (t *tree) Role(dir string) string {
+	spec, _ := t.rootOf(dir)
+	return spec.Role
+}
@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+var sandboxFlag = cmdline.String("sandbox", "", "run the compiler in a sandbox: bwrap, nsjail, sandbox-exec or auto")
+
+// directories the sandboxed compiler may read besides the toolchain
+var sandboxRoots []string
+
+var toolchainDirs = map[string][]string{
+	"linux":  {"/usr", "/lib", "/lib64", "/lib32", "/bin", "/sbin", "/etc", "/opt"},
+	"darwin": {"/usr", "/bin", "/System", "/Library", "/Applications/Xcode.app", "/private", "/dev", "/opt"},
+}
+
+func compiler() string {
+	cc := os.Getenv("CC")
+	if cc == "" {
+		cc = ndkCompiler
+	}
+	if cc == "" {
+		cc = "gcc"
+	}
+	return cc
+}
+
+func sandboxAllow(dirs ...string) {
+	sandboxRoots = append(sandboxRoots, dirs...)
+}
+
+func sandboxKind() (string, error) {
+	kind := *sandboxFlag
+	if kind != "auto" {
+		return kind, nil
+	}
+	var candidates []string
+	switch runtime.GOOS {
+	case "linux":
+		candidates = []string{"bwrap", "nsjail"}
+	case "darwin":
+		candidates = []string{"sandbox-exec"}
+	}
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c); err == nil {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("no sandbox available on %s", runtime.GOOS)
+}
+
+func checkSandbox() error {
+	kind, err := sandboxKind()
+	if err != nil || kind == "" {
+		return err
+	}
+	switch kind {
+	case "bwrap", "nsjail", "sandbox-exec":
+	default:
+		return fmt.Errorf("unknown sandbox %q", kind)
+	}
+	if _, err := exec.LookPath(kind); err != nil {
+		return err
+	}
+	*sandboxFlag = kind
+	return nil
+}
+
+// readonlyDirs returns the existing directories the compiler needs to read.
+func readonlyDirs(cc string) []string {
+	dirs := append([]string{}, toolchainDirs[runtime.GOOS]...)
+	if path, err := exec.LookPath(cc); err == nil {
+		if path, err = filepath.EvalSymlinks(path); err == nil {
+			dirs = append(dirs, filepath.Dir(filepath.Dir(path)))
+		}
+	}
+	dirs = append(dirs, sandboxRoots...)
+
+	var ret []string
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		if _, err := os.Stat(dir); err == nil {
+			ret = append(ret, dir)
+		}
+	}
+	return ret
+}
+
+// runCompiler runs the compiler with args and records the invocation when a
+// fixture is being written.
+func runCompiler(args ...string) (stdout, stderr []byte, err error) {
+	return runCompilerContext(context.Background(), args...)
+}
+
+// runCompilerContext is runCompiler killing the compiler when ctx is done.
+func runCompilerContext(ctx context.Context, args ...string) (stdout, stderr []byte, err error) {
+	cmd := ccCommand(args...)
+	outbuf, errbuf := new(bytes.Buffer), new(bytes.Buffer)
+	cmd.Stdout = outbuf
+	cmd.Stderr = errbuf
+	if err = cmd.Start(); err == nil {
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				cmd.Process.Kill()
+			case <-done:
+			}
+		}()
+		err = cmd.Wait()
+		close(done)
+	}
+	if recorder != nil {
+		exit := 0
+		if err != nil {
+			exit = -1
+			if e, ok := err.(*exec.ExitError); ok {
+				exit = e.ExitCode()
+			}
+		}
+		recorder.Command(args, outbuf.Bytes(), errbuf.Bytes(), exit)
+	}
+	return outbuf.Bytes(), errbuf.Bytes(), err
+}
+
+func ccCommand(args ...string) *exec.Cmd {
+	return toolCommand(compiler(), args...)
+}
+
+// toolCommand runs cc, or another toolchain program, in the sandbox and the
+// C locale, so that its messages can be parsed.
+func toolCommand(cc string, args ...string) *exec.Cmd {
+	cmd := sandboxedCommand(cc, args...)
+	cmd.Env = cLocaleEnv()
+	return cmd
+}
+
+func sandboxedCommand(cc string, args ...string) *exec.Cmd {
+	switch *sandboxFlag {
+	case "bwrap":
+		wrap := []string{"--unshare-all", "--die-with-parent", "--dev", "/dev", "--proc", "/proc", "--tmpfs", "/tmp"}
+		for _, dir := range readonlyDirs(cc) {
+			wrap = append(wrap, "--ro-bind", dir, dir)
+		}
+		wrap = append(wrap, "--", cc)
+		return exec.Command("bwrap", append(wrap, args...)...)
+	case "nsjail":
+		if path, err := exec.LookPath(cc); err == nil {
+			cc = path
+		}
+		wrap := []string{"-Mo", "--quiet", "-B", "/dev/null", "-T", "/tmp"}
+		for _, dir := range readonlyDirs(cc) {
+			wrap = append(wrap, "-R", dir)
+		}
+		wrap = append(wrap, "--", cc)
+		return exec.Command("nsjail", append(wrap, args...)...)
+	case "sandbox-exec":
+		profile := new(strings.Builder)
+		profile.WriteString("(version 1)(allow default)(deny network*)(deny file-write*)")
+		profile.WriteString(`(allow file-write* (literal "/dev/null"))(deny file-read* (subpath "/Users"))`)
+		for _, dir := range readonlyDirs(cc) {
+			fmt.Fprintf(profile, "(allow file-read* (subpath %q))", dir)
+		}
+		wrap := []string{"-p", profile.String(), cc}
+		return exec.Command("sandbox-exec", append(wrap, args...)...)
+	}
+	return exec.Command(cc, args...)
+}
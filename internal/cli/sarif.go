@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var sarifFile = cmdline.String("sarif", "", "also write the unresolved headers, scan errors, shadowed headers and -never-include violations to this SARIF file for code review annotations")
+
+// The subset of SARIF 2.1.0 code scanning services read.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysical `json:"physicalLocation"`
+}
+
+type sarifPhysical struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+	Region           *sarifRegion  `json:"region,omitempty"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+var sarifRules = []sarifRule{
+	{"unresolved-header", sarifMessage{"Included header not found in the search roots"}},
+	{"scan-error", sarifMessage{"Path skipped while scanning"}},
+	{"shadowed-header", sarifMessage{"Header found in several include dirs"}},
+	{"never-include", sarifMessage{"Header found only in a -never-include dir"}},
+}
+
+// sarifURI returns p relative to the current dir, where review tools expect
+// paths relative to the checkout, or as a file URI outside it.
+func sarifURI(p string) string {
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, p); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(p)}
+	return u.String()
+}
+
+func sarifAt(p string, line int) sarifLocation {
+	loc := sarifLocation{sarifPhysical{ArtifactLocation: sarifArtifact{sarifURI(p)}}}
+	if line > 0 {
+		loc.PhysicalLocation.Region = &sarifRegion{line}
+	}
+	return loc
+}
+
+// WriteSARIF writes the problems of the report to name.
+func (r *report) WriteSARIF(name string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	results := []sarifResult{}
+	var headers []string
+	for h := range r.unresolved {
+		headers = append(headers, h)
+	}
+	sort.Strings(headers)
+	for _, h := range headers {
+		for _, site := range r.unresolved[h] {
+			msg := fmt.Sprintf("%s not found in the search roots", h)
+			if site.file != site.src {
+				msg += ", included while scanning " + sarifURI(site.src)
+			}
+			results = append(results, sarifResult{
+				RuleID:    "unresolved-header",
+				Level:     "error",
+				Message:   sarifMessage{msg},
+				Locations: []sarifLocation{sarifAt(site.file, site.line)},
+			})
+		}
+	}
+	headers = headers[:0]
+	for h := range r.never {
+		headers = append(headers, h)
+	}
+	sort.Strings(headers)
+	for _, h := range headers {
+		var dirs []string
+		for _, dir := range r.neverDirs[h] {
+			dirs = append(dirs, sarifURI(dir))
+		}
+		for _, site := range r.never[h] {
+			results = append(results, sarifResult{
+				RuleID:    "never-include",
+				Level:     "error",
+				Message:   sarifMessage{fmt.Sprintf("%s is only in %s, which must not be included from here", h, strings.Join(dirs, " "))},
+				Locations: []sarifLocation{sarifAt(site.file, site.line)},
+			})
+		}
+	}
+	for _, err := range r.scanErrors {
+		res := sarifResult{
+			RuleID:  "scan-error",
+			Level:   "warning",
+			Message: sarifMessage{err.Error()},
+		}
+		if pe, ok := err.(*os.PathError); ok {
+			res.Locations = []sarifLocation{sarifAt(pe.Path, 0)}
+		}
+		results = append(results, res)
+	}
+	for _, s := range r.shadowed {
+		var dirs []string
+		for _, dir := range s.dirs {
+			dirs = append(dirs, sarifURI(dir))
+		}
+		res := sarifResult{
+			RuleID:  "shadowed-header",
+			Level:   "warning",
+			Message: sarifMessage{fmt.Sprintf("%s is in %s, the first one wins", s.header, strings.Join(dirs, " "))},
+		}
+		for _, src := range s.srcs {
+			res.Locations = append(res.Locations, sarifAt(src, 0))
+		}
+		results = append(results, res)
+	}
+
+	buf, err := json.MarshalIndent(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{sarifDriver{
+				Name:           "clang_complete",
+				Version:        version,
+				InformationURI: "https://github.com/icexin/clang_complete",
+				Rules:          sarifRules,
+			}},
+			Results: results,
+		}},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, append(buf, '\n'), 0644)
+}
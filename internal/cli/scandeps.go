@@ -0,0 +1,293 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	depScanner = cmdline.String("scanner", "cc", "dependency scanner: cc runs the compiler per file, cc-batch passes it several files at once, clang-scan-deps scans each round in one batch, native follows the includes without a compiler")
+	batchSize  = cmdline.Int("batch-size", 32, "maximum number of files per compiler run with -scanner cc-batch")
+)
+
+// batchDeps holds the headers of the files scanned by the last batch until
+// searchFile takes them.
+type batchDeps struct {
+	lock sync.Mutex
+	m    map[string][]string
+	// clang-scan-deps 因缺头文件失败过的源码，之后直接逐个扫描
+	unresolved map[string]bool
+}
+
+var batch = &batchDeps{m: make(map[string][]string), unresolved: make(map[string]bool)}
+
+// scanners are the values of -scanner.
+var scanners = []string{"cc", "cc-batch", "clang-scan-deps", "native"}
+
+func checkScanner() error {
+	for _, name := range scanners {
+		if *depScanner == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown scanner %q", *depScanner)
+}
+
+func (b *batchDeps) take(file string) ([]string, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	headers, ok := b.m[file]
+	delete(b.m, file)
+	return headers, ok
+}
+
+// Scan runs clang-scan-deps once over files, and once more over the files
+// that failed other than on a missing header, as their retry language. Files
+// it fails on are left to the per file scan. Unlike the compiler with -MG,
+// clang-scan-deps fails on a missing header, so the files that did are left
+// to the per file scan right away in later rounds.
+func (b *batchDeps) Scan(files []string, acceptsuffix map[string]bool, includes []string) error {
+	var todo []string
+	b.lock.Lock()
+	for _, file := range files {
+		if !b.unresolved[file] {
+			todo = append(todo, file)
+		}
+	}
+	b.lock.Unlock()
+	if len(todo) == 0 {
+		return nil
+	}
+	failed, err := b.scanDeps(todo, "", acceptsuffix, includes)
+	if err != nil {
+		return err
+	}
+	retry := make(map[string][]string)
+	for _, file := range failed {
+		if lang := retryLang(file); lang != "" {
+			retry[lang] = append(retry[lang], file)
+		}
+	}
+	for lang, files := range retry {
+		if _, err := b.scanDeps(files, lang, acceptsuffix, includes); err != nil {
+			return err
+		}
+		b.retried(files, lang)
+	}
+	return nil
+}
+
+// scanDeps runs clang-scan-deps over files as lang, or their scanLang if
+// lang is "", and returns the files that failed other than on a missing
+// header.
+func (b *batchDeps) scanDeps(files []string, lang string, acceptsuffix map[string]bool, includes []string) ([]string, error) {
+	dir, err := ioutil.TempDir("", "clang_complete")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	sandboxAllow(dir)
+
+	cc := "clang"
+	if strings.Contains(filepath.Base(compiler()), "clang") {
+		cc = compiler()
+	}
+	var cmds []compileCommand
+	for _, file := range files {
+		l := lang
+		if l == "" {
+			l = scanLang(file)
+		}
+		args := []string{cc, "-x" + l, "-M", "-MG"}
+		if isCuda(file) && cudaHostOnly() {
+			args = append(args, cudaHostDefines...)
+		}
+		args = append(args, flagsFor(fileScanFlags(file), l)...)
+		args = append(args, includes...)
+		args = append(args, "-c", file)
+		cmds = append(cmds, compileCommand{
+			Directory: filepath.Dir(file),
+			File:      file,
+			Arguments: args,
+		})
+	}
+	buf, err := json.Marshal(cmds)
+	if err != nil {
+		return nil, err
+	}
+	cdb := filepath.Join(dir, "compile_commands.json")
+	err = ioutil.WriteFile(cdb, buf, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := toolCommand("clang-scan-deps", "-compilation-database="+cdb, "-format=make", "-j", strconv.Itoa(*ccWorkers))
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	failed, unresolved := scanDepsFailures(stderr.Bytes())
+	if len(out) == 0 && err != nil && len(failed)+len(unresolved) == 0 {
+		return nil, fmt.Errorf("clang-scan-deps: %s:%s", err, stderr)
+	}
+
+	b.parse(out, files, acceptsuffix)
+	b.lock.Lock()
+	for _, file := range unresolved {
+		b.unresolved[file] = true
+	}
+	b.lock.Unlock()
+	return failed, nil
+}
+
+// scanDepsFailures returns the files clang-scan-deps reported errors for in
+// stderr, those that missed a header apart.
+func scanDepsFailures(stderr []byte) (failed, unresolved []string) {
+	const prefix = "Error while scanning dependencies for "
+	file, missing := "", false
+	flush := func() {
+		switch {
+		case file == "":
+		case missing:
+			unresolved = append(unresolved, file)
+		default:
+			failed = append(failed, file)
+		}
+	}
+	for _, line := range strings.Split(string(stderr), "\n") {
+		if strings.HasPrefix(line, prefix) {
+			flush()
+			file, missing = strings.TrimSuffix(line[len(prefix):], ":"), false
+			continue
+		}
+		// -MG 对 clang-scan-deps 无效，缺头文件的交给逐个扫描
+		if strings.Contains(line, "file not found") {
+			missing = true
+		}
+	}
+	flush()
+	return failed, unresolved
+}
+
+// ScanCC runs the compiler over files in groups of -batch-size, saving a
+// process start per file, and over the files it failed on once more as their
+// retry language. Files both fail on are left to the per file scan.
+func (b *batchDeps) ScanCC(files []string, acceptsuffix map[string]bool, includes []string) {
+	langs := make(map[string][]string)
+	for _, file := range files {
+		// CUDA 文件的宏定义不同，汇编文件的语言不同，有目录规则的参数不同，单独处理
+		if !isCuda(file) && !isAsm(file) && len(dirRules.Flags(file)) == 0 {
+			lang := scanLang(file)
+			langs[lang] = append(langs[lang], file)
+		}
+	}
+	b.runCC(langs, acceptsuffix, includes)
+
+	retry := make(map[string][]string)
+	for _, files := range langs {
+		for _, file := range b.missing(files) {
+			lang := retryLang(file)
+			retry[lang] = append(retry[lang], file)
+		}
+	}
+	b.runCC(retry, acceptsuffix, includes)
+	for lang, files := range retry {
+		b.retried(files, lang)
+	}
+}
+
+// runCC runs the compiler over the files of each language in groups.
+func (b *batchDeps) runCC(langs map[string][]string, acceptsuffix map[string]bool, includes []string) {
+	total := 0
+	for _, files := range langs {
+		total += len(files)
+	}
+	n := (total + *ccWorkers - 1) / *ccWorkers
+	if n > *batchSize {
+		n = *batchSize
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	pool := newPool(*ccWorkers)
+	for lang, rest := range langs {
+		lang := lang
+		for len(rest) != 0 {
+			m := n
+			if m > len(rest) {
+				m = len(rest)
+			}
+			group := rest[:m]
+			rest = rest[m:]
+			pool.Run(func() {
+				flags := []string{"-x" + lang, "-M", "-MG"}
+				flags = append(flags, flagsFor(scanFlags(), lang)...)
+				flags = append(flags, includes...)
+				flags = append(flags, group...)
+				out, stderr, err := runCompiler(flags...)
+				if err != nil {
+					// 出错的文件不记录，留给重试和逐个扫描
+					group = ccSucceeded(group, stderr)
+				}
+				b.parse(out, group, acceptsuffix)
+			})
+		}
+	}
+	pool.Wait()
+}
+
+// ccSucceeded returns the files of a failed compiler run stderr does not
+// mention, directly or as the includer of a header with an error.
+func ccSucceeded(files []string, stderr []byte) []string {
+	var ret []string
+	for _, file := range files {
+		if !bytes.Contains(stderr, []byte(file+":")) {
+			ret = append(ret, file)
+		}
+	}
+	return ret
+}
+
+// missing returns the files of files no batch recorded headers for.
+func (b *batchDeps) missing(files []string) []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var ret []string
+	for _, file := range files {
+		if _, ok := b.m[file]; !ok {
+			ret = append(ret, file)
+		}
+	}
+	return ret
+}
+
+// parse records the headers of files from make rules.
+func (b *batchDeps) parse(out []byte, files []string, acceptsuffix map[string]bool) {
+	want := make(map[string]bool)
+	for _, file := range files {
+		want[file] = true
+	}
+	out = bytes.Replace(out, []byte("\\\n"), []byte{}, -1)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		i := bytes.Index(line, []byte(": "))
+		if i < 0 {
+			continue
+		}
+		deps := bytes.Fields(line[i+2:])
+		if len(deps) == 0 || !want[string(deps[0])] {
+			continue
+		}
+		file := string(deps[0])
+		b.m[file] = filterHeaders(context.Background(), file, deps[1:], acceptsuffix, *sniff)
+	}
+}
@@ -0,0 +1,237 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runServe answers the flags of files over a unix socket with a line
+// protocol shell scripts can speak with nc or socat: a request is
+// "FLAGS <path>", the response one flag group per line then an empty line,
+// or "ERROR <message>" then an empty line. "STALE <output> [<src_dir>]"
+// answers "fresh" or "stale <reason>" for an output written with -meta, the
+// source dir defaulting to the dir of the output. "ROOTS" answers one line
+// per search root: its path, when its index was built, its content hash and
+// whether it is revalidated. "TOUCH <path>..." answers "queued <n>" and
+// re-resolves the paths once the saves of a burst settle, see -debounce.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	socket := fs.String("socket", ".clang_complete.sock", "path of the unix socket to listen on")
+	interval := fs.Duration("refresh", 2*time.Second, "how often changed files are resolved again in the background, 0 turns it off")
+	recent := fs.Int("recent", 64, "number of recently requested files refreshed ahead of the others")
+	stateDir := fs.String("state", "", "dir the index and the known flags are saved to on exit and restored from on start")
+	revalidate := fs.Duration("revalidate", time.Minute, "how often the dirs of the revalidated roots are listed and the changed ones indexed again, 0 turns it off")
+	revalidateRoots := fs.String("revalidate-roots", "network", "roots to revalidate: network for the ones on NFS or SMB, or all")
+	debounce := fs.Duration("debounce", 300*time.Millisecond, "how long after the last TOUCH of a burst the touched paths are re-resolved, in one batch")
+	debounceMax := fs.Duration("debounce-max", 2*time.Second, "longest a TOUCH waits for the burst to settle, 0 means no limit")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New("usage: clang_complete " + commands["serve"].usage)
+	}
+	if *revalidateRoots != "network" && *revalidateRoots != "all" {
+		return fmt.Errorf("bad -revalidate-roots %q, want network or all", *revalidateRoots)
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	if err := cfg.apply(); err != nil {
+		return err
+	}
+	headerext, srcext := suffixes(cfg)
+	roots := searchroots
+	if len(roots) == 0 {
+		roots = rootSlice{{Path: "."}}
+	}
+	for _, root := range roots.Paths() {
+		if abs, err := filepath.Abs(root); err == nil {
+			sandboxAllow(abs)
+		}
+	}
+
+	// 上次异常退出留下的 socket 文件
+	if conn, err := net.Dial("unix", *socket); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use", *socket)
+	}
+	os.Remove(*socket)
+	l, err := net.Listen("unix", *socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(*socket)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	stopped := make(chan struct{})
+	go func() {
+		<-stop
+		close(stopped)
+		l.Close()
+	}()
+
+	s := &server{
+		r:      newResolver(roots, headerext),
+		srcext: srcext,
+		recent: newRecentFiles(*recent),
+		fresh:  new(freshness),
+	}
+	if *stateDir != "" {
+		s.r.fingerprint = true
+		if err := s.restoreState(*stateDir); err == nil {
+			fmt.Fprintf(os.Stderr, "restored state from %s\n", *stateDir)
+		} else if !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "state not restored, reindexing: %s\n", err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.touch = newDebouncer(*debounce, *debounceMax, func(paths []string) {
+		s.touched(ctx, paths)
+	})
+	// 提前建立索引，第一个请求不用等待
+	go s.revalidate(ctx, *revalidate, *revalidateRoots == "all")
+	if *interval > 0 {
+		go s.refresh(ctx, *interval)
+	}
+	fmt.Fprintf(os.Stderr, "listening on %s\n", *socket)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-stopped:
+			default:
+				return err
+			}
+			cancel()
+			if *stateDir != "" {
+				return s.saveState(*stateDir)
+			}
+			return nil
+		}
+		go s.serve(conn)
+	}
+}
+
+// serveRequests are the requests of the serve protocol, serveProtocol is
+// bumped when one changes.
+var serveRequests = []string{"FLAGS", "STALE", "ROOTS", "TOUCH"}
+
+const serveProtocol = 2
+
+type server struct {
+	r      *resolver
+	srcext map[string]bool
+	recent *recentFiles
+	fresh  *freshness
+	touch  *debouncer
+}
+
+// serve answers the requests of one connection until it is closed.
+func (s *server) serve(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	w := bufio.NewWriter(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		err := s.handle(w, line)
+		if err != nil {
+			fmt.Fprintf(w, "ERROR %s\n", strings.Replace(err.Error(), "\n", " ", -1))
+		}
+		fmt.Fprintln(w)
+		if w.Flush() != nil {
+			return
+		}
+	}
+}
+
+func (s *server) handle(w io.Writer, line string) error {
+	cmd, arg := line, ""
+	if i := strings.IndexByte(line, ' '); i >= 0 {
+		cmd, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	switch cmd {
+	case "FLAGS":
+		if arg == "" {
+			return errors.New("usage: FLAGS <path>")
+		}
+		path, err := filepath.Abs(arg)
+		if err != nil {
+			return err
+		}
+		s.recent.Touch(path)
+		flags, err := s.r.FlagsForFile(context.Background(), path)
+		if err != nil {
+			return err
+		}
+		for _, g := range flagGroups(flags) {
+			fmt.Fprintln(w, strings.Join(g, " "))
+		}
+		return nil
+	case "STALE":
+		args := strings.Fields(arg)
+		if len(args) == 0 || len(args) > 2 {
+			return errors.New("usage: STALE <output> [<src_dir>]")
+		}
+		srcroot := filepath.Dir(args[0])
+		if len(args) == 2 {
+			srcroot = args[1]
+		}
+		isStale, reason, err := stale(args[0], srcroot, s.srcext)
+		if err != nil {
+			return err
+		}
+		if isStale {
+			fmt.Fprintln(w, "stale "+reason)
+		} else {
+			fmt.Fprintln(w, "fresh")
+		}
+		return nil
+	case "TOUCH":
+		args := strings.Fields(arg)
+		if len(args) == 0 {
+			return errors.New("usage: TOUCH <path>...")
+		}
+		var paths []string
+		for _, p := range args {
+			path, err := filepath.Abs(p)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, path)
+		}
+		s.touch.Touch(paths)
+		fmt.Fprintf(w, "queued %d\n", len(paths))
+		return nil
+	case "ROOTS":
+		for _, info := range s.fresh.Roots() {
+			hash, mode := info.Hash, "local"
+			if hash == "" {
+				hash = "-"
+			}
+			if info.Network {
+				mode = "network"
+			}
+			if info.Validated {
+				mode += ",revalidated"
+			}
+			fmt.Fprintf(w, "%s %s %s %s\n", info.Path, info.Built.Format(time.RFC3339), hash, mode)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown request %q", cmd)
+}
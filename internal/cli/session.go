@@ -0,0 +1,32 @@
+package cli
+
+import "context"
+
+// session is the state gathered while resolving: the statistics, the report
+// and the system headers used. A run of the command has the package level
+// one, every resolver its own, carried by the context of its searches.
+type session struct {
+	stats   *statistics
+	rep     *report
+	sysUsed *sysUsage
+}
+
+var defaultSession = &session{stats: stats, rep: rep, sysUsed: sysUsed}
+
+func newSession() *session {
+	return &session{stats: newStatistics(), rep: newReport(), sysUsed: newSysUsage()}
+}
+
+type sessionKey struct{}
+
+func withSession(ctx context.Context, s *session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, s)
+}
+
+// sessionOf returns the session of ctx, the package level one by default.
+func sessionOf(ctx context.Context) *session {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		return s
+	}
+	return defaultSession
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var checkShadowing = cmdline.Bool("shadowing", false, "report headers found in more than one emitted include dir, with the dir that wins and the sources including them")
+
+// shadowed is a header several include dirs provide.
+type shadowed struct {
+	header string
+	// dirs in search order, the first one wins
+	dirs []string
+	srcs []string
+}
+
+// searchOrder returns the include dirs in the order the compiler searches
+// them: -I, -isystem, the system dirs, then -idirafter.
+func (p *printer) searchOrder() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// 和 FlagSet 的分类一样，但不做路径映射
+	var includes, systems, after []string
+	for _, h := range cleanDirs(p.l, p.sys) {
+		switch {
+		case underAny(h, p.vendored):
+			systems = append(systems, h)
+		case p.isTest(h):
+		case p.isAfter(h):
+			after = append(after, h)
+		default:
+			includes = append(includes, h)
+		}
+	}
+	var ret []string
+	ret = append(ret, includes...)
+	ret = append(ret, systems...)
+	ret = append(ret, p.sys...)
+	return append(ret, after...)
+}
+
+// includedBy returns the sources including each resolved header.
+func (s *statistics) includedBy() map[string][]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ret := make(map[string][]string)
+	for key := range s.seen {
+		i := strings.IndexByte(key, 0)
+		header := key[i+1:]
+		ret[header] = append(ret[header], key[:i])
+	}
+	return ret
+}
+
+// findShadowing lists the included headers more than one of dirs provides.
+func findShadowing(dirs []string) []shadowed {
+	var ret []shadowed
+	for header, srcs := range stats.includedBy() {
+		var found []string
+		for _, dir := range dirs {
+			if info, err := os.Stat(filepath.Join(dir, header)); err == nil && !info.IsDir() {
+				found = append(found, dir)
+			}
+		}
+		if len(found) < 2 {
+			continue
+		}
+		sort.Strings(srcs)
+		ret = append(ret, shadowed{header, found, srcs})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].header < ret[j].header })
+	return ret
+}
@@ -0,0 +1,282 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var sharedCache = cmdline.String("shared-cache", "", "reuse the include dirs found for sources by others, from a shared dir or an http(s) url")
+
+// cacheStore keeps results by key, see dirStore and httpStore.
+type cacheStore interface {
+	Get(key string) ([]byte, bool)
+	Put(key string, data []byte) error
+}
+
+func openStore(target string) cacheStore {
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		return &httpStore{
+			url:    strings.TrimSuffix(target, "/"),
+			client: &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+	return dirStore(target)
+}
+
+// dirStore is a cache dir, on NFS for a team.
+type dirStore string
+
+func (d dirStore) path(key string) string {
+	return filepath.Join(string(d), key[:2], key)
+}
+
+func (d dirStore) Get(key string) ([]byte, bool) {
+	buf, err := ioutil.ReadFile(d.path(key))
+	return buf, err == nil
+}
+
+func (d dirStore) Put(key string, data []byte) error {
+	p := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	// 先写临时文件再改名，其他人不会读到写了一半的结果
+	f, err := ioutil.TempFile(filepath.Dir(p), ".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+// httpStore is a cache server taking GET and PUT of url/key.
+type httpStore struct {
+	url    string
+	client *http.Client
+}
+
+func (h *httpStore) Get(key string) ([]byte, bool) {
+	resp, err := h.client.Get(h.url + "/" + key)
+	if err != nil {
+		return nil, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, false
+	}
+	buf, err := ioutil.ReadAll(resp.Body)
+	return buf, err == nil
+}
+
+func (h *httpStore) Put(key string, data []byte) error {
+	req, err := http.NewRequest("PUT", h.url+"/"+key, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("shared cache %s: %s", h.url, resp.Status)
+	}
+	return nil
+}
+
+// indexVersion hashes the headers of the index relative to their roots, so
+// checkouts at different paths share results until a header is added, moved
+// or removed.
+func (t *tree) indexVersion() string {
+	_, entries := t.indexEntries()
+	h := sha256.New()
+	for _, e := range entries {
+		// 去掉根目录本身的名字
+		rel := e.path[e.min:]
+		if i := strings.IndexByte(rel, filepath.Separator); i >= 0 {
+			rel = rel[i+1:]
+		}
+		h.Write([]byte(rel))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// sharedVersion is bumped when the keys or values of the shared cache change.
+const sharedVersion = 3
+
+// sharedKey returns the cache key of src: its include directives, the
+// compiler, the extra flags and the index version. The headers they pull in
+// are checked against the entry, see sharedEntry.
+func sharedKey(src, version string) (string, error) {
+	incs, err := parseIncludesFile(src)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\x00%s\x00%s\x00%s\x00", sharedVersion, version, toolchainKey(), strings.Join(fileScanFlags(src), " "))
+	for _, inc := range incs {
+		fmt.Fprintf(h, "%t %t %s\x00", inc.Angled, inc.Macro, inc.Name)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sharedEntry is the result stored for a source: all the include dirs it
+// needs, and the hashes of the headers it pulled in, through its headers too.
+// An entry is only used while the headers are unchanged. Paths under the
+// source dir are relative to it.
+type sharedEntry struct {
+	Dirs    []string          `json:"dirs"`
+	Headers map[string]string `json:"headers"`
+}
+
+func hashFile(p string) (string, error) {
+	buf, err := ioutil.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// sharedSources shares the include dirs of sources through a cacheStore.
+// Dirs under srcroot are kept relative to it.
+type sharedSources struct {
+	store   cacheStore
+	srcroot string
+	version string
+	misses  map[string]string
+}
+
+func newSharedSources(t *tree, srcroot string) *sharedSources {
+	return &sharedSources{
+		store:   openStore(*sharedCache),
+		srcroot: srcroot,
+		version: t.indexVersion(),
+		misses:  make(map[string]string),
+	}
+}
+
+func (s *sharedSources) abs(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(s.srcroot, p)
+}
+
+func (s *sharedSources) rel(p string) string {
+	if rel, err := filepath.Rel(s.srcroot, p); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return p
+}
+
+// Get returns the include dirs stored for src by an earlier run, if the
+// headers it pulled in are still the same.
+func (s *sharedSources) Get(src string) ([]string, bool) {
+	key, err := sharedKey(src, s.version)
+	if err != nil {
+		return nil, false
+	}
+	s.misses[src] = key
+	buf, ok := s.store.Get(key)
+	var e sharedEntry
+	if !ok || json.Unmarshal(buf, &e) != nil {
+		return nil, false
+	}
+	for h, sum := range e.Headers {
+		if cur, err := hashFile(s.abs(h)); err != nil || cur != sum {
+			log.Debug("shared cache: %s changed, rescan %s", h, src)
+			return nil, false
+		}
+	}
+	for i, dir := range e.Dirs {
+		e.Dirs[i] = s.abs(dir)
+	}
+	delete(s.misses, src)
+	return e.Dirs, true
+}
+
+// Put stores the results of the scanned sources missing from the cache:
+// the dirs, of the include dirs emitted, that the headers of their last scan
+// were found in, besides the ones they resolved themselves. Sources scanned
+// elsewhere, by workers, have no list of headers and are not stored.
+func (s *sharedSources) Put(scanned map[string]bool, dirs []string) error {
+	stats.lock.Lock()
+	attributed := make(map[string][]string)
+	for dir, srcs := range stats.dirSrcs {
+		for src := range srcs {
+			if _, ok := s.misses[src]; ok {
+				attributed[src] = append(attributed[src], dir)
+			}
+		}
+	}
+	deps := make(map[string][]string)
+	for src := range s.misses {
+		if l, ok := stats.deps[src]; ok {
+			deps[src] = l
+		}
+	}
+	stats.lock.Unlock()
+
+	for src, key := range s.misses {
+		l, ok := deps[src]
+		if !scanned[src] || !ok {
+			continue
+		}
+		e := sharedEntry{Headers: make(map[string]string)}
+		need := attributed[src]
+		for _, dep := range l {
+			p, err := filepath.Abs(dep)
+			if err != nil {
+				continue
+			}
+			// 没有找到的头文件只有名字，索引版本已经包含了它们
+			sum, err := hashFile(p)
+			if err != nil {
+				continue
+			}
+			e.Headers[s.rel(p)] = sum
+			// 间接包含的头文件可能在其他源码解析出的目录里，
+			// 不知道按哪个名字找到的，包含它的目录都要
+			for _, dir := range dirs {
+				if underAny(p, []string{dir}) {
+					need = append(need, dir)
+				}
+			}
+		}
+		for _, dir := range dedup(need) {
+			e.Dirs = append(e.Dirs, s.rel(dir))
+		}
+		sort.Strings(e.Dirs)
+		if e.Dirs == nil {
+			e.Dirs = []string{}
+		}
+		buf, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		err = s.store.Put(key, buf)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stateVersion is bumped when the layout of daemonState changes.
+const stateVersion = 2
+
+// daemonState is what serve keeps across restarts in the -state dir, next
+// to the header index.
+type daemonState struct {
+	Version int
+	// fingerprint of the settings and search roots, see stateKey
+	Key string
+	// listing hashes of the roots the index was built from
+	Listings map[string]string
+	Cache    []cachedFlags
+	Recent   []string
+}
+
+type cachedFlags struct {
+	Path  string
+	Mtime time.Time
+	Flags []string
+}
+
+// stateKey fingerprints the program version, the compiler, the extra flags
+// and the search roots. Their contents are compared by listing hash.
+func stateKey(roots rootSlice) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%s\x00", stateVersion, version, toolchainKey(), strings.Join(scanFlags(), " "))
+	for _, root := range roots {
+		fmt.Fprintf(h, "%s\x00", root)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// restoreState loads the index, the memoized flags and the recent files
+// saved in dir, unless the settings or the search roots changed since.
+func (s *server) restoreState(dir string) error {
+	buf, err := ioutil.ReadFile(filepath.Join(dir, "state.json"))
+	if err != nil {
+		return err
+	}
+	var state daemonState
+	if err := json.Unmarshal(buf, &state); err != nil {
+		return err
+	}
+	if state.Version != stateVersion {
+		return fmt.Errorf("saved by state version %d", state.Version)
+	}
+	if state.Key != stateKey(s.r.roots) {
+		return errors.New("search roots or settings changed")
+	}
+	// 和建立索引时的目录列表比较，任何一层加入或删除头文件都会发现
+	for _, root := range s.r.roots.Paths() {
+		p, err := filepath.Abs(root)
+		if err != nil {
+			return err
+		}
+		hash := state.Listings[p]
+		if hash == "" || hash != listingHash(fingerprint(p, nil)) {
+			return fmt.Errorf("%s changed since it was indexed", root)
+		}
+	}
+	index := filepath.Join(dir, "index")
+	if _, err := os.Stat(index); err != nil {
+		return err
+	}
+	s.r.indexFile = index
+	for root, hash := range state.Listings {
+		s.r.SetListing(root, hash)
+	}
+	s.r.lock.Lock()
+	for _, c := range state.Cache {
+		s.r.cache[c.Path] = resolved{c.Mtime, c.Flags}
+	}
+	s.r.lock.Unlock()
+	// 最近的文件最后加入，排在最前面
+	for i := len(state.Recent) - 1; i >= 0; i-- {
+		s.recent.Touch(state.Recent[i])
+	}
+	return nil
+}
+
+// saveState writes the index, the memoized flags and the recent files to
+// dir for the next start.
+func (s *server) saveState(dir string) error {
+	if err := s.r.index(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// 先写临时文件再改名，正在使用的旧索引不受影响
+	tmp := filepath.Join(dir, ".index.tmp")
+	if err := s.r.t.Save(tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, "index")); err != nil {
+		return err
+	}
+
+	state := daemonState{
+		Version:  stateVersion,
+		Key:      stateKey(s.r.roots),
+		Listings: s.r.Listings(),
+		Recent:   s.recent.List(),
+	}
+	s.r.lock.Lock()
+	for path, c := range s.r.cache {
+		state.Cache = append(state.Cache, cachedFlags{path, c.mtime, c.flags})
+	}
+	s.r.lock.Unlock()
+	sort.Slice(state.Cache, func(i, j int) bool { return state.Cache[i].Path < state.Cache[j].Path })
+	buf, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "state.json"), buf, 0644)
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+var maxFileSize = cmdline.Int64("max-file-size", 0, "skip sources larger than this many MB, like generated amalgamations, 0 for no limit")
+
+// binarySniff is how much of a source is read to tell whether it is binary.
+const binarySniff = 8 << 10
+
+// openRegular opens p for reading unless it is a FIFO, socket or device,
+// whose reads may block forever.
+func openRegular(p string) (*os.File, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s: not a regular file (%s)", p, specialKind(info.Mode()))
+	}
+	return os.Open(p)
+}
+
+// specialKind names the type of a file that is neither regular nor a dir.
+func specialKind(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "char device"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	case mode&os.ModeSymlink != 0:
+		return "dangling link"
+	}
+	return "special file"
+}
+
+// skipSource returns why the regular file p is not worth scanning, or "".
+func skipSource(p string, size int64) string {
+	if *maxFileSize > 0 && size > *maxFileSize<<20 {
+		return fmt.Sprintf("%.1f MB, over -max-file-size", float64(size)/(1<<20))
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	buf := make([]byte, binarySniff)
+	n, _ := io.ReadFull(f, buf)
+	// UTF-16 的源码也有 NUL，先转换再判断
+	if bytes.IndexByte(decodeSource(buf[:n]), 0) != -1 {
+		return "binary"
+	}
+	return ""
+}
+
+// SkipFile records a file matching the source suffixes that was not scanned.
+func (r *report) SkipFile(p, reason string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.skipped = append(r.skipped, mapPath(p)+" ("+reason+")")
+}
@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+var (
+	printStats = cmdline.Bool("stats", false, "print header resolution statistics")
+	statsTop   = cmdline.Int("stats-top", 10, "number of entries in each statistics section")
+)
+
+type statistics struct {
+	lock    sync.Mutex
+	seen    map[string]bool
+	headers map[string]int
+	dirs    map[string]int
+	dirHdrs map[string]map[string]bool
+	dirSrcs map[string]map[string]bool
+	// 每个源码最近一次扫描包含的头文件数和最大包含深度
+	counts map[string]int
+	depths map[string]int
+	// 每个源码解析到的头文件和所在目录
+	srcHdrs map[string]map[string][]string
+	// 使用共享缓存时每个源码最近一次扫描的全部依赖
+	deps map[string][]string
+	// 排在头文件第一个包含者之后重新搜索的次数
+	deferred int
+}
+
+var stats = newStatistics()
+
+func newStatistics() *statistics {
+	return &statistics{
+		seen:    make(map[string]bool),
+		headers: make(map[string]int),
+		dirs:    make(map[string]int),
+		dirHdrs: make(map[string]map[string]bool),
+		dirSrcs: make(map[string]map[string]bool),
+		counts:  make(map[string]int),
+		depths:  make(map[string]int),
+		srcHdrs: make(map[string]map[string][]string),
+		deps:    make(map[string][]string),
+	}
+}
+
+// Defer records a rescan queued behind the first includer of its headers.
+func (s *statistics) Defer() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.deferred++
+}
+
+// Count records the number of headers the last scan of src pulled in.
+func (s *statistics) Count(src string, n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.counts[src] = n
+}
+
+// Deps records the files the last scan of src pulled in, found or not.
+func (s *statistics) Deps(src string, deps []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.deps[src] = deps
+}
+
+// Depth records the deepest include chain of src.
+func (s *statistics) Depth(src string, depth int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.depths[src] = depth
+}
+
+// includeDepth returns the deepest level of the -H output of the compiler.
+func includeDepth(out []byte) int {
+	max := 0
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		n := 0
+		for n < len(line) && line[n] == '.' {
+			n++
+		}
+		if n > 0 && n < len(line) && line[n] == ' ' && n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+func (s *statistics) Resolved(src, header string, dirs []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// 同一个文件会在多轮搜索中重复处理
+	key := src + "\x00" + header
+	if s.seen[key] {
+		return
+	}
+	s.seen[key] = true
+	s.headers[header]++
+	if s.srcHdrs[src] == nil {
+		s.srcHdrs[src] = make(map[string][]string)
+	}
+	s.srcHdrs[src][header] = dirs
+	for _, dir := range dirs {
+		s.dirs[dir]++
+		m := s.dirHdrs[dir]
+		if m == nil {
+			m = make(map[string]bool)
+			s.dirHdrs[dir] = m
+		}
+		m[header] = true
+		m = s.dirSrcs[dir]
+		if m == nil {
+			m = make(map[string]bool)
+			s.dirSrcs[dir] = m
+		}
+		m[src] = true
+	}
+}
+
+type counter struct {
+	name  string
+	count int
+}
+
+func topN(m map[string]int, n int) []counter {
+	var l []counter
+	for name, count := range m {
+		l = append(l, counter{name, count})
+	}
+	sort.Slice(l, func(i, j int) bool {
+		if l[i].count != l[j].count {
+			return l[i].count > l[j].count
+		}
+		return l[i].name < l[j].name
+	})
+	if n > 0 && len(l) > n {
+		l = l[:n]
+	}
+	return l
+}
+
+func (s *statistics) Print(w io.Writer) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	fmt.Fprintf(w, "most included headers:\n")
+	for _, c := range topN(s.headers, *statsTop) {
+		fmt.Fprintf(w, "  %6d %s\n", c.count, c.name)
+	}
+	fmt.Fprintf(w, "largest contributor dirs:\n")
+	for _, c := range topN(s.dirs, *statsTop) {
+		fmt.Fprintf(w, "  %6d %s\n", c.count, mapPath(c.name))
+	}
+	counts := make(map[string]int)
+	for dir, m := range s.dirHdrs {
+		counts[dir] = len(m)
+	}
+	fmt.Fprintf(w, "distinct headers per dir:\n")
+	for _, c := range topN(counts, 0) {
+		fmt.Fprintf(w, "  %6d %s\n", c.count, mapPath(c.name))
+	}
+	fmt.Fprintf(w, "heaviest includes:\n")
+	for _, c := range topN(s.counts, *statsTop) {
+		fmt.Fprintf(w, "  %6d %s (depth %d)\n", c.count, mapPath(c.name), s.depths[c.name])
+	}
+	fmt.Fprintf(w, "rescans queued behind the first includer: %d\n", s.deferred)
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runStdin prints the flags of an unsaved buffer read from stdin, which an
+// editor names with -filename.
+func runStdin(args []string) error {
+	fs := flag.NewFlagSet("stdin", flag.ExitOnError)
+	filename := fs.String("filename", "", "path of the buffer, used to resolve its relative includes")
+	outFormat := fs.String("format", "", "print the flags in this output format instead of one per line")
+	fs.Parse(args)
+	if *filename == "" || fs.NArg() != 0 {
+		return errors.New("usage: clang_complete " + commands["stdin"].usage)
+	}
+	path, err := filepath.Abs(*filename)
+	if err != nil {
+		return err
+	}
+	var f *format
+	if *outFormat != "" {
+		f, err = lookupFormat(*outFormat)
+		if err != nil {
+			return err
+		}
+	}
+	buf, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	if err := cfg.apply(); err != nil {
+		return err
+	}
+	headerext, _ := suffixes(cfg)
+	for _, root := range searchroots.Paths() {
+		if abs, err := filepath.Abs(root); err == nil {
+			sandboxAllow(abs)
+		}
+	}
+
+	// 缓冲区写到原文件旁边的临时文件，相对路径的头文件才能找到
+	dir := filepath.Dir(path)
+	var quote []string
+	tmp, err := ioutil.TempFile(dir, ".clang_complete-*"+filepath.Ext(path))
+	if err != nil {
+		tmp, err = ioutil.TempFile("", "clang_complete-*"+filepath.Ext(path))
+		if err != nil {
+			return err
+		}
+		quote = []string{"-iquote", dir}
+		ccflags = append(ccflags, quote...)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(buf)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	sandboxAllow(dir, filepath.Dir(tmp.Name()))
+
+	r := newResolver(searchroots, headerext)
+	set, err := r.resolve(context.Background(), []string{tmp.Name()})
+	if err != nil {
+		return err
+	}
+	set.Files = []string{path}
+	if len(quote) != 0 {
+		set.Flags = removeFlags(set.Flags, quote)
+	}
+	if f != nil {
+		return f.write(os.Stdout, set)
+	}
+	for _, g := range flagGroups(set.ArgsFor(path)) {
+		fmt.Println(strings.Join(g, " "))
+	}
+	return nil
+}
+
+// removeFlags returns flags without the group g.
+func removeFlags(flags, g []string) []string {
+	var ret []string
+	for _, fg := range flagGroups(flags) {
+		if strings.Join(fg, " ") != strings.Join(g, " ") {
+			ret = append(ret, fg...)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var gccVersionRe = regexp.MustCompile(`^\d+(\.\d+)*$`)
+
+// gccVersion splits a versioned GCC dir like /usr/include/c++/12/backward or
+// /usr/lib/gcc/x86_64-linux-gnu/12/include into a key with the version
+// replaced by "*" and the version. It returns "" for other dirs.
+func gccVersion(dir string) (key, version string) {
+	parts := strings.Split(filepath.ToSlash(dir), "/")
+	for i, part := range parts {
+		if !gccVersionRe.MatchString(part) {
+			continue
+		}
+		for j := i - 1; j >= 0 && j >= i-2; j-- {
+			switch parts[j] {
+			case "c++", "gcc", "gcc-cross":
+				parts[i] = "*"
+				return strings.Join(parts, "/"), part
+			}
+		}
+	}
+	return "", ""
+}
+
+// newerVersion reports whether version a is greater than b.
+func newerVersion(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, _ := strconv.Atoi(as[i])
+		y, _ := strconv.Atoi(bs[i])
+		if x != y {
+			return x > y
+		}
+	}
+	return len(as) > len(bs)
+}
+
+// cleanDirs drops the dirs that are symlinks or bind mounts of an earlier
+// one, and of the dirs of several GCC versions keeps only one: the version
+// of the compiler, given by its dirs sys, or else the newest.
+func cleanDirs(dirs, sys []string) []string {
+	prefer := make(map[string]string)
+	for _, dir := range sys {
+		if key, version := gccVersion(dir); key != "" {
+			prefer[key] = version
+		}
+	}
+	best := make(map[string]string)
+	for _, dir := range dirs {
+		key, version := gccVersion(dir)
+		if key == "" {
+			continue
+		}
+		if v, ok := prefer[key]; ok {
+			best[key] = v
+			continue
+		}
+		if v, ok := best[key]; !ok || newerVersion(version, v) {
+			best[key] = version
+		}
+	}
+
+	var ret []string
+	var same sameDirs
+	for _, dir := range dirs {
+		if key, version := gccVersion(dir); key != "" && best[key] != version {
+			continue
+		}
+		// 重复的目录优先保留真实路径
+		if i := same.Index(dir); i >= 0 {
+			if isRealDir(dir) {
+				ret[i] = dir
+			}
+			continue
+		}
+		ret = append(ret, dir)
+	}
+	return ret
+}
+
+// sysrootFlags returns the groups of flags that change the system dirs of the
+// compiler.
+func sysrootFlags(flags []string) []string {
+	var ret []string
+	for _, g := range flagGroups(flags) {
+		switch {
+		case g[0] == "-isysroot" || g[0] == "-target" || g[0] == "-nostdinc" || g[0] == "-nostdinc++",
+			strings.HasPrefix(g[0], "--sysroot="), strings.HasPrefix(g[0], "--target="), strings.HasPrefix(g[0], "-stdlib="):
+			ret = append(ret, g...)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// sysFlag is -sys: true emits all system dirs, used only those that provided
+// a header, false none.
+type sysFlag string
+
+var printSystem = sysFlag("true")
+
+func (s *sysFlag) String() string {
+	return string(*s)
+}
+
+func (s *sysFlag) Set(value string) error {
+	if value == "used" {
+		*s = "used"
+		return nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("want true, false or used")
+	}
+	*s = sysFlag(strconv.FormatBool(b))
+	return nil
+}
+
+func (s *sysFlag) IsBoolFlag() bool {
+	return true
+}
+
+// checkSysFlag refuses "-sys used": as a bool flag -sys takes no separate
+// value, so used would be taken for the src_dir.
+func checkSysFlag() error {
+	i := len(cmdArgs) - cmdline.NArg() - 1
+	if i < 0 || cmdArgs[i] != "-sys" && cmdArgs[i] != "--sys" {
+		return nil
+	}
+	switch value := cmdline.Arg(0); value {
+	case "used", "true", "false":
+		return fmt.Errorf("-sys %s: write -sys=%s", value, value)
+	}
+	return nil
+}
+
+// sysUsage collects the absolute headers the compiler found by itself.
+type sysUsage struct {
+	lock    sync.Mutex
+	headers map[string]bool
+}
+
+var sysUsed = newSysUsage()
+
+func newSysUsage() *sysUsage {
+	return &sysUsage{headers: make(map[string]bool)}
+}
+
+func (u *sysUsage) Seen(header string) {
+	if printSystem != "used" {
+		return
+	}
+	u.lock.Lock()
+	u.headers[header] = true
+	u.lock.Unlock()
+}
+
+// Dirs returns the dirs of sys that provided a seen header. A header belongs
+// to the innermost dir containing it, like /usr/include/c++/12 over
+// /usr/include.
+func (u *sysUsage) Dirs(sys []string) []string {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	used := make(map[string]bool)
+	for h := range u.headers {
+		best := ""
+		for _, dir := range sys {
+			dir = filepath.Clean(dir)
+			if underAny(h, []string{dir}) && len(dir) > len(best) {
+				best = dir
+			}
+		}
+		if best != "" {
+			used[best] = true
+		}
+	}
+	var ret []string
+	for _, dir := range sys {
+		if used[filepath.Clean(dir)] {
+			ret = append(ret, dir)
+		}
+	}
+	return ret
+}
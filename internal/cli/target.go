@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+var emitTarget = cmdline.Bool("emit-target", false, "emit --target= with the target triple of the scanning compiler, so clangd parses the sources with the ABI and predefines of the build")
+
+var target struct {
+	sync.Once
+	triple string
+}
+
+// compilerTarget returns the triple the compiler builds for with the extra
+// flags, empty when it is unknown.
+func compilerTarget() string {
+	target.Do(func() {
+		if *depScanner == "native" {
+			return
+		}
+		flags := scanFlags()
+		// clang 给出的三元组已经考虑了 -m32 等参数，gcc 只有默认的
+		out, _, err := runCompiler(append(append([]string{}, flags...), "-print-effective-triple")...)
+		if err == nil {
+			target.triple = string(bytes.TrimSpace(out))
+			return
+		}
+		out, _, err = runCompiler(append([]string{"-dumpmachine"}, flags...)...)
+		if err != nil {
+			return
+		}
+		target.triple = normalizeTriple(string(bytes.TrimSpace(out)), flags)
+	})
+	return target.triple
+}
+
+// The arch of a triple with -m32 and -m64, which gcc -dumpmachine ignores.
+var (
+	arch32 = map[string]string{
+		"x86_64": "i686", "powerpc64": "powerpc", "powerpc64le": "powerpcle",
+		"sparc64": "sparc", "mips64": "mips", "mips64el": "mipsel", "s390x": "s390",
+	}
+	arch64 = map[string]string{
+		"i386": "x86_64", "i486": "x86_64", "i586": "x86_64", "i686": "x86_64",
+		"powerpc": "powerpc64", "powerpcle": "powerpc64le", "sparc": "sparc64",
+		"mips": "mips64", "mipsel": "mips64el", "s390": "s390x",
+	}
+)
+
+// normalizeTriple applies the last -m32, -m64 or -mx32 of flags to the
+// default triple of gcc.
+func normalizeTriple(triple string, flags []string) string {
+	mode := ""
+	for _, f := range flags {
+		if f == "-m32" || f == "-m64" || f == "-mx32" {
+			mode = f
+		}
+	}
+	parts := strings.SplitN(triple, "-", 2)
+	if len(parts) != 2 {
+		return triple
+	}
+	arch, rest := parts[0], parts[1]
+	switch mode {
+	case "-m32":
+		if a, ok := arch32[arch]; ok {
+			arch = a
+		}
+	case "-m64":
+		if a, ok := arch64[arch]; ok {
+			arch = a
+		}
+	case "-mx32":
+		if a, ok := arch64[arch]; ok {
+			arch = a
+		}
+		if arch == "x86_64" && strings.HasSuffix(rest, "-gnu") {
+			rest += "x32"
+		}
+	}
+	return arch + "-" + rest
+}
+
+// targetFlags returns --target= with the triple of the compiler, unless the
+// extra flags set the target or the outputs are for gcc, which has no such
+// flag.
+func targetFlags() []string {
+	if !*emitTarget || *targetCompiler == "gcc" {
+		return nil
+	}
+	for _, g := range flagGroups(ccflags) {
+		if g[0] == "-target" || strings.HasPrefix(g[0], "--target=") {
+			return nil
+		}
+	}
+	triple := compilerTarget()
+	if triple == "" {
+		return nil
+	}
+	return []string{"--target=" + triple}
+}
+
+// targetForm rewrites -target triple into the --target=triple clangd and
+// clang-cl both take.
+func targetForm(flags []string) []string {
+	var ret []string
+	for _, g := range flagGroups(flags) {
+		if len(g) == 2 && g[0] == "-target" {
+			g = []string{"--target=" + g[1]}
+		}
+		ret = append(ret, g...)
+	}
+	return ret
+}
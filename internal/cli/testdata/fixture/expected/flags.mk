@@ -0,0 +1,2 @@
+CPPFLAGS += -I${ROOT}/tree/roots/0/include
+CPPFLAGS += -I${ROOT}/tree/src/lib/include
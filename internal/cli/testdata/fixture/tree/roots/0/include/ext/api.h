@@ -0,0 +1 @@
+int api(void);
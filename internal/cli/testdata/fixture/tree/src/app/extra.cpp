@@ -0,0 +1,3 @@
+#include <ext/api.h>
+#include "local.hpp"
+int f() { return api() + g(); }
@@ -0,0 +1 @@
+inline int g() { return 1; }
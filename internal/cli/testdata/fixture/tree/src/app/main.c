@@ -0,0 +1,3 @@
+#include "lib/util.h"
+#include <ext/api.h>
+int main(void) { return util() + api(); }
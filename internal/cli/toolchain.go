@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var toolchain struct {
+	sync.Once
+	key string
+}
+
+// toolchainKey fingerprints the compiler by path, version and target triple,
+// so results cached with another toolchain are not reused after an upgrade
+// or a switch of CC.
+func toolchainKey() string {
+	toolchain.Do(func() {
+		if *depScanner == "native" {
+			toolchain.key = "native"
+			return
+		}
+		cc := compiler()
+		if p, err := exec.LookPath(cc); err == nil {
+			if abs, err := filepath.Abs(p); err == nil {
+				cc = abs
+			}
+			// 升级后 /usr/bin/gcc 通常指向新版本
+			if real, err := filepath.EvalSymlinks(cc); err == nil {
+				cc = real
+			}
+		}
+		out, _, _ := runCompiler("--version")
+		if i := bytes.IndexByte(out, '\n'); i >= 0 {
+			out = out[:i]
+		}
+		triple, _, _ := runCompiler(append([]string{"-dumpmachine"}, scanFlags()...)...)
+		toolchain.key = strings.Join([]string{cc, string(bytes.TrimSpace(out)), string(bytes.TrimSpace(triple))}, "\x00")
+	})
+	return toolchain.key
+}
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// debouncer coalesces the paths touched in a burst, like an editor saving
+// several files, into one batch run once no path was touched for window,
+// or max after the first touch of the batch. One batch runs at a time; the
+// paths touched meanwhile go to the next one.
+type debouncer struct {
+	lock   sync.Mutex
+	window time.Duration
+	max    time.Duration
+	run    func(paths []string)
+
+	paths   map[string]bool
+	first   time.Time
+	timer   *time.Timer
+	running bool
+	again   bool
+}
+
+func newDebouncer(window, max time.Duration, run func(paths []string)) *debouncer {
+	return &debouncer{
+		window: window,
+		max:    max,
+		run:    run,
+		paths:  make(map[string]bool),
+	}
+}
+
+// Touch adds paths to the next batch and pushes it back by the window.
+func (d *debouncer) Touch(paths []string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, p := range paths {
+		d.paths[p] = true
+	}
+	now := time.Now()
+	if d.first.IsZero() {
+		d.first = now
+	}
+	delay := d.window
+	// 持续不断的保存也不能无限推迟
+	if d.max > 0 && now.Sub(d.first)+delay > d.max {
+		delay = d.max - now.Sub(d.first)
+	}
+	if d.timer == nil {
+		d.timer = time.AfterFunc(delay, d.fire)
+		return
+	}
+	d.timer.Reset(delay)
+}
+
+func (d *debouncer) fire() {
+	d.lock.Lock()
+	d.timer = nil
+	if d.running {
+		d.again = true
+		d.lock.Unlock()
+		return
+	}
+	var paths []string
+	for p := range d.paths {
+		paths = append(paths, p)
+	}
+	d.paths = make(map[string]bool)
+	d.first = time.Time{}
+	d.running = len(paths) != 0
+	d.lock.Unlock()
+	if len(paths) == 0 {
+		return
+	}
+
+	sort.Strings(paths)
+	d.run(paths)
+	d.lock.Lock()
+	d.running = false
+	again := d.again
+	d.again = false
+	d.lock.Unlock()
+	if again {
+		d.fire()
+	}
+}
+
+// touched re-resolves a batch of touched paths: the sources among them, and
+// for the headers the dirs they are in are indexed again and the recent
+// files re-resolved, as they may include them.
+func (s *server) touched(ctx context.Context, paths []string) {
+	start := time.Now()
+	var srcs []string
+	dirs := make(map[string]bool)
+	for _, p := range paths {
+		if s.srcext[filepath.Ext(p)] {
+			srcs = append(srcs, p)
+			continue
+		}
+		dirs[filepath.Dir(p)] = true
+	}
+	if len(dirs) != 0 {
+		if err := s.r.index(); err != nil {
+			fmt.Fprintf(os.Stderr, "touch: %s\n", err)
+			return
+		}
+		for dir := range dirs {
+			root, ok := s.r.t.ownerOf(dir)
+			if !ok {
+				continue
+			}
+			// 只重新索引了一部分，保存的状态不能再用于这个根目录
+			s.r.SetListing(root, "")
+			log.Debug("reindex %s", dir)
+			if err := s.r.t.Rescan(dir, s.r.headerext); err != nil {
+				fmt.Fprintf(os.Stderr, "reindex %s: %s\n", dir, err)
+			}
+		}
+		srcs = append(srcs, s.recent.List()...)
+	}
+	srcs = dedup(srcs)
+	s.r.Forget(srcs)
+	for _, p := range srcs {
+		if ctx.Err() != nil {
+			return
+		}
+		s.refreshFile(ctx, p)
+	}
+	fmt.Fprintf(os.Stderr, "touched %d files, re-resolved %d sources in %s\n", len(paths), len(srcs), time.Since(start).Round(time.Millisecond))
+}
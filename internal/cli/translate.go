@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var targetCompiler = cmdline.String("target-compiler", "clang", "compiler the outputs are for: clang drops or rewrites the gcc only flags, gcc keeps them, clang-cl also passes system dirs with -imsvc")
+
+// gccOnly are gcc flags clang rejects or warns about, by prefix.
+var gccOnly = []string{
+	"-fno-var-tracking", "-fvar-tracking", "-fconserve-stack", "-fipa-", "-fno-ipa-",
+	"-ftree-", "-fno-tree-", "-fsched-", "-fschedule-insns", "-fno-schedule-insns",
+	"-fno-partial-inlining", "-fno-allow-store-data-races", "-fplugin=", "-fplugin-arg-",
+	"-mindirect-branch", "-mrecord-mcount", "-mno-fp-ret-in-387",
+	"-Wmaybe-uninitialized", "-Wno-maybe-uninitialized", "-Wlogical-op",
+	"-Wno-logical-op", "-Wstringop-", "-Wno-stringop-", "-Wformat-truncation",
+	"-Wno-format-truncation", "-Wformat-overflow", "-Wno-format-overflow",
+	"-Wno-class-memaccess", "-Wclass-memaccess", "-Wno-psabi",
+}
+
+// gccRewrites turn gcc flags, by prefix, into their clang equivalent.
+var gccRewrites = map[string]func(arg string) string{
+	"-fmax-errors=": func(arg string) string { return "-ferror-limit=" + arg },
+	"-mpreferred-stack-boundary=": func(arg string) string {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return ""
+		}
+		return "-mstack-alignment=" + strconv.Itoa(1<<uint(n))
+	},
+}
+
+func checkTargetCompiler() error {
+	switch *targetCompiler {
+	case "clang", "gcc", "clang-cl":
+		return nil
+	}
+	return fmt.Errorf("unknown target compiler %q", *targetCompiler)
+}
+
+// clangFlags drops the gcc only flags and rewrites the ones clang spells
+// differently.
+func clangFlags(flags []string) []string {
+	var ret []string
+	for _, g := range flagGroups(flags) {
+		if len(g) == 1 && isGccOnly(g[0]) {
+			continue
+		}
+		if len(g) == 1 {
+			g = []string{rewriteGcc(g[0])}
+			if g[0] == "" {
+				continue
+			}
+		}
+		ret = append(ret, g...)
+	}
+	return ret
+}
+
+func isGccOnly(flag string) bool {
+	for _, p := range gccOnly {
+		if strings.HasPrefix(flag, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func rewriteGcc(flag string) string {
+	for p, rewrite := range gccRewrites {
+		if strings.HasPrefix(flag, p) {
+			return rewrite(flag[len(p):])
+		}
+	}
+	return flag
+}
+
+// translate adapts the flags of fs to -target-compiler.
+func (fs *flagSet) translate() {
+	if *targetCompiler == "gcc" {
+		return
+	}
+	fs.Flags = dedupFlags(targetForm(clangFlags(fs.Flags)))
+	for lang, flags := range fs.Lang {
+		fs.Lang[lang] = targetForm(clangFlags(flags))
+	}
+}
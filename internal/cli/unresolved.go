@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// missingRe matches the diagnostics of clang and gcc for a missing header.
+var missingRe = regexp.MustCompile(`(?m)((?:[A-Za-z]:)?[^:\n]+):(\d+):(?:\d+:)? fatal error: (?:'([^']+)' file not found|([^:]+): No such file or directory)`)
+
+// includeSite is where a header is included.
+type includeSite struct {
+	file string
+	line int
+	// the source being scanned
+	src string
+}
+
+func (s includeSite) String() string {
+	site := mapPath(s.file)
+	if s.line > 0 {
+		site += ":" + strconv.Itoa(s.line)
+	}
+	if s.file != s.src {
+		site += " from " + mapPath(s.src)
+	}
+	return site
+}
+
+// missingHeaders returns the headers the diagnostics in out report missing,
+// with where they are included.
+func missingHeaders(src string, out string) map[string]includeSite {
+	ret := make(map[string]includeSite)
+	for _, m := range missingRe.FindAllStringSubmatch(out, -1) {
+		header := m[3]
+		if header == "" {
+			header = m[4]
+		}
+		line, _ := strconv.Atoi(m[2])
+		file := m[1]
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(src), file)
+		}
+		ret[header] = includeSite{filepath.Clean(file), line, src}
+	}
+	return ret
+}
+
+// findIncludeSite follows the includes of src through the dirs of includes,
+// flags as passed to the compiler, to the directive including header.
+func findIncludeSite(src, header string, includes []string) includeSite {
+	dirs := searchDirs(includes)
+	seen := map[string]bool{src: true}
+	queue := []string{src}
+	for len(queue) != 0 && len(seen) < findSiteBudget {
+		file := queue[0]
+		queue = queue[1:]
+		incs, err := parseIncludesFile(file)
+		if err != nil {
+			continue
+		}
+		for _, inc := range incs {
+			if inc.Name == header {
+				return includeSite{file, inc.Line, src}
+			}
+			lookup := dirs
+			if !inc.Angled {
+				lookup = append([]string{filepath.Dir(file)}, dirs...)
+			}
+			for _, dir := range lookup {
+				p := filepath.Join(dir, inc.Name)
+				if seen[p] {
+					break
+				}
+				if _, err := os.Stat(p); err == nil {
+					seen[p] = true
+					queue = append(queue, p)
+					break
+				}
+			}
+		}
+	}
+	return includeSite{src, 0, src}
+}
+
+// findSiteBudget bounds the files read to find an include site.
+const findSiteBudget = 1000
+
+// unresolvedSites is the number of include sites listed for each header.
+const unresolvedSites = 3
+
+func (r *report) Unresolved(header string, site includeSite) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, s := range r.unresolved[header] {
+		if s == site {
+			return
+		}
+	}
+	r.unresolved[header] = append(r.unresolved[header], site)
+}
+
+func (r *report) printUnresolved(w io.Writer) {
+	var headers []string
+	for h := range r.unresolved {
+		headers = append(headers, h)
+	}
+	// 影响源码最多的排在前面
+	sort.Slice(headers, func(i, j int) bool {
+		a, b := len(r.unresolved[headers[i]]), len(r.unresolved[headers[j]])
+		return a > b || a == b && headers[i] < headers[j]
+	})
+	fmt.Fprintf(w, "unresolved headers:\n")
+	for _, h := range headers {
+		sites := r.unresolved[h]
+		sort.Slice(sites, func(i, j int) bool { return sites[i].String() < sites[j].String() })
+		fmt.Fprintf(w, "  %s, included %d times:\n", h, len(sites))
+		for i, site := range sites {
+			if i == unresolvedSites {
+				fmt.Fprintf(w, "    ...\n")
+				break
+			}
+			fmt.Fprintf(w, "    %s\n", site)
+		}
+	}
+}
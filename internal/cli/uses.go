@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"sort"
+)
+
+var minUses = cmdline.Int("min-uses", 0, "emit include dirs needed by fewer sources than this only in the compile_commands.json entries of those sources")
+
+// Sources returns the sources that resolved headers in dir.
+func (s *statistics) Sources(dir string) []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var ret []string
+	for src := range s.dirSrcs[dir] {
+		ret = append(ret, src)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Uses returns the sources that resolved headers in each dir.
+func (s *statistics) Uses() map[string][]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ret := make(map[string][]string)
+	for dir, srcs := range s.dirSrcs {
+		for src := range srcs {
+			ret[dir] = append(ret[dir], src)
+		}
+		sort.Strings(ret[dir])
+	}
+	return ret
+}
+
+// Use records that src resolved headers in dirs, for results reused without
+// a scan.
+func (s *statistics) Use(src string, dirs []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, dir := range dirs {
+		m := s.dirSrcs[dir]
+		if m == nil {
+			m = make(map[string]bool)
+			s.dirSrcs[dir] = m
+		}
+		m[src] = true
+	}
+}
+
+// usesFile is the side file keeping the Uses of output.
+func usesFile(output string) string {
+	return output + ".uses"
+}
+
+// dropSources forgets which dirs the sources under dir used and removes the
+// include dirs no other source needs, before their rescan is merged. Dirs no
+// source asked for, like the ones of build systems, stay.
+func (fs *flagSet) dropSources(dir string) {
+	drop := make(map[string]bool)
+	for d, srcs := range fs.Uses {
+		var kept []string
+		for _, src := range srcs {
+			if !underAny(src, []string{dir}) {
+				kept = append(kept, src)
+			}
+		}
+		if len(kept) == 0 {
+			delete(fs.Uses, d)
+			drop[d] = true
+			continue
+		}
+		fs.Uses[d] = kept
+	}
+	keep := func(l []string) []string {
+		var ret []string
+		for _, d := range l {
+			if !drop[d] {
+				ret = append(ret, d)
+			}
+		}
+		return ret
+	}
+	fs.Includes = keep(fs.Includes)
+	fs.Systems = keep(fs.Systems)
+	fs.After = keep(fs.After)
+	fs.Test = keep(fs.Test)
+	for file, dirs := range fs.FileIncludes {
+		if underAny(file, []string{dir}) {
+			delete(fs.FileIncludes, file)
+			continue
+		}
+		fs.FileIncludes[file] = keep(dirs)
+	}
+}
+
+// splitRare moves the include dirs fewer than -min-uses sources need from the
+// shared flags to the flags of those sources. Dirs no source asked for, like
+// the ones of build systems, stay shared.
+func (fs *flagSet) splitRare(stats *statistics) {
+	if *minUses <= 1 {
+		return
+	}
+	var kept []string
+	for _, dir := range fs.Includes {
+		srcs := stats.Sources(dir)
+		if len(srcs) == 0 || len(srcs) >= *minUses {
+			kept = append(kept, dir)
+			continue
+		}
+		if fs.FileIncludes == nil {
+			fs.FileIncludes = make(map[string][]string)
+		}
+		for _, src := range srcs {
+			fs.FileIncludes[src] = append(fs.FileIncludes[src], dir)
+		}
+	}
+	fs.Includes = kept
+}
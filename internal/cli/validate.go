@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+var validatePolicy = cmdline.String("validate", "warn", "check that emitted include dirs exist, are readable, hold headers and are no duplicates: off, warn or drop")
+
+// validateBudget bounds the entries read to find a header in a dir.
+const validateBudget = 10000
+
+func checkValidate() error {
+	switch *validatePolicy {
+	case "off", "warn", "drop":
+		return nil
+	}
+	return fmt.Errorf("unknown -validate policy %q", *validatePolicy)
+}
+
+// hasHeader reports whether dir holds a header, looking at no more than
+// budget entries. Files without suffix count, like the C++ standard headers.
+func hasHeader(dir string, headerext map[string]bool, budget *int) bool {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	var subdirs []string
+	for _, f := range files {
+		*budget--
+		if f.IsDir() {
+			subdirs = append(subdirs, filepath.Join(dir, f.Name()))
+			continue
+		}
+		ext := filepath.Ext(f.Name())
+		if headerext[ext] || ext == "" {
+			return true
+		}
+	}
+	for _, sub := range subdirs {
+		if *budget <= 0 || hasHeader(sub, headerext, budget) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the include dirs and, with -validate drop, removes the bad
+// ones. It returns the problems found.
+func (p *printer) Validate(headerext map[string]bool) []string {
+	if *validatePolicy == "off" {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// 重复的目录保留真实路径而不是符号链接
+	real := make(map[string]bool)
+	for _, dir := range p.l {
+		real[dir] = isRealDir(dir)
+	}
+	sort.Slice(p.l, func(i, j int) bool {
+		if real[p.l[i]] != real[p.l[j]] {
+			return real[p.l[i]]
+		}
+		return p.l[i] < p.l[j]
+	})
+	var problems []string
+	var kept, seen []string
+	var same sameDirs
+	for _, dir := range p.l {
+		problem := ""
+		info, err := os.Stat(dir)
+		switch {
+		case err != nil:
+			problem = "does not exist"
+		case !info.IsDir():
+			problem = "is not a directory"
+		default:
+			if f, err := os.Open(dir); err != nil {
+				problem = "is not readable"
+			} else {
+				f.Close()
+				budget := validateBudget
+				if !hasHeader(dir, headerext, &budget) {
+					problem = "has no headers"
+				}
+			}
+		}
+		if problem == "" {
+			if i := same.Index(dir); i >= 0 {
+				problem = "is the same dir as " + mapPath(seen[i])
+			}
+		}
+		if problem == "" {
+			kept = append(kept, dir)
+			seen = append(seen, dir)
+			continue
+		}
+		problems = append(problems, mapPath(dir)+": "+problem)
+		if *validatePolicy == "warn" {
+			kept = append(kept, dir)
+		}
+	}
+	sort.Strings(kept)
+	p.l = kept
+	return problems
+}
+
+// sameDirs finds the dirs that are one dir on disk under several paths,
+// through symlinks or bind mounts.
+type sameDirs struct {
+	infos []os.FileInfo
+	// 不存在的目录只能按路径比较
+	paths map[string]int
+}
+
+// Index returns the position, among the dirs passed before, of the one dir
+// is the same as, or records dir and returns -1 if there is none.
+func (s *sameDirs) Index(dir string) int {
+	info, err := os.Stat(dir)
+	if err != nil {
+		if i, ok := s.paths[dir]; ok {
+			return i
+		}
+		if s.paths == nil {
+			s.paths = make(map[string]int)
+		}
+		s.paths[dir] = len(s.infos)
+		s.infos = append(s.infos, nil)
+		return -1
+	}
+	for i, other := range s.infos {
+		if other != nil && os.SameFile(info, other) {
+			return i
+		}
+	}
+	s.infos = append(s.infos, info)
+	return -1
+}
+
+// isRealDir reports whether dir is its own path with the symlinks resolved.
+func isRealDir(dir string) bool {
+	real, err := filepath.EvalSymlinks(dir)
+	return err == nil && real == dir
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var detectVendor = cmdline.Bool("detect-vendor", false, "emit include dirs of vendored libraries under src_dir as -isystem")
+
+var vendorParents = map[string]bool{
+	"third_party": true, "thirdparty": true, "3rdparty": true, "third-party": true,
+	"vendor": true, "vendored": true, "external": true, "extern": true, "deps": true,
+}
+
+var knownLibs = map[string]bool{
+	"boost": true, "eigen": true, "gtest": true, "googletest": true, "gmock": true,
+	"abseil": true, "abseil-cpp": true, "protobuf": true, "grpc": true, "zlib": true,
+	"openssl": true, "boringssl": true, "fmt": true, "spdlog": true, "json": true,
+	"nlohmann": true, "catch2": true, "rapidjson": true, "glog": true, "gflags": true,
+	"leveldb": true, "rocksdb": true, "sqlite": true, "curl": true, "libuv": true,
+	"benchmark": true, "flatbuffers": true, "lz4": true, "zstd": true, "snappy": true,
+}
+
+var versionSuffix = regexp.MustCompile(`[-_.]?v?[0-9][0-9._-]*$`)
+
+const vendorMaxDepth = 4
+
+func isLicense(name string) bool {
+	name = strings.ToUpper(name)
+	return strings.HasPrefix(name, "LICENSE") || strings.HasPrefix(name, "LICENCE") || strings.HasPrefix(name, "COPYING")
+}
+
+func isVendorRoot(dir string, files []os.FileInfo) bool {
+	var license, include bool
+	for _, f := range files {
+		if f.IsDir() && f.Name() == "include" {
+			include = true
+		}
+		if !f.IsDir() && isLicense(f.Name()) {
+			license = true
+		}
+	}
+	if !license {
+		return false
+	}
+	name := strings.ToLower(versionSuffix.ReplaceAllString(filepath.Base(dir), ""))
+	return include || knownLibs[name] || vendorParents[filepath.Base(filepath.Dir(dir))]
+}
+
+// findVendored returns the roots of vendored libraries below srcroot.
+func findVendored(srcroot string) []string {
+	var ret []string
+	var walk func(dir string, depth int)
+	walk = func(dir string, depth int) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		if depth > 0 && isVendorRoot(dir, files) {
+			ret = append(ret, dir)
+			return
+		}
+		if depth == vendorMaxDepth {
+			return
+		}
+		for _, f := range files {
+			if f.IsDir() && f.Name()[0] != '.' {
+				walk(filepath.Join(dir, f.Name()), depth+1)
+			}
+		}
+	}
+	walk(srcroot, 0)
+	return ret
+}
+
+func underAny(dir string, roots []string) bool {
+	for _, root := range roots {
+		if dir == root || strings.HasPrefix(dir, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
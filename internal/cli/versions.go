@@ -0,0 +1,239 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var versionPolicy = cmdline.String("version-policy", "all", "of the dirs of several versions of a library, like boost_1_76 and boost_1_81, emit: all, newest or build (the version the build files of the source dir name, else the newest); versions pinned in the config are emitted alone with any policy")
+
+// versionPins are the versions of the config, library name to version.
+var versionPins map[string]string
+
+// versionRoot is the source dir, its build files name the versions used.
+var versionRoot string
+
+// versionedRe matches a dir named after a library and its version, like
+// boost_1_76, boost-1.81.0 or qt5.15.
+var versionedRe = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9+]*?[A-Za-z+])[-_.]?v?(\d+(?:[._]\d+)+)$`)
+
+func checkVersionPolicy() error {
+	switch *versionPolicy {
+	case "newest", "build", "all":
+		return nil
+	}
+	return fmt.Errorf("unknown -version-policy %q, want all, newest or build", *versionPolicy)
+}
+
+// versionedDir splits dir at its innermost versioned component. key is dir
+// with the version left out, the same for all the versions of the library.
+func versionedDir(dir string) (key, name, version string) {
+	parts := strings.Split(filepath.ToSlash(dir), "/")
+	for i := len(parts) - 1; i >= 0; i-- {
+		m := versionedRe.FindStringSubmatch(parts[i])
+		if m == nil {
+			continue
+		}
+		name = strings.ToLower(m[1])
+		version = strings.Replace(m[2], "_", ".", -1)
+		key = strings.Join(parts[:i], "/") + "/" + name + "*/" + strings.Join(parts[i+1:], "/")
+		return key, name, version
+	}
+	return "", "", ""
+}
+
+// selectVersions keeps, of the dirs resolving a header that differ only in
+// the version of a library, the dirs of one version: the one pinned in the
+// config, then with -version-policy build the one the build files name, or
+// the newest. With -version-policy all only pinned libraries lose dirs.
+func selectVersions(ctx context.Context, dirs []string) []string {
+	if len(dirs) < 2 || *versionPolicy == "all" && len(versionPins) == 0 {
+		return dirs
+	}
+	type group struct {
+		name     string
+		versions []string
+	}
+	groups := make(map[string]*group)
+	for _, dir := range dirs {
+		key, name, version := versionedDir(dir)
+		if key == "" {
+			continue
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &group{name: name}
+			groups[key] = g
+		}
+		g.versions = append(g.versions, version)
+	}
+	pick := make(map[string]string)
+	for key, g := range groups {
+		versions := dedup(g.versions)
+		if len(versions) < 2 {
+			continue
+		}
+		version, why := pickVersion(g.name, versions)
+		if version == "" {
+			continue
+		}
+		pick[key] = version
+		sessionOf(ctx).rep.Version(key, version, why, versions)
+	}
+	if len(pick) == 0 {
+		return dirs
+	}
+	var ret []string
+	for _, dir := range dirs {
+		key, _, version := versionedDir(dir)
+		if v, ok := pick[key]; ok && v != version {
+			continue
+		}
+		ret = append(ret, dir)
+	}
+	return ret
+}
+
+// pickVersion chooses one of the versions of the library name and says why,
+// or returns "" to keep them all.
+func pickVersion(name string, versions []string) (string, string) {
+	if v, ok := versionPins[name]; ok {
+		for _, version := range versions {
+			if version == v {
+				return version, "pinned in the config"
+			}
+		}
+	}
+	switch *versionPolicy {
+	case "all":
+		return "", ""
+	case "build":
+		var named []string
+		for _, version := range versions {
+			if buildNames(name, version) {
+				named = append(named, version)
+			}
+		}
+		if len(named) != 0 {
+			return newestVersion(named), "named by the build files"
+		}
+	}
+	return newestVersion(versions), "newest"
+}
+
+func newestVersion(versions []string) string {
+	best := versions[0]
+	for _, version := range versions[1:] {
+		if newerVersion(version, best) {
+			best = version
+		}
+	}
+	return best
+}
+
+// buildFileNames are the build files read for the versions of libraries.
+var buildFileNames = map[string]bool{
+	"CMakeLists.txt": true,
+	"meson.build":    true,
+	"Makefile":       true,
+	"conanfile.txt":  true,
+	"conanfile.py":   true,
+	"vcpkg.json":     true,
+	"WORKSPACE":      true,
+	"MODULE.bazel":   true,
+	"BUILD":          true,
+	"BUILD.bazel":    true,
+}
+
+var buildLines struct {
+	once  sync.Once
+	lines []string
+}
+
+// buildNames reports whether a line of the build files under versionRoot
+// names both the library and the version, like find_package(Boost 1.76).
+func buildNames(name, version string) bool {
+	buildLines.once.Do(func() {
+		buildLines.lines = readBuildLines(versionRoot)
+	})
+	re := regexp.MustCompile(`(^|[^0-9.])` + strings.Replace(regexp.QuoteMeta(version), `\.`, `[._]`, -1) + `($|[^0-9])`)
+	for _, line := range buildLines.lines {
+		if strings.Contains(line, name) && re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// readBuildLines returns the lines of the build files under root, lower
+// cased.
+func readBuildLines(root string) []string {
+	if root == "" {
+		return nil
+	}
+	var lines []string
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if path != root && (name[0] == '.' || isExcluded(name)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !buildFileNames[name] && !strings.HasSuffix(name, ".cmake") {
+			return nil
+		}
+		// 生成的大文件里不会写版本要求
+		if info.Size() > 1<<20 {
+			return nil
+		}
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		lines = append(lines, strings.Split(strings.ToLower(string(buf)), "\n")...)
+		return nil
+	})
+	return lines
+}
+
+// Version records the version picked of the dirs matching key.
+func (r *report) Version(key, version, why string, versions []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.versions[key]; ok {
+		return
+	}
+	var others []string
+	for _, v := range versions {
+		if v != version {
+			others = append(others, v)
+		}
+	}
+	sort.Strings(others)
+	r.versions[key] = fmt.Sprintf("%s, %s, over %s", version, why, strings.Join(others, " "))
+}
+
+func (r *report) printVersions(w io.Writer) {
+	var keys []string
+	for key := range r.versions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(w, "dirs of several versions, one version emitted:\n")
+	for _, key := range keys {
+		fmt.Fprintf(w, "  %s: %s\n", mapPath(key), r.versions[key])
+	}
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// warmOutputs are the outputs looked up above a file, in order of preference.
+var warmOutputs = []string{"compdb", "clangd", "clang_complete", "compile_flags"}
+
+// vcsDirs mark the top of a project when no search root is given.
+var vcsDirs = []string{".git", ".hg", ".svn"}
+
+// runWarm prints the flags of a file an editor just opened. An up to date
+// output in the dirs above it is used as is, otherwise the file and the
+// sources next to it are resolved against the search roots, or the project
+// the file is in.
+func runWarm(args []string) error {
+	fs := flag.NewFlagSet("warm", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: clang_complete " + commands["warm"].usage)
+	}
+	path, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	set := cachedFlagSet(path, info)
+	if set == nil {
+		set, err = warmFlagSet(path)
+		if err != nil {
+			return err
+		}
+	}
+	for _, g := range flagGroups(set.ArgsFor(path)) {
+		fmt.Println(strings.Join(g, " "))
+	}
+	return nil
+}
+
+// cachedFlagSet returns the flags of the nearest output above path that is
+// newer than it and covers it, or nil.
+func cachedFlagSet(path string, info os.FileInfo) *flagSet {
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		for _, name := range warmOutputs {
+			f := formats[name]
+			out := filepath.Join(dir, f.output)
+			oinfo, err := os.Stat(out)
+			if err != nil || oinfo.ModTime().Before(info.ModTime()) {
+				continue
+			}
+			set, err := readFlagSet(out, f)
+			if err != nil {
+				continue
+			}
+			// 只有列出文件的格式才能判断是否覆盖了这个文件
+			if len(set.Files) != 0 && !containsString(set.Files, path) {
+				continue
+			}
+			log.Debug("warm %s from %s", path, out)
+			return set
+		}
+		if filepath.Dir(dir) == dir {
+			return nil
+		}
+	}
+}
+
+// warmFlagSet resolves path together with the sources in its dir.
+func warmFlagSet(path string) (*flagSet, error) {
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.apply(); err != nil {
+		return nil, err
+	}
+	headerext, srcext := suffixes(cfg)
+
+	roots := searchroots
+	if len(roots) == 0 {
+		roots = rootSlice{{Path: projectRoot(filepath.Dir(path))}}
+	}
+	for _, root := range roots.Paths() {
+		if abs, err := filepath.Abs(root); err == nil {
+			sandboxAllow(abs)
+		}
+	}
+	sandboxAllow(filepath.Dir(path))
+
+	paths := []string{path}
+	files, _ := ioutil.ReadDir(filepath.Dir(path))
+	for _, file := range files {
+		p := filepath.Join(filepath.Dir(path), file.Name())
+		if file.Mode().IsRegular() && srcext[filepath.Ext(p)] && p != path {
+			paths = append(paths, p)
+		}
+	}
+	r := newResolver(roots, headerext)
+	return r.resolve(context.Background(), paths)
+}
+
+// projectRoot returns the nearest dir above dir holding a VCS dir, or dir.
+func projectRoot(dir string) string {
+	for p := dir; ; p = filepath.Dir(p) {
+		for _, name := range vcsDirs {
+			if _, err := os.Stat(filepath.Join(p, name)); err == nil {
+				return p
+			}
+		}
+		if filepath.Dir(p) == p {
+			return dir
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+var watchInterval = cmdline.Duration("watch", 0, "after the run, check the sources and search roots at this interval and run again when they change, see -notify")
+
+// watchChildEnv marks the runs started by watch, which do not watch again.
+const watchChildEnv = "CLANG_COMPLETE_WATCH_CHILD"
+
+// watchState fingerprints the sources under srcroot by path, size and mtime,
+// and the listings of roots.
+func watchState(srcroot string, srcext map[string]bool, roots []string) string {
+	l := list.New()
+	collect(srcroot, l, srcext)
+	var sources []string
+	for e := l.Front(); e != nil; e = e.Next() {
+		sources = append(sources, e.Value.(string))
+	}
+	state := treeHash(sources)
+	for _, root := range roots {
+		if abs, err := filepath.Abs(root); err == nil {
+			state += listingHash(fingerprint(abs, nil))
+		}
+	}
+	return state
+}
+
+// watch runs clang_complete again with the same arguments each time the
+// sources or the search roots change, until it is killed. The runs write the
+// output and send the -notify changes themselves.
+func watch(srcroot string, srcext map[string]bool, roots []string) {
+	if *watchInterval <= 0 || os.Getenv(watchChildEnv) != "" {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "watching %s every %s\n", srcroot, *watchInterval)
+	prev := watchState(srcroot, srcext, roots)
+	for {
+		time.Sleep(*watchInterval)
+		cur := watchState(srcroot, srcext, roots)
+		if cur == prev {
+			continue
+		}
+		prev = cur
+		cmd := exec.Command(executable(), os.Args[1:]...)
+		cmd.Env = append(os.Environ(), watchChildEnv+"=1")
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintln(os.Stderr, "watch:", err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// wizardSample is the number of sources previewed.
+const wizardSample = 10
+
+// runWizard asks for the settings of a first run, suggesting the ones init
+// would pick, previews the sources, writes the config and runs the scan.
+func runWizard(args []string) error {
+	fs := flag.NewFlagSet("wizard", flag.ExitOnError)
+	out := fs.String("o", "clang_complete.json", "config file to write")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New("usage: clang_complete " + commands["wizard"].usage)
+	}
+	in := bufio.NewReader(os.Stdin)
+	ask := func(prompt, def string) string {
+		fmt.Printf("%s [%s]: ", prompt, def)
+		line, _ := in.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+		return def
+	}
+	yes := func(prompt string) bool {
+		fmt.Printf("%s [Y/n] ", prompt)
+		line, _ := in.ReadString('\n')
+		line = strings.ToLower(strings.TrimSpace(line))
+		return line == "" || line == "y" || line == "yes"
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	srcroot := ask("source dir", ".")
+	dir, err := filepath.Abs(srcroot)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", srcroot)
+	}
+	cfg, system := initConfig(dir)
+	fmt.Printf("build system: %s\n", system)
+
+	// 建议的路径相对于源码目录，配置文件里的相对于当前目录
+	var suggested []string
+	for _, root := range cfg.SearchRoots {
+		p, attr := root, ""
+		if strings.HasSuffix(p, ":after") {
+			p, attr = strings.TrimSuffix(p, ":after"), ":after"
+		}
+		p = filepath.Join(dir, p)
+		if rel, err := filepath.Rel(cwd, p); err == nil {
+			p = rel
+		}
+		suggested = append(suggested, p+attr)
+	}
+	cfg.SearchRoots = strings.Fields(ask("search roots, :after for vendored ones", strings.Join(suggested, " ")))
+
+	cc := os.Getenv("CC")
+	if cc == "" {
+		cc = "gcc"
+	}
+	for {
+		cc = ask("compiler", cc)
+		if _, err := exec.LookPath(cc); err == nil {
+			break
+		}
+		fmt.Printf("%s not found\n", cc)
+	}
+	if cc != "gcc" {
+		cfg.Compiler = cc
+	}
+	for {
+		cfg.Format = ask("output format: clang_complete, compile_flags, compdb, clangd or make", cfg.Format)
+		if _, err := lookupFormat(cfg.Format); err == nil {
+			break
+		}
+		fmt.Printf("unknown format %s\n", cfg.Format)
+	}
+	cfg.Exclude = strings.Fields(ask("excluded dirs", strings.Join(cfg.Exclude, " ")))
+
+	excludes = cfg.Exclude
+	_, srcext := suffixes(cfg)
+	l := list.New()
+	if err := collect(dir, l, srcext); err != nil {
+		return err
+	}
+	fmt.Printf("%d sources, like:\n", l.Len())
+	n := 0
+	for e := l.Front(); e != nil && n < wizardSample; e = e.Next() {
+		p := e.Value.(string)
+		if rel, err := filepath.Rel(cwd, p); err == nil {
+			p = rel
+		}
+		fmt.Printf("  %s\n", p)
+		n++
+	}
+
+	if _, err := os.Stat(*out); err == nil && !yes(*out+" exists, overwrite it?") {
+		return nil
+	}
+	buf, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(*out, append(buf, '\n'), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s\n", *out)
+	if !yes("run the first scan now?") {
+		fmt.Printf("run: clang_complete -config %s %s\n", *out, srcroot)
+		return nil
+	}
+	cmd := exec.Command(executable(), "-config", *out, srcroot)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
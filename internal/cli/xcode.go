@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var xcodeproj = cmdline.String("xcodeproj", "", "merge search paths and defines from an xcode project")
+
+type buildSettings struct {
+	includes   []string
+	frameworks []string
+	defines    []string
+}
+
+func (b *buildSettings) set(key string, values []string) {
+	switch key {
+	case "HEADER_SEARCH_PATHS", "USER_HEADER_SEARCH_PATHS", "SYSTEM_HEADER_SEARCH_PATHS":
+		b.includes = append(b.includes, values...)
+	case "FRAMEWORK_SEARCH_PATHS", "SYSTEM_FRAMEWORK_SEARCH_PATHS":
+		b.frameworks = append(b.frameworks, values...)
+	case "GCC_PREPROCESSOR_DEFINITIONS":
+		b.defines = append(b.defines, values...)
+	}
+}
+
+func (b *buildSettings) flags(base string) (dirs []string, flags []string) {
+	clean := func(p string) string {
+		p = strings.TrimSuffix(strings.TrimSuffix(p, "/**"), "/*")
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(base, p)
+		}
+		return filepath.Clean(p)
+	}
+	for _, p := range b.includes {
+		if p == "$(inherited)" {
+			continue
+		}
+		dirs = append(dirs, clean(p))
+	}
+	for _, p := range b.frameworks {
+		if p == "$(inherited)" {
+			continue
+		}
+		flags = append(flags, "-F"+clean(p))
+	}
+	for _, d := range b.defines {
+		if d == "$(inherited)" {
+			continue
+		}
+		flags = append(flags, "-D"+d)
+	}
+	return dirs, flags
+}
+
+func xcodeFlags(proj string) ([]string, []string, error) {
+	proj, err := filepath.Abs(proj)
+	if err != nil {
+		return nil, nil, err
+	}
+	base := filepath.Dir(proj)
+
+	settings := new(buildSettings)
+	if _, err := exec.LookPath("xcodebuild"); err == nil {
+		err = xcodebuildSettings(proj, settings)
+	} else {
+		err = pbxprojSettings(proj, settings)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	dirs, flags := settings.flags(base)
+	return dirs, flags, nil
+}
+
+func xcodebuildSettings(proj string, settings *buildSettings) error {
+	cmd := exec.Command("xcodebuild", "-project", proj, "-alltargets", "-showBuildSettings")
+	out, err := cmd.Output()
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		i := strings.Index(line, " = ")
+		if i == -1 {
+			continue
+		}
+		settings.set(line[:i], splitQuoted(line[i+3:]))
+	}
+	return scanner.Err()
+}
+
+var pbxSetting = regexp.MustCompile(`(?s)\b([A-Z_]+) = (\([^)]*\)|[^;]*);`)
+
+// pbxprojSettings reads build settings straight from project.pbxproj, used
+// when xcodebuild is not installed. Only $(SRCROOT) style variables are expanded.
+func pbxprojSettings(proj string, settings *buildSettings) error {
+	buf, err := ioutil.ReadFile(filepath.Join(proj, "project.pbxproj"))
+	if err != nil {
+		return err
+	}
+	root := filepath.Dir(proj)
+	expand := strings.NewReplacer("$(SRCROOT)", root, "$(PROJECT_DIR)", root, "${SRCROOT}", root, "${PROJECT_DIR}", root)
+	for _, m := range pbxSetting.FindAllSubmatch(buf, -1) {
+		value := strings.TrimSpace(string(m[2]))
+		if strings.HasPrefix(value, "(") {
+			value = strings.Replace(strings.Trim(value, "()"), ",", " ", -1)
+		}
+		var values []string
+		for _, v := range splitQuoted(value) {
+			values = append(values, expand.Replace(v))
+		}
+		settings.set(string(m[1]), values)
+	}
+	return nil
+}
+
+// splitQuoted splits s into fields like a shell does, honoring quotes and backslashes.
+func splitQuoted(s string) []string {
+	var ret []string
+	var cur []rune
+	var quote rune
+	var escape, inword bool
+	for _, c := range s {
+		switch {
+		case escape:
+			cur = append(cur, c)
+			escape = false
+		case c == '\\' && quote != '\'':
+			escape = true
+			inword = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				cur = append(cur, c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inword = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inword {
+				ret = append(ret, string(cur))
+				cur = cur[:0]
+				inword = false
+			}
+		default:
+			cur = append(cur, c)
+			inword = true
+		}
+	}
+	if inword {
+		ret = append(ret, string(cur))
+	}
+	return ret
+}
@@ -0,0 +1,91 @@
+package testgen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Replay reruns the fixture at dir with the clang_complete binary cli and
+// fakecc as the compiler, and checks the output against the expected one.
+// The stderr of the run goes to log when not nil, and always when it fails.
+func Replay(dir, cli, fakecc string, log io.Writer) error {
+	f, err := Load(dir)
+	if err != nil {
+		return err
+	}
+	return f.Replay(cli, fakecc, log)
+}
+
+// Replay reruns the fixture like the package level Replay.
+func (f *Fixture) Replay(cli, fakecc string, log io.Writer) error {
+	want, err := f.Expected()
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	// 输出里的编译器名要和录制时一致，用同名链接指向 fakecc
+	env := append(os.Environ(), "CC="+fakecc, FixtureEnv+"="+f.Dir)
+	if cc := f.Manifest.Compiler; cc != "" {
+		if strings.ContainsAny(cc, `/\`) {
+			return fmt.Errorf("recorded with CC=%s, only compilers found in PATH can be replayed", cc)
+		}
+		bin := filepath.Join(tmp, "bin")
+		if err := os.Mkdir(bin, 0755); err != nil {
+			return err
+		}
+		if err := os.Symlink(fakecc, filepath.Join(bin, cc)); err != nil {
+			return err
+		}
+		env = append(env, "CC="+cc, "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	}
+
+	output := filepath.Join(tmp, filepath.Base(f.Manifest.Output))
+	cmd := exec.Command(cli, f.Args(output)...)
+	cmd.Env = env
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("%s\n%s", err, stderr.Bytes())
+	}
+	if log != nil {
+		log.Write(stderr.Bytes())
+	}
+	got, err := ioutil.ReadFile(output)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("output differs\n%s", firstDiff(string(want), string(got)))
+	}
+	return nil
+}
+
+// firstDiff shows the first line where got differs from want.
+func firstDiff(want, got string) string {
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y string
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return fmt.Sprintf("  line %d\n  want: %s\n  got:  %s", i+1, x, y)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,278 @@
+// Package testgen snapshots a run of clang_complete into a self-contained
+// fixture: a copy of the source tree, the compiler invocations with their
+// outputs, and the expected output file. Absolute paths of the original
+// tree are replaced by a placeholder so fixtures can be replayed anywhere.
+package testgen
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Root is the placeholder for the fixture directory in recorded data.
+const Root = "${ROOT}"
+
+// FixtureEnv names the fixture dir fakecc answers from.
+const FixtureEnv = "CLANG_COMPLETE_FIXTURE"
+
+const (
+	treeDir      = "tree"
+	manifestFile = "fixture.json"
+	commandsFile = "commands.json"
+	expectedDir  = "expected"
+)
+
+// Command is a recorded compiler invocation.
+type Command struct {
+	Args   []string `json:"args"`
+	Stdout string   `json:"stdout"`
+	Stderr string   `json:"stderr"`
+	Exit   int      `json:"exit"`
+}
+
+// Manifest describes how to rerun a fixture.
+type Manifest struct {
+	Src    string   `json:"src"`
+	Roots  []string `json:"roots"`
+	Format string   `json:"format"`
+	Output string   `json:"output"`
+	Flags  []string `json:"flags"`
+	// CC of the recorded run, which compile commands name
+	Compiler string `json:"compiler,omitempty"`
+}
+
+type Recorder struct {
+	dir      string
+	from, to []string
+
+	lock     sync.Mutex
+	commands []Command
+	seen     map[string]bool
+}
+
+// NewRecorder creates a recorder writing to dir. paths maps absolute
+// directories of the original run to paths relative to the fixture tree.
+func NewRecorder(dir string, paths map[string]string) (*Recorder, error) {
+	err := os.MkdirAll(filepath.Join(dir, treeDir), 0755)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{dir: dir, seen: make(map[string]bool)}
+	for from := range paths {
+		r.from = append(r.from, from)
+	}
+	// 长的路径优先替换
+	sort.Slice(r.from, func(i, j int) bool { return len(r.from[i]) > len(r.from[j]) })
+	for _, from := range r.from {
+		r.to = append(r.to, Root+"/"+treeDir+"/"+filepath.ToSlash(paths[from]))
+	}
+	return r, nil
+}
+
+// Rel replaces the recorded absolute paths in s by placeholders.
+func (r *Recorder) Rel(s string) string {
+	for i, from := range r.from {
+		s = strings.Replace(s, from, r.to[i], -1)
+	}
+	return s
+}
+
+// Copy copies the files under src accepted by accept into the fixture tree at dst.
+func (r *Recorder) Copy(src, dst string, accept func(path string, info os.FileInfo) bool) error {
+	base := filepath.Join(r.dir, treeDir, dst)
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if len(info.Name()) > 1 && info.Name()[0] == '.' {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || !accept(path, info) {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		return copyFile(path, filepath.Join(base, rel))
+	})
+}
+
+func copyFile(src, dst string) error {
+	err := os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err1 := out.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+// Command records a compiler invocation. Identical invocations are kept once.
+func (r *Recorder) Command(args []string, stdout, stderr []byte, exit int) {
+	c := Command{
+		Stdout: r.Rel(string(stdout)),
+		Stderr: r.Rel(string(stderr)),
+		Exit:   exit,
+	}
+	for _, arg := range args {
+		c.Args = append(c.Args, r.Rel(arg))
+	}
+	key := strings.Join(c.Args, "\x00")
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.seen[key] {
+		return
+	}
+	r.seen[key] = true
+	r.commands = append(r.commands, c)
+}
+
+// Close writes the manifest, the recorded commands and the expected output.
+func (r *Recorder) Close(m *Manifest, expected []byte) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	err := writeJSON(filepath.Join(r.dir, manifestFile), m)
+	if err != nil {
+		return err
+	}
+	err = writeJSON(filepath.Join(r.dir, commandsFile), r.commands)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(r.dir, expectedDir, filepath.Base(m.Output))
+	err = os.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, []byte(r.Rel(string(expected))), 0644)
+}
+
+func writeJSON(name string, v interface{}) error {
+	buf, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, append(buf, '\n'), 0644)
+}
+
+// Fixture is a loaded fixture directory.
+type Fixture struct {
+	Dir      string
+	Manifest Manifest
+	Commands []Command
+}
+
+// Load reads the fixture at dir with placeholders expanded to dir.
+func Load(dir string) (*Fixture, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	f := &Fixture{Dir: dir}
+	buf, err := ioutil.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(buf, &f.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	buf, err = ioutil.ReadFile(filepath.Join(dir, commandsFile))
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal([]byte(f.Expand(string(buf))), &f.Commands)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Expand replaces the placeholders in s by the fixture directory.
+func (f *Fixture) Expand(s string) string {
+	return strings.Replace(s, Root, filepath.ToSlash(f.Dir), -1)
+}
+
+// Path returns the absolute path of a tree relative path from the manifest.
+func (f *Fixture) Path(rel string) string {
+	return filepath.Join(f.Dir, treeDir, rel)
+}
+
+// Args returns the arguments of clang_complete rerunning the fixture with
+// the output written to output.
+func (f *Fixture) Args(output string) []string {
+	var args []string
+	for _, flag := range f.Manifest.Flags {
+		args = append(args, f.Expand(flag))
+	}
+	for _, root := range f.Manifest.Roots {
+		path, attrs := root, ""
+		if i := strings.IndexByte(root, ':'); i >= 0 {
+			path, attrs = root[:i], root[i:]
+		}
+		args = append(args, "-s", f.Path(path)+attrs)
+	}
+	return append(args, "-format", f.Manifest.Format, "-o", output, f.Path(f.Manifest.Src))
+}
+
+// WithFormat returns a copy of the fixture generating format to output, so
+// one recorded tree can be replayed for every format. The expected output is
+// then read from the file named like output.
+func (f *Fixture) WithFormat(format, output string) *Fixture {
+	g := *f
+	g.Manifest.Format, g.Manifest.Output = format, output
+	return &g
+}
+
+// Expected returns the expected output with placeholders expanded.
+func (f *Fixture) Expected() ([]byte, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(f.Dir, expectedDir, filepath.Base(f.Manifest.Output)))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(f.Expand(string(buf))), nil
+}
+
+// Lookup returns the recorded invocation with exactly args.
+func (f *Fixture) Lookup(args []string) (*Command, bool) {
+	for i := range f.Commands {
+		c := &f.Commands[i]
+		if len(c.Args) != len(args) {
+			continue
+		}
+		match := true
+		for j := range args {
+			if args[j] != c.Args[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return c, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,182 @@
+package testgen
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden fixture in testdata")
+
+const golden = "testdata/golden"
+
+// record makes a fixture of a small run into dir: a source tree under src
+// and an outside search root under ext.
+func record(t *testing.T, dir string) {
+	tmp := t.TempDir()
+	src, ext := filepath.Join(tmp, "src"), filepath.Join(tmp, "ext")
+	files := map[string]string{
+		"src/a.c":          "#include \"a.h\"\n#include <sys.h>\n",
+		"src/inc/a.h":      "int a;\n",
+		"src/big.c":        "int big;\n",
+		"src/.git/config":  "[core]\n",
+		"ext/sys.h":        "int sys;\n",
+		"ext/.hidden.h":    "int hidden;\n",
+		"ext/sub/nested.h": "int nested;\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tmp, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := NewRecorder(dir, map[string]string{src: "src", ext: "roots/1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	accept := func(path string, info os.FileInfo) bool { return filepath.Base(path) != "big.c" }
+	if err := r.Copy(src, "src", accept); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Copy(ext, "roots/1", accept); err != nil {
+		t.Fatal(err)
+	}
+
+	deps := []string{"-M", "-I" + filepath.Join(src, "inc"), "-I" + ext, filepath.Join(src, "a.c")}
+	out := "a.o: " + filepath.Join(src, "a.c") + " " + filepath.Join(src, "inc", "a.h") + " " + filepath.Join(ext, "sys.h") + "\n"
+	r.Command(deps, []byte(out), nil, 0)
+	r.Command(deps, []byte("recorded twice"), nil, 0)
+	r.Command([]string{"-E", "-v", "-"}, nil, []byte("#include <...> search starts here:\n "+ext+"\n"), 1)
+
+	m := &Manifest{
+		Src:    "src",
+		Roots:  []string{"src/inc", "roots/1:sys"},
+		Format: "clang_complete",
+		Output: ".clang_complete",
+		Flags:  []string{"-sys=false", "-x", "-I" + filepath.Join(src, "inc")},
+	}
+	m.Flags[2] = r.Rel(m.Flags[2])
+	expected := "-I" + filepath.Join(src, "inc") + "\n-isystem " + ext + "\n"
+	if err := r.Close(m, []byte(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// listFiles returns the contents of the regular files under dir by relative path.
+func listFiles(t *testing.T, dir string) map[string]string {
+	files := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(buf)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestRecorderGolden(t *testing.T) {
+	dir := t.TempDir()
+	record(t, dir)
+	got := listFiles(t, dir)
+	if *update {
+		if err := os.RemoveAll(golden); err != nil {
+			t.Fatal(err)
+		}
+		record(t, golden)
+	}
+	want := listFiles(t, golden)
+
+	var names []string
+	for name := range want {
+		names = append(names, name)
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w, ok1 := want[name]
+		g, ok2 := got[name]
+		switch {
+		case !ok1:
+			t.Errorf("unexpected file %s", name)
+		case !ok2:
+			t.Errorf("missing file %s", name)
+		case w != g:
+			t.Errorf("%s differs\ngot:\n%s\nwant:\n%s", name, g, w)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	f, err := Load(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.ToSlash(f.Dir)
+	tree := filepath.Join(f.Dir, "tree")
+
+	if got, want := f.Expand(Root+"/tree/src"), root+"/tree/src"; got != want {
+		t.Errorf("Expand = %q, want %q", got, want)
+	}
+	if got, want := f.Path("src/inc"), filepath.Join(tree, "src", "inc"); got != want {
+		t.Errorf("Path = %q, want %q", got, want)
+	}
+
+	args := f.Args("out")
+	want := []string{
+		"-sys=false", "-x", "-I" + root + "/tree/src/inc",
+		"-s", filepath.Join(tree, "src", "inc"),
+		"-s", filepath.Join(tree, "roots", "1") + ":sys",
+		"-format", "clang_complete", "-o", "out", filepath.Join(tree, "src"),
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Args =\n%q\nwant\n%q", args, want)
+	}
+
+	expected, err := f.Expected()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(expected, []byte(Root)) || !bytes.Contains(expected, []byte("-isystem "+root+"/tree/roots/1\n")) {
+		t.Errorf("Expected not expanded:\n%s", expected)
+	}
+
+	if len(f.Commands) != 2 {
+		t.Fatalf("got %d commands, want 2 after dedup", len(f.Commands))
+	}
+	c, ok := f.Lookup(f.Commands[0].Args)
+	if !ok || c != &f.Commands[0] {
+		t.Fatalf("Lookup of a recorded invocation failed")
+	}
+	if strings.Contains(c.Stdout, Root) || !strings.Contains(c.Stdout, root+"/tree/src/a.c") {
+		t.Errorf("Stdout not expanded: %q", c.Stdout)
+	}
+	if _, ok := f.Lookup(c.Args[:len(c.Args)-1]); ok {
+		t.Errorf("Lookup matched a prefix of a recorded invocation")
+	}
+	if c, ok := f.Lookup([]string{"-E", "-v", "-"}); !ok || c.Exit != 1 {
+		t.Errorf("Lookup(-E -v -) = %v, %v", c, ok)
+	}
+}
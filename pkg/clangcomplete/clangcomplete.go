@@ -0,0 +1,120 @@
+// Package clangcomplete gives Go editor tooling the include flags of single
+// files. A Client embeds the resolver of clang_complete: it builds the header
+// index lazily, on first use, and memoizes the flags of each file until it
+// changes.
+package clangcomplete
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+
+	"github.com/icexin/clang_complete/internal/cli"
+)
+
+// Options configure the resolver of a Client.
+type Options struct {
+	// Dir is the project dir, the search root when Args give none and the
+	// base of relative search roots. The current dir by default.
+	Dir string
+	// Args are the options of clang_complete given before a subcommand,
+	// like "-s", "include". They apply to the whole process, so all the
+	// Clients of a process must be given the same ones.
+	Args []string
+}
+
+// Client resolves files in process. It is safe for concurrent use.
+type Client struct {
+	opts Options
+
+	lock sync.Mutex
+	r    *cli.Resolver
+}
+
+// New returns a Client; the resolver is set up by the first request.
+func New(opts Options) *Client {
+	return &Client{opts: opts}
+}
+
+// Default is the Client of FlagsForFile and Stale, resolving in the current
+// dir with the default options.
+var Default = New(Options{})
+
+// FlagsForFile returns the include flags of path with Default.
+func FlagsForFile(ctx context.Context, path string) ([]string, error) {
+	return Default.FlagsForFile(ctx, path)
+}
+
+// Stale reports with Default whether outputPath, written with -meta, is
+// stale relative to the sources of its dir, and why. An output that cannot
+// be checked is reported stale.
+func Stale(outputPath string) (bool, string) {
+	stale, reason, err := Default.Stale(context.Background(), outputPath, "")
+	if err != nil {
+		return true, err.Error()
+	}
+	return stale, reason
+}
+
+// resolver sets up the resolver, again on the next request if it failed.
+func (c *Client) resolver() (*cli.Resolver, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.r != nil {
+		return c.r, nil
+	}
+	dir := c.opts.Dir
+	if dir == "" {
+		dir = "."
+	}
+	r, err := cli.NewResolver(dir, c.opts.Args)
+	if err != nil {
+		return nil, err
+	}
+	c.r = r
+	return r, nil
+}
+
+// FlagsForFile returns the include flags of path, one flag per element,
+// memoized until the file changes.
+func (c *Client) FlagsForFile(ctx context.Context, path string) ([]string, error) {
+	r, err := c.resolver()
+	if err != nil {
+		return nil, err
+	}
+	return r.FlagsForFile(ctx, path)
+}
+
+// Stale reports whether output, written with -meta, is stale relative to
+// the sources under srcDir, the dir of output if empty, and why.
+func (c *Client) Stale(ctx context.Context, output, srcDir string) (bool, string, error) {
+	if err := ctx.Err(); err != nil {
+		return false, "", err
+	}
+	r, err := c.resolver()
+	if err != nil {
+		return false, "", err
+	}
+	output, err = filepath.Abs(output)
+	if err != nil {
+		return false, "", err
+	}
+	if srcDir != "" {
+		if srcDir, err = filepath.Abs(srcDir); err != nil {
+			return false, "", err
+		}
+	}
+	return r.Stale(output, srcDir)
+}
+
+// Close releases the header index; the next request builds a new one.
+func (c *Client) Close() error {
+	c.lock.Lock()
+	r := c.r
+	c.r = nil
+	c.lock.Unlock()
+	if r != nil {
+		r.Close()
+	}
+	return nil
+}
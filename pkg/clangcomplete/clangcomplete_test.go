@@ -0,0 +1,88 @@
+package clangcomplete
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// args are the options of every Client of the tests, they apply to the
+// whole process.
+var args = []string{"-sys=false"}
+
+func writeFile(t *testing.T, name, content string) {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func contains(l []string, s string) bool {
+	for _, x := range l {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFlagsForFile(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("no gcc")
+	}
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "inc dir", "x.h"), "")
+	writeFile(t, filepath.Join(dir, "other", "y.h"), "")
+	src := filepath.Join(dir, "src", "a.c")
+	writeFile(t, src, "#include <x.h>\nint main(){}\n")
+
+	c := New(Options{Dir: dir, Args: args})
+	defer c.Close()
+	// 目录中的空格不能拆开
+	flags, err := c.FlagsForFile(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(flags, "-I"+filepath.Join(dir, "inc dir")) {
+		t.Fatalf("flags = %q, want the dir of x.h", flags)
+	}
+
+	writeFile(t, src, "#include <y.h>\nint main(){}\n")
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(src, later, later); err != nil {
+		t.Fatal(err)
+	}
+	flags, err = c.FlagsForFile(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(flags, "-I"+filepath.Join(dir, "other")) {
+		t.Errorf("flags after the file changed = %q, want the dir of y.h", flags)
+	}
+}
+
+func TestOptionsPerProcess(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := New(Options{Dir: dir, Args: args}).resolver(); err != nil {
+		t.Fatal(err)
+	}
+	c := New(Options{Dir: dir, Args: []string{"-sys=true"}})
+	if _, err := c.FlagsForFile(context.Background(), "clangcomplete.go"); err == nil {
+		t.Error("no error with options differing from the other clients")
+	}
+}
+
+func TestStale(t *testing.T) {
+	dir := t.TempDir()
+	c := New(Options{Dir: dir, Args: args})
+	defer c.Close()
+	if _, _, err := c.Stale(context.Background(), filepath.Join(dir, "missing"), ""); err == nil {
+		t.Error("no error for a missing output")
+	}
+}